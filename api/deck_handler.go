@@ -3,21 +3,40 @@
 package api
 
 import (
-	"errors"
 	"net/http"
+	"path"
 	"strconv"
 	"strings"
 	"test/cardsgame/controllers"
 	"test/cardsgame/data"
+	"test/cardsgame/fairness"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// Header identifying the player that makes a request. Decks
+// created with it are only fully visible to that player. It is
+// not authenticated, a gateway in front of the service must set it
+const PlayerHeader = "X-Player-Id"
+
 type DeckHandler struct {
 	controller *controllers.DeckController
 }
 
+// Handler that runs with the controller of a DeckHandler, so
+// that Conditional and Idempotent can run it with another one
+type DeckHandlerFunc func(h *DeckHandler, c *gin.Context)
+
+// Returns the gin handler that runs the handler with h
+func (h *DeckHandler) Handle(handler DeckHandlerFunc) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		handler(h, c)
+	}
+}
+
 // Converts a data.Card slice into a CardDto slice
 func convertCardSlice(cards []data.Card) []CardDto {
 
@@ -29,25 +48,75 @@ func convertCardSlice(cards []data.Card) []CardDto {
 	return dtoSlice
 }
 
-// Converts a data.Card object to a CardDto object
+// Converts a data.Card object to a CardDto object. Cards with
+// no code are hidden from the viewer
 func convertCardToCardDto(card *data.Card) *CardDto {
 
+	if card.Code == "" {
+		return &CardDto{Visible: false}
+	}
+
 	dto := &CardDto{
-		Code:  card.Code,
-		Value: card.Value.String(),
-		Suit:  card.Suit.String(),
+		Code:     card.Code,
+		Instance: card.Instance,
+		Value:    card.Value.String(),
+		Suit:     card.Suit.String(),
+		Visible:  true,
 	}
 
 	return dto
 }
 
+// Mounts the pile DTOs (with no cards) of a deck
+func convertPileSlice(piles []data.Pile) []PileNoCardsDto {
+
+	dtoSlice := make([]PileNoCardsDto, len(piles))
+	for i, v := range piles {
+		dtoSlice[i] = PileNoCardsDto{
+			Name:      v.Name,
+			Owner:     v.Owner,
+			FaceUp:    v.FaceUp,
+			Remaining: len(v.Cards),
+		}
+	}
+
+	return dtoSlice
+}
+
+// Returns the hash of the server seed of a fair deck, which is
+// public from its creation, or "" for the rest of decks
+func serverSeedHash(deck *data.Deck) string {
+
+	if !deck.Fair {
+		return ""
+	}
+
+	return fairness.SeedHash(deck.ServerSeed)
+}
+
 // Mounts deck DTO (with no cards) from deck model class
 func convertDeckToDeckNoCardsDto(deck *data.Deck) *DeckNoCardsDto {
 
 	dto := &DeckNoCardsDto{
-		Id:        deck.Id,
-		Shuffled:  deck.Shuffled,
-		Remaining: deck.Remaining,
+		Id:             deck.Id,
+		Version:        deck.Version,
+		Composition:    deck.Composition,
+		Jokers:         deck.Jokers,
+		Decks:          deck.Decks,
+		Shuffled:       deck.Shuffled,
+		Remaining:      deck.Remaining,
+		Drawn:          len(deck.Drawn),
+		Burned:         len(deck.Burned),
+		NeedsReshuffle: deck.NeedsReshuffle(),
+		Fair:           deck.Fair,
+		ServerSeedHash: serverSeedHash(deck),
+		ClientSeed:     deck.ClientSeed,
+		Commitment:     deck.Commitment,
+		Closed:         deck.Closed,
+		HideOrder:      deck.HideOrder,
+		CreatedAt:      deck.CreatedAt.UTC(),
+		Tags:           deck.Tags,
+		Piles:          convertPileSlice(deck.Piles),
 	}
 
 	return dto
@@ -57,9 +126,25 @@ func convertDeckToDeckNoCardsDto(deck *data.Deck) *DeckNoCardsDto {
 func convertDeckToDeckDto(deck *data.Deck) *DeckDto {
 
 	dto := &DeckDto{
-		Id:        deck.Id,
-		Shuffled:  deck.Shuffled,
-		Remaining: deck.Remaining,
+		Id:             deck.Id,
+		Version:        deck.Version,
+		Composition:    deck.Composition,
+		Jokers:         deck.Jokers,
+		Decks:          deck.Decks,
+		Shuffled:       deck.Shuffled,
+		Remaining:      deck.Remaining,
+		Drawn:          len(deck.Drawn),
+		Burned:         len(deck.Burned),
+		NeedsReshuffle: deck.NeedsReshuffle(),
+		Fair:           deck.Fair,
+		ServerSeedHash: serverSeedHash(deck),
+		ClientSeed:     deck.ClientSeed,
+		Commitment:     deck.Commitment,
+		Closed:         deck.Closed,
+		HideOrder:      deck.HideOrder,
+		CreatedAt:      deck.CreatedAt.UTC(),
+		Tags:           deck.Tags,
+		Piles:          convertPileSlice(deck.Piles),
 	}
 
 	dto.Cards = convertCardSlice(deck.Cards)
@@ -67,6 +152,82 @@ func convertDeckToDeckDto(deck *data.Deck) *DeckDto {
 	return dto
 }
 
+// Mounts the deck DTOs of a page of listed decks
+func convertDeckPageToDeckListDto(page *controllers.DeckPage) *DeckListDto {
+
+	dto := &DeckListDto{
+		Decks:      make([]DeckSummaryDto, len(page.Decks)),
+		NextCursor: page.Next,
+	}
+
+	for i, v := range page.Decks {
+		dto.Decks[i] = DeckSummaryDto{
+			Id:          v.Id,
+			Version:     v.Version,
+			Composition: v.Composition,
+			Decks:       v.Decks,
+			Shuffled:    v.Shuffled,
+			Remaining:   v.Remaining,
+			Fair:        v.Fair,
+			Closed:      v.Closed,
+			CreatedAt:   v.CreatedAt.UTC(),
+			Tags:        v.Tags,
+		}
+	}
+
+	return dto
+}
+
+// Reads an optional int query parameter, which is nil
+// when the parameter is not supplied
+func queryOptionalInt(c *gin.Context, name string) (*int, error) {
+
+	if c.Query(name) == "" {
+		return nil, nil
+	}
+
+	value, err := queryInt(c, name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+// Reads an optional bool query parameter, "true" or "false",
+// which is nil when the parameter is not supplied
+func queryOptionalBool(c *gin.Context, name string) (*bool, error) {
+
+	switch strings.ToLower(c.Query(name)) {
+	case "":
+		return nil, nil
+	case "true":
+		value := true
+		return &value, nil
+	case "false":
+		value := false
+		return &value, nil
+	}
+
+	return nil, &ParameterError{Name: name}
+}
+
+// Reads an int query parameter, returning the default
+// value when the parameter is not supplied
+func queryInt(c *gin.Context, name string, defaultValue int) (int, error) {
+
+	if c.Query(name) == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(c.Query(name))
+	if err != nil {
+		return 0, &ParameterError{Name: name}
+	}
+
+	return value, nil
+}
+
 // Constructor injects DeckController dependency
 func NewDeckHandler(controller *controllers.DeckController) *DeckHandler {
 
@@ -77,8 +238,8 @@ func NewDeckHandler(controller *controllers.DeckController) *DeckHandler {
 	return handler
 }
 
-// REST handler to create a new deck
-func (h *DeckHandler) CreateDeck(c *gin.Context) {
+// Reads the deck options from the query parameters
+func queryDeckOptions(c *gin.Context) (controllers.DeckOptions, error) {
 
 	shuffle := false
 
@@ -96,39 +257,216 @@ func (h *DeckHandler) CreateDeck(c *gin.Context) {
 		codes = nil
 	}
 
-	deck, err := h.controller.CreateDeck(shuffle, codes)
+	// Jokers and decks default to 0, and penetration to no
+	// cut card. If there is error parsing the numbers returns error
+	jokers, err := queryInt(c, "jokers", 0)
+	if err != nil {
+		return controllers.DeckOptions{}, err
+	}
+
+	decks, err := queryInt(c, "decks", 0)
+	if err != nil {
+		return controllers.DeckOptions{}, err
+	}
+
+	penetration := 0.0
+	if c.Query("penetration") != "" {
+		if value, err := strconv.ParseFloat(c.Query("penetration"), 64); err == nil {
+			penetration = value
+		} else {
+			return controllers.DeckOptions{}, &ParameterError{Name: "penetration"}
+		}
+	}
+
+	options := controllers.DeckOptions{
+		Shuffle:     shuffle,
+		Codes:       codes,
+		Composition: c.Query("composition"),
+		Jokers:      jokers,
+		Decks:       decks,
+		Penetration: penetration,
+		Fair:        strings.ToLower(c.Query("fair")) == "true",
+		// Repeated codes are allowed unless the parameter is false
+		RejectDuplicates: strings.ToLower(c.Query("allow_duplicates")) == "false",
+		// Optional hand shuffle methods, i.e. "riffle*7,cut"
+		ShuffleMethod: c.Query("shuffle_method"),
+		HideOrder:     strings.ToLower(c.Query("hide_order")) == "true",
+	}
+
+	// The client seed of fair decks is supplied once the server
+	// seed hash is published (see SeedDeck)
+	if c.Query("client_seed") != "" {
+		return controllers.DeckOptions{}, &ParameterError{Name: "client_seed"}
+	}
+
+	// Optional tags, i.e. "poker,table-1"
+	if c.Query("tags") != "" {
+		options.Tags = strings.Split(c.Query("tags"), ",")
+	}
+
+	// Optional seed, so that the shuffle can be reproduced
+	if c.Query("seed") != "" {
+		if value, err := strconv.ParseInt(c.Query("seed"), 10, 64); err == nil {
+			options.Seed = &value
+		} else {
+			return controllers.DeckOptions{}, &ParameterError{Name: "seed"}
+		}
+	}
+
+	return options, nil
+}
+
+// Reads the deck options from the JSON body (see CreateDeckRequest)
+func bodyDeckOptions(c *gin.Context) (controllers.DeckOptions, error) {
+
+	var request CreateDeckRequest
+	if err := bindJSONBody(c, &request); err != nil {
+		return controllers.DeckOptions{}, err
+	}
+
+	// Codes are case-proof as in the query parameter
+	var codes []string
+	for _, v := range request.Cards {
+		codes = append(codes, strings.ToUpper(v))
+	}
+
+	options := controllers.DeckOptions{
+		Shuffle:          request.Shuffle,
+		Codes:            codes,
+		RejectDuplicates: request.AllowDuplicates != nil && !*request.AllowDuplicates,
+		Composition:      request.Composition,
+		Jokers:           request.Jokers,
+		Decks:            request.Decks,
+		Penetration:      request.Penetration,
+		Seed:             request.Seed,
+		ShuffleMethod:    request.ShuffleMethod,
+		Fair:             request.Fair,
+		Tags:             request.Tags,
+		HideOrder:        request.HideOrder,
+	}
+
+	return options, nil
+}
+
+// REST handler to create a new deck. The options are read from
+// the JSON body if there is one, or else from the query parameters
+func (h *DeckHandler) CreateDeck(c *gin.Context) {
+
+	var options controllers.DeckOptions
+	var err error
+
+	if hasJSONBody(c) {
+		options, err = bodyDeckOptions(c)
+	} else {
+		options, err = queryDeckOptions(c)
+	}
 
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, nil)
+		problem(c, err)
+		return
+	}
+
+	// The player creating the deck owns it
+	options.Owner = c.GetHeader(PlayerHeader)
+
+	deck, err := h.controller.CreateDeckWithOptions(options)
+
+	if err != nil {
+		problem(c, err)
 		return
 	}
 
 	// Mounts the DTO from the model object
 	dto := convertDeckToDeckNoCardsDto(deck)
 
+	// URL of the new deck, under the same path
+	c.Header("Location", path.Join(c.Request.URL.Path, deck.Id.String()))
+	c.Set(createdDeckKey, deck.Id)
+	setETag(c, deck)
 	c.IndentedJSON(http.StatusCreated, dto)
 }
 
-// REST handler to retrieve one of the existing decks
+// REST handler to list the existing decks, filtered and
+// sorted by the query parameters, one page at a time
+func (h *DeckHandler) ListDecks(c *gin.Context) {
+
+	var options controllers.ListOptions
+	var err error
+
+	if options.Shuffled, err = queryOptionalBool(c, "shuffled"); err != nil {
+		problem(c, err)
+		return
+	}
+
+	if options.MinRemaining, err = queryOptionalInt(c, "min_remaining"); err != nil {
+		problem(c, err)
+		return
+	}
+
+	if options.MaxRemaining, err = queryOptionalInt(c, "max_remaining"); err != nil {
+		problem(c, err)
+		return
+	}
+
+	if options.Limit, err = queryInt(c, "limit", 0); err != nil {
+		problem(c, err)
+		return
+	}
+
+	// RFC 3339 time, i.e. "2024-05-01T12:00:00Z"
+	if c.Query("created_after") != "" {
+		if options.CreatedAfter, err = time.Parse(time.RFC3339Nano, c.Query("created_after")); err != nil {
+			problem(c, &ParameterError{Name: "created_after"})
+			return
+		}
+	}
+
+	options.Owner = c.Query("owner")
+	options.Tag = c.Query("tag")
+	options.Sort = c.Query("sort")
+	options.Cursor = c.Query("cursor")
+
+	page, err := h.controller.ListDecks(options)
+
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, convertDeckPageToDeckListDto(page))
+}
+
+// REST handler to retrieve one of the existing decks. The
+// cards of the decks that hide their order from the player are
+// returned in composition order
 func (h *DeckHandler) OpenDeck(c *gin.Context) {
 
 	uuid, err := uuid.Parse(c.Param("uuid"))
 	// Bad request invalid parameter
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, nil)
+		problem(c, ErrInvalidDeckId)
 		return
 	}
 
-	deck, err := h.controller.OpenDeck(uuid)
+	options := controllers.OpenOptions{
+		Viewer: c.GetHeader(PlayerHeader),
+	}
+
+	deck, err := h.controller.OpenDeckWithOptions(uuid, options)
 
 	if err != nil {
-		if errors.Is(err, controllers.ErrDeckNotFound) {
-			c.IndentedJSON(http.StatusNotFound, nil)
-			return
-		} else {
-			c.IndentedJSON(http.StatusBadRequest, nil)
-			return
-		}
+		problem(c, err)
+		return
+	}
+
+	// The cards seen depend on the player
+	c.Header("Vary", PlayerHeader)
+	setETag(c, deck)
+
+	// The client already has this version
+	if noneMatch(c.GetHeader("If-None-Match"), deckETag(deck)) {
+		c.Status(http.StatusNotModified)
+		return
 	}
 
 	// Mounts the DTO from the model object
@@ -137,41 +475,320 @@ func (h *DeckHandler) OpenDeck(c *gin.Context) {
 	c.IndentedJSON(http.StatusOK, dto)
 }
 
-// REST handler to get one sigle card from a deck
+// REST handler of the deprecated GET draw route. Drawing
+// changes the deck, so it is replaced by a POST that caches and
+// prefetchers don't send. The response points to its successor
+func (h *DeckHandler) DrawCardDeprecated(c *gin.Context) {
+
+	successor := path.Join(path.Dir(c.Request.URL.Path), "draw")
+	c.Header("Deprecation", "true")
+	c.Header("Link", "<"+successor+">; rel=\"successor-version\"")
+
+	h.DrawCard(c)
+}
+
+// REST handler to get one sigle card from a deck. The "from"
+// parameter draws from the top, bottom or random positions, and
+// "codes" draws the cards with those codes instead
 func (h *DeckHandler) DrawCard(c *gin.Context) {
 
 	uuid, err := uuid.Parse(c.Param("uuid"))
 	// Bad request invalid parameter
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, nil)
+		problem(c, ErrInvalidDeckId)
 		return
 	}
 
-	// Initialises amount with the default value of 1. If there
-	// is error parsing the amount number returns error
-	amount := 1
-	if c.Query("amount") != "" {
-		if value, err := strconv.Atoi(c.Query("amount")); err == nil {
-			amount = value
-		} else {
-			c.IndentedJSON(http.StatusBadRequest, nil)
-			return
-		}
+	options := controllers.DrawOptions{
+		From:   c.Query("from"),
+		Viewer: c.GetHeader(PlayerHeader),
 	}
 
-	cards, err := h.controller.DrawCards(uuid, amount)
+	// Optional codes of the cards to draw
+	if c.Query("codes") != "" {
+		options.Codes = strings.Split(strings.ToUpper(c.Query("codes")), ",")
+	}
 
+	// Initialises amount with the default value of 1, or all the
+	// codes if there are. If there is error parsing the amount
+	// number returns error
+	defaultAmount := 1
+	if len(options.Codes) > 0 {
+		defaultAmount = 0
+	}
+
+	options.Amount, err = queryInt(c, "amount", defaultAmount)
 	if err != nil {
-		if errors.Is(err, controllers.ErrDeckNotFound) {
-			c.IndentedJSON(http.StatusNotFound, nil)
-			return
-		} else if errors.Is(err, controllers.ErrNotEnoughCards) {
-			c.IndentedJSON(http.StatusBadRequest, nil)
-			return
-		} else {
-			c.IndentedJSON(http.StatusBadRequest, nil)
+		problem(c, err)
+		return
+	}
+
+	cards, err := h.controller.DrawCardsWithOptions(uuid, options)
+
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	// Mounts the DTO from the model object
+	dto := convertCardSlice(cards)
+
+	c.IndentedJSON(http.StatusOK, dto)
+}
+
+// REST handler to look at cards from the top of a deck
+// without drawing them
+func (h *DeckHandler) PeekCards(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	amount, err := queryInt(c, "amount", 1)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	cards, err := h.controller.PeekCards(uuid, amount, c.GetHeader(PlayerHeader))
+
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	// Mounts the DTO from the model object
+	dto := convertCardSlice(cards)
+
+	c.IndentedJSON(http.StatusOK, dto)
+}
+
+// REST handler to burn cards from the top of a deck
+func (h *DeckHandler) BurnCards(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	amount, err := queryInt(c, "amount", 1)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	deck, err := h.controller.BurnCards(uuid, amount, c.GetHeader(PlayerHeader))
+
+	deckResponse(c, deck, err)
+}
+
+// REST handler to close a deck, so that no more cards can be drawn
+func (h *DeckHandler) CloseDeck(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	deck, err := h.controller.CloseDeck(uuid, c.GetHeader(PlayerHeader))
+
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	setETag(c, deck)
+
+	// Mounts the DTO from the model object
+	dto := convertDeckToDeckNoCardsDto(deck)
+
+	c.IndentedJSON(http.StatusOK, dto)
+}
+
+// REST handler to discard a deck with all its cards and piles
+func (h *DeckHandler) DeleteDeck(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	if err := h.controller.DeleteDeck(uuid, c.GetHeader(PlayerHeader)); err != nil {
+		problem(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Writes the response of the operations that change a deck
+// and return it without its cards
+func deckResponse(c *gin.Context, deck *data.Deck, err error) {
+
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	setETag(c, deck)
+
+	// Mounts the DTO from the model object
+	dto := convertDeckToDeckNoCardsDto(deck)
+
+	c.IndentedJSON(http.StatusOK, dto)
+}
+
+// REST handler to shuffle the cards left in a deck, with the
+// hand shuffle methods of the "method" parameter if present.
+// With "include_drawn" the drawn cards are shuffled too
+func (h *DeckHandler) ShuffleDeck(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	includeDrawn := strings.ToLower(c.Query("include_drawn")) == "true"
+	deck, err := h.controller.ShuffleDeck(uuid, c.Query("method"), includeDrawn, c.GetHeader(PlayerHeader))
+
+	deckResponse(c, deck, err)
+}
+
+// REST handler to put drawn cards back in a deck. The "cards"
+// parameter selects them, all the drawn cards if not supplied,
+// and "position" is top, bottom or random
+func (h *DeckHandler) ReturnCards(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	var codes []string
+	if c.Query("cards") != "" {
+		codes = strings.Split(strings.ToUpper(c.Query("cards")), ",")
+	}
+
+	deck, err := h.controller.ReturnCards(uuid, codes, c.Query("position"), c.GetHeader(PlayerHeader))
+
+	deckResponse(c, deck, err)
+}
+
+// REST handler to cut a deck. The "at" parameter is the amount
+// of cards moved to the bottom, close to half if not supplied
+func (h *DeckHandler) CutDeck(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	var position *int
+	if c.Query("at") != "" {
+		value, err := strconv.Atoi(c.Query("at"))
+		if err != nil {
+			problem(c, &ParameterError{Name: "at"})
 			return
 		}
+		position = &value
+	}
+
+	deck, err := h.controller.CutDeck(uuid, position, c.GetHeader(PlayerHeader))
+
+	deckResponse(c, deck, err)
+}
+
+// REST handler to add an empty pile, named with the "name"
+// parameter, to a deck. The "owner" parameter is the player
+// holding it and "face_up" shows its cards to every player
+func (h *DeckHandler) CreatePile(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	options := controllers.PileOptions{
+		Name:   c.Query("name"),
+		Owner:  c.Query("owner"),
+		FaceUp: strings.ToLower(c.Query("face_up")) == "true",
+		Viewer: c.GetHeader(PlayerHeader),
+	}
+
+	deck, err := h.controller.CreatePileWithOptions(uuid, options)
+
+	deckResponse(c, deck, err)
+}
+
+// REST handler to get the cards of a pile, hidden unless the
+// player of the request can see them
+func (h *DeckHandler) GetPile(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	pile, err := h.controller.GetPile(uuid, c.Param("pile"), c.GetHeader(PlayerHeader))
+
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	dto := &PileDto{
+		Name:      pile.Name,
+		Owner:     pile.Owner,
+		FaceUp:    pile.FaceUp,
+		Remaining: len(pile.Cards),
+		Cards:     convertCardSlice(pile.Cards),
+	}
+
+	c.IndentedJSON(http.StatusOK, dto)
+}
+
+// REST handler to draw cards from a deck to one of its piles.
+// The "from" parameter draws from the top, bottom or random
+// positions
+func (h *DeckHandler) DrawCardsToPile(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	amount, err := queryInt(c, "amount", 1)
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	cards, err := h.controller.DrawCardsToPile(uuid, c.Param("pile"), amount, c.Query("from"), c.GetHeader(PlayerHeader))
+
+	if err != nil {
+		problem(c, err)
+		return
 	}
 
 	// Mounts the DTO from the model object
@@ -179,3 +796,107 @@ func (h *DeckHandler) DrawCard(c *gin.Context) {
 
 	c.IndentedJSON(http.StatusOK, dto)
 }
+
+// REST handler to move cards from a pile to the pile of the
+// "to" parameter. The "cards" parameter selects them, all the
+// cards of the pile if not supplied
+func (h *DeckHandler) MovePileCards(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	var codes []string
+	if c.Query("cards") != "" {
+		codes = strings.Split(strings.ToUpper(c.Query("cards")), ",")
+	}
+
+	deck, err := h.controller.MovePileCards(uuid, c.Param("pile"), c.Query("to"), codes, c.GetHeader(PlayerHeader))
+
+	deckResponse(c, deck, err)
+}
+
+// REST handler to shuffle the cards of a pile, with the hand
+// shuffle methods of the "method" parameter if present
+func (h *DeckHandler) ShufflePile(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	deck, err := h.controller.ShufflePile(uuid, c.Param("pile"), c.Query("method"), c.GetHeader(PlayerHeader))
+
+	deckResponse(c, deck, err)
+}
+
+// REST handler to put the cards of a pile back in the deck.
+// The "position" parameter is top, bottom or random
+func (h *DeckHandler) ReturnPile(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	deck, err := h.controller.ReturnPile(uuid, c.Param("pile"), c.Query("position"), c.GetHeader(PlayerHeader))
+
+	deckResponse(c, deck, err)
+}
+
+// REST handler to shuffle a fair deck with the "client_seed"
+// parameter, once its server seed hash has been published
+func (h *DeckHandler) SeedDeck(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	deck, err := h.controller.SeedDeck(uuid, c.Query("client_seed"), c.GetHeader(PlayerHeader))
+
+	deckResponse(c, deck, err)
+}
+
+// REST handler to reveal the server seed of a fair deck
+// once it is closed or has no cards left
+func (h *DeckHandler) RevealDeck(c *gin.Context) {
+
+	uuid, err := uuid.Parse(c.Param("uuid"))
+	// Bad request invalid parameter
+	if err != nil {
+		problem(c, ErrInvalidDeckId)
+		return
+	}
+
+	reveal, err := h.controller.RevealDeck(uuid)
+
+	if err != nil {
+		problem(c, err)
+		return
+	}
+
+	dto := &RevealDto{
+		Id:             uuid,
+		ServerSeed:     reveal.ServerSeed,
+		ServerSeedHash: reveal.ServerSeedHash,
+		ClientSeed:     reveal.ClientSeed,
+		Commitment:     reveal.Commitment,
+		Composition:    reveal.Composition,
+		Jokers:         reveal.Jokers,
+		Decks:          reveal.Decks,
+		InitialCards:   reveal.InitialCards,
+		Cards:          reveal.Cards,
+	}
+
+	c.IndentedJSON(http.StatusOK, dto)
+}
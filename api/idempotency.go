@@ -0,0 +1,157 @@
+// Author: Ferran Balaguer
+
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Header with the key that identifies a request and its retries
+const IdempotencyHeader = "Idempotency-Key"
+
+// Header set on the responses replayed for a retried request
+const ReplayedHeader = "Idempotent-Replayed"
+
+// Context key of the deck created by a request
+const createdDeckKey = "created_deck"
+
+// Identifies the parameters of a request, so that a key reused
+// for a different request is detected
+func requestFingerprint(c *gin.Context, body []byte) string {
+
+	hash := sha256.New()
+
+	for _, v := range []string{c.Request.Method, c.Request.URL.Path, c.Request.URL.Query().Encode(), c.GetHeader(PlayerHeader), c.ContentType()} {
+		hash.Write([]byte(v))
+		hash.Write([]byte{0})
+	}
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Returns the deck created or changed by a request
+func requestDeck(c *gin.Context) (uuid.UUID, bool) {
+
+	if id, err := uuid.Parse(c.Param("uuid")); err == nil {
+		return id, true
+	}
+
+	id, ok := c.Get(createdDeckKey)
+	if !ok {
+		return uuid.Nil, false
+	}
+
+	return id.(uuid.UUID), true
+}
+
+// Writes a stored response as it was first sent
+func replayResponse(c *gin.Context, response *data.StoredResponse) {
+
+	for k, v := range response.Header {
+		c.Writer.Header()[k] = append([]string(nil), v...)
+	}
+	c.Header(ReplayedHeader, "true")
+
+	c.Writer.WriteHeader(response.Status)
+	c.Writer.Write(response.Body)
+}
+
+// Wraps a handler so that the requests with an Idempotency-Key
+// are only run once. Successful responses are stored with their
+// deck in the same change made by the handler, so that either
+// both are stored or none, and the retries of the request get
+// the same response instead of drawing or shuffling again
+func (h *DeckHandler) Idempotent(handler DeckHandlerFunc) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			handler(h, c)
+			return
+		}
+
+		// The body is part of the fingerprint, and it is
+		// restored for the handler
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			problem(c, fmt.Errorf("%w: %v", ErrInvalidBody, err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(c, body)
+
+		stored, err := h.controller.BeginRequest(key, fingerprint)
+		if err != nil {
+			problem(c, err)
+			return
+		}
+
+		if stored != nil {
+			replayResponse(c, stored)
+			return
+		}
+
+		defer h.controller.EndRequest(key)
+
+		// The response is held until it is stored
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		run := func(controller *controllers.DeckController) error {
+
+			handler(&DeckHandler{controller: controller}, c)
+
+			// Failed requests don't change the deck, so they are
+			// run again when retried
+			if writer.Status() >= http.StatusMultipleChoices {
+				return errHandlerFailed
+			}
+
+			id, ok := requestDeck(c)
+			if !ok {
+				return nil
+			}
+
+			response := data.StoredResponse{
+				Key:         key,
+				Fingerprint: fingerprint,
+				Status:      writer.Status(),
+				Header:      writer.Header().Clone(),
+				Body:        writer.body.Bytes(),
+			}
+
+			return controller.SaveResponse(id, response)
+		}
+
+		// Requests on a deck change it at any version, and the
+		// others can only create new decks
+		if id, parseErr := uuid.Parse(c.Param("uuid")); parseErr == nil {
+			err = h.controller.IfVersion(id, nil, run)
+		} else {
+			err = h.controller.WithNewDecks(run)
+		}
+
+		c.Writer = writer.ResponseWriter
+
+		if err != nil && !errors.Is(err, errHandlerFailed) {
+			discardedProblem(c, err)
+			return
+		}
+
+		writer.send()
+	}
+}
@@ -7,27 +7,156 @@
 package api
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
-// CardDto type definition
+// CardDto type definition. Cards hidden from the viewer
+// only have the visible field
 type CardDto struct {
-	Value string `json:"value"`
-	Suit  string `json:"suit"`
-	Code  string `json:"code"`
+	Value    string `json:"value,omitempty"`
+	Suit     string `json:"suit,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Instance int    `json:"instance,omitempty"`
+	Visible  bool   `json:"visible"`
 }
 
 // DeckDto type definition
 type DeckDto struct {
-	Id        uuid.UUID `json:"deck_id"`
-	Shuffled  bool      `json:"shuffled"`
-	Remaining int       `json:"remaining"`
-	Cards     []CardDto `json:"cards"`
+	Id             uuid.UUID        `json:"deck_id"`
+	Version        int64            `json:"version"`
+	Composition    string           `json:"composition"`
+	Jokers         int              `json:"jokers"`
+	Decks          int              `json:"decks"`
+	Shuffled       bool             `json:"shuffled"`
+	Remaining      int              `json:"remaining"`
+	Drawn          int              `json:"drawn"`
+	Burned         int              `json:"burned"`
+	NeedsReshuffle bool             `json:"needs_reshuffle"`
+	Fair           bool             `json:"fair"`
+	ServerSeedHash string           `json:"server_seed_hash,omitempty"`
+	ClientSeed     string           `json:"client_seed,omitempty"`
+	Commitment     string           `json:"commitment,omitempty"`
+	Closed         bool             `json:"closed"`
+	HideOrder      bool             `json:"hide_order"`
+	CreatedAt      time.Time        `json:"created_at"`
+	Tags           []string         `json:"tags,omitempty"`
+	Piles          []PileNoCardsDto `json:"piles"`
+	Cards          []CardDto        `json:"cards"`
 }
 
 // DeckDto type definition
 type DeckNoCardsDto struct {
-	Id        uuid.UUID `json:"deck_id"`
-	Shuffled  bool      `json:"shuffled"`
+	Id             uuid.UUID        `json:"deck_id"`
+	Version        int64            `json:"version"`
+	Composition    string           `json:"composition"`
+	Jokers         int              `json:"jokers"`
+	Decks          int              `json:"decks"`
+	Shuffled       bool             `json:"shuffled"`
+	Remaining      int              `json:"remaining"`
+	Drawn          int              `json:"drawn"`
+	Burned         int              `json:"burned"`
+	NeedsReshuffle bool             `json:"needs_reshuffle"`
+	Fair           bool             `json:"fair"`
+	ServerSeedHash string           `json:"server_seed_hash,omitempty"`
+	ClientSeed     string           `json:"client_seed,omitempty"`
+	Commitment     string           `json:"commitment,omitempty"`
+	Closed         bool             `json:"closed"`
+	HideOrder      bool             `json:"hide_order"`
+	CreatedAt      time.Time        `json:"created_at"`
+	Tags           []string         `json:"tags,omitempty"`
+	Piles          []PileNoCardsDto `json:"piles"`
+}
+
+// DeckSummaryDto type definition. Decks are listed with
+// their metadata and no cards
+type DeckSummaryDto struct {
+	Id          uuid.UUID `json:"deck_id"`
+	Version     int64     `json:"version"`
+	Composition string    `json:"composition"`
+	Decks       int       `json:"decks"`
+	Shuffled    bool      `json:"shuffled"`
+	Remaining   int       `json:"remaining"`
+	Fair        bool      `json:"fair"`
+	Closed      bool      `json:"closed"`
+	CreatedAt   time.Time `json:"created_at"`
+	Tags        []string  `json:"tags,omitempty"`
+}
+
+// DeckListDto type definition. NextCursor is only set if
+// there are more decks, to list them with the cursor parameter
+type DeckListDto struct {
+	Decks      []DeckSummaryDto `json:"decks"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// PileDto type definition
+type PileDto struct {
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner,omitempty"`
+	FaceUp    bool      `json:"face_up"`
 	Remaining int       `json:"remaining"`
+	Cards     []CardDto `json:"cards"`
+}
+
+// PileNoCardsDto type definition
+type PileNoCardsDto struct {
+	Name      string `json:"name"`
+	Owner     string `json:"owner,omitempty"`
+	FaceUp    bool   `json:"face_up"`
+	Remaining int    `json:"remaining"`
+}
+
+// RevealDto type definition. It can be verified
+// with cmd/verifydeck
+type RevealDto struct {
+	Id             uuid.UUID `json:"deck_id"`
+	ServerSeed     string    `json:"server_seed"`
+	ServerSeedHash string    `json:"server_seed_hash"`
+	ClientSeed     string    `json:"client_seed"`
+	Commitment     string    `json:"commitment"`
+	Composition    string    `json:"composition"`
+	Jokers         int       `json:"jokers"`
+	Decks          int       `json:"decks"`
+	InitialCards   []string  `json:"initial_cards"`
+	Cards          []string  `json:"cards"`
+}
+
+// ProblemDto type definition. Error responses follow RFC 7807
+// (problem+json), with a machine-readable code and the details
+// that apply to each error. Internal errors only have the
+// correlation id of the logged error
+type ProblemDto struct {
+	Type       string   `json:"type"`
+	Title      string   `json:"title"`
+	Status     int      `json:"status"`
+	Detail     string   `json:"detail,omitempty"`
+	Instance   string   `json:"instance,omitempty"`
+	Code       string   `json:"code"`
+	Parameter  string   `json:"parameter,omitempty"`
+	Cards      []string `json:"cards,omitempty"`
+	Duplicates []string `json:"duplicates,omitempty"`
+	Requested  *int     `json:"requested,omitempty"`
+	Remaining  *int     `json:"remaining,omitempty"`
+	// Identifies the internal error in the logs
+	CorrelationId string `json:"correlation_id,omitempty"`
+}
+
+// CreateDeckRequest type definition. JSON body accepted when
+// creating a deck instead of the query parameters, which have
+// the same names. Unknown fields are rejected
+type CreateDeckRequest struct {
+	Shuffle         bool     `json:"shuffle"`
+	Cards           []string `json:"cards" binding:"omitempty,dive,required"`
+	AllowDuplicates *bool    `json:"allow_duplicates"`
+	Composition     string   `json:"composition"`
+	Jokers          int      `json:"jokers"`
+	Decks           int      `json:"decks"`
+	Penetration     float64  `json:"penetration"`
+	Seed            *int64   `json:"seed"`
+	ShuffleMethod   string   `json:"shuffle_method"`
+	Fair            bool     `json:"fair"`
+	Tags            []string `json:"tags" binding:"omitempty,dive,required"`
+	HideOrder       bool     `json:"hide_order"`
 }
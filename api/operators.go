@@ -0,0 +1,30 @@
+// Author: Ferran Balaguer
+
+package api
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Returns a handler that only lets the requests of operators
+// through, which send the token in an "Authorization: Bearer"
+// header. With no token every request is rejected
+func RequireOperator(token string) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		sent, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="operators"`)
+			problem(c, ErrNotOperator)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,162 @@
+// Author: Ferran Balaguer
+
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"test/cardsgame/controllers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Errors of the requests rejected before reaching the controller
+var (
+	ErrInvalidDeckId    = errors.New("Invalid deck id")
+	ErrInvalidParameter = errors.New("Invalid parameter")
+	ErrNotOperator      = errors.New("Only operators can do this")
+)
+
+// Content type of the error responses
+const ProblemContentType = "application/problem+json"
+
+// Header with the correlation id of internal errors
+const CorrelationHeader = "X-Correlation-Id"
+
+// Prefix of the type of every problem, followed by its code
+const problemTypePrefix = "urn:cardsgame:problem:"
+
+// ErrInvalidParameter with the name of the parameter
+type ParameterError struct {
+	Name string
+}
+
+func (e *ParameterError) Error() string {
+	return ErrInvalidParameter.Error() + ": " + e.Name
+}
+
+func (e *ParameterError) Unwrap() error {
+	return ErrInvalidParameter
+}
+
+// Kind of error returned to the clients
+type problemKind struct {
+	err    error
+	status int
+	code   string
+}
+
+// Every error the clients can get. Errors not listed here are
+// internal errors, whose details are not returned
+var problemKinds = []problemKind{
+	{ErrInvalidDeckId, http.StatusBadRequest, "invalid_deck_id"},
+	{ErrInvalidParameter, http.StatusBadRequest, "invalid_parameter"},
+	{ErrNotOperator, http.StatusUnauthorized, "not_operator"},
+	{ErrInvalidBody, http.StatusBadRequest, "invalid_body"},
+	{controllers.ErrInvalidCardCode, http.StatusBadRequest, "invalid_card_code"},
+	{controllers.ErrDuplicateCardCode, http.StatusBadRequest, "duplicate_card_code"},
+	{controllers.ErrDeckNotFound, http.StatusNotFound, "deck_not_found"},
+	{controllers.ErrNotEnoughCards, http.StatusBadRequest, "not_enough_cards"},
+	{controllers.ErrInvalidAmount, http.StatusBadRequest, "invalid_amount"},
+	{controllers.ErrInvalidComposition, http.StatusBadRequest, "invalid_composition"},
+	{controllers.ErrInvalidJokers, http.StatusBadRequest, "invalid_jokers"},
+	{controllers.ErrInvalidDecks, http.StatusBadRequest, "invalid_decks"},
+	{controllers.ErrInvalidPenetration, http.StatusBadRequest, "invalid_penetration"},
+	{controllers.ErrSeedWithoutShuffle, http.StatusBadRequest, "seed_without_shuffle"},
+	{controllers.ErrFairPartialDeck, http.StatusBadRequest, "fair_partial_deck"},
+	{controllers.ErrDeckClosed, http.StatusConflict, "deck_closed"},
+	{controllers.ErrDeckNotFair, http.StatusBadRequest, "deck_not_fair"},
+	{controllers.ErrDeckNotFinished, http.StatusConflict, "deck_not_finished"},
+	{controllers.ErrInvalidShuffleMethod, http.StatusBadRequest, "invalid_shuffle_method"},
+	{controllers.ErrFairDeckShuffle, http.StatusConflict, "fair_deck_reorder"},
+	{controllers.ErrFairDeckSeeded, http.StatusConflict, "fair_deck_seeded"},
+	{controllers.ErrFairDeckNotSeeded, http.StatusConflict, "fair_deck_not_seeded"},
+	{controllers.ErrInvalidClientSeed, http.StatusBadRequest, "invalid_client_seed"},
+	{controllers.ErrCardNotDrawn, http.StatusBadRequest, "card_not_drawn"},
+	{controllers.ErrInvalidPosition, http.StatusBadRequest, "invalid_position"},
+	{controllers.ErrCardNotFound, http.StatusBadRequest, "card_not_found"},
+	{controllers.ErrInvalidPileName, http.StatusBadRequest, "invalid_pile_name"},
+	{controllers.ErrPileNotFound, http.StatusNotFound, "pile_not_found"},
+	{controllers.ErrPileExists, http.StatusConflict, "pile_exists"},
+	{controllers.ErrTooManyPiles, http.StatusConflict, "too_many_piles"},
+	{controllers.ErrInvalidPlayer, http.StatusBadRequest, "invalid_player"},
+	{controllers.ErrNotDeckOwner, http.StatusForbidden, "not_deck_owner"},
+	{controllers.ErrNotPileOwner, http.StatusForbidden, "not_pile_owner"},
+	{controllers.ErrInvalidIdempotency, http.StatusBadRequest, "invalid_idempotency_key"},
+	{controllers.ErrIdempotencyReused, http.StatusUnprocessableEntity, "idempotency_key_reused"},
+	{controllers.ErrIdempotencyInUse, http.StatusConflict, "idempotency_key_in_use"},
+	{controllers.ErrIdempotencyFull, http.StatusTooManyRequests, "idempotency_store_full"},
+	{controllers.ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch"},
+	{controllers.ErrInvalidTag, http.StatusBadRequest, "invalid_tag"},
+	{controllers.ErrInvalidSort, http.StatusBadRequest, "invalid_sort"},
+	{controllers.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+	{controllers.ErrInvalidPageSize, http.StatusBadRequest, "invalid_page_size"},
+}
+
+// Internal errors, including controllers.ErrGeneral
+var internalProblem = problemKind{errors.New("Internal error"), http.StatusInternalServerError, "internal_error"}
+
+// Mounts the problem DTO of an error
+func convertErrorToProblemDto(err error) *ProblemDto {
+
+	kind := internalProblem
+	for _, v := range problemKinds {
+		if errors.Is(err, v.err) {
+			kind = v
+			break
+		}
+	}
+
+	dto := &ProblemDto{
+		Type:   problemTypePrefix + kind.code,
+		Title:  kind.err.Error(),
+		Status: kind.status,
+		Code:   kind.code,
+	}
+
+	if kind == internalProblem {
+		return dto
+	}
+
+	dto.Detail = err.Error()
+
+	var parameterErr *ParameterError
+	if errors.As(err, &parameterErr) {
+		dto.Parameter = parameterErr.Name
+	}
+
+	var codeErr *controllers.CardCodeError
+	if errors.As(err, &codeErr) {
+		dto.Cards = codeErr.Codes
+		dto.Duplicates = codeErr.Duplicates
+	}
+
+	var cardsErr *controllers.NotEnoughCardsError
+	if errors.As(err, &cardsErr) {
+		dto.Requested = &cardsErr.Requested
+		dto.Remaining = &cardsErr.Remaining
+	}
+
+	return dto
+}
+
+// Writes the problem+json response of an error. Internal errors
+// are logged with a new correlation id, which is returned instead
+// of their details
+func problem(c *gin.Context, err error) {
+
+	dto := convertErrorToProblemDto(err)
+	dto.Instance = c.Request.URL.Path
+
+	if dto.Status == http.StatusInternalServerError {
+		dto.CorrelationId = uuid.New().String()
+		c.Header(CorrelationHeader, dto.CorrelationId)
+		log.Printf("Internal error %s in %s %s: %v", dto.CorrelationId, c.Request.Method, dto.Instance, err)
+	}
+
+	// Set before rendering so that it is not replaced
+	c.Header("Content-Type", ProblemContentType)
+	c.IndentedJSON(dto.Status, dto)
+}
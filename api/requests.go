@@ -0,0 +1,74 @@
+// Author: Ferran Balaguer
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Error of the request bodies that can't be bound
+var ErrInvalidBody = errors.New("Invalid request body")
+
+// JSON binding that rejects unknown fields, so that misspelled
+// fields are not silently ignored. Bound objects are then
+// validated with their binding tags
+type strictJSONBinding struct{}
+
+func (strictJSONBinding) Name() string {
+	return "strict json"
+}
+
+func (b strictJSONBinding) Bind(req *http.Request, obj any) error {
+
+	if req == nil || req.Body == nil {
+		return errors.New("missing body")
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+
+	// Only one JSON value is accepted
+	if decoder.More() {
+		return errors.New("unexpected data after the JSON object")
+	}
+
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// Tells if the request has a JSON body. An empty body, or one
+// with only whitespace, is no body even with a JSON content type,
+// so that clients that always set it can still use the query
+// parameters. The body is restored for the handler
+func hasJSONBody(c *gin.Context) bool {
+
+	if c.ContentType() != binding.MIMEJSON || c.Request.Body == nil || c.Request.ContentLength == 0 {
+		return false
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	// A body that can't be read is left to fail when bound
+	return err != nil || len(bytes.TrimSpace(body)) > 0
+}
+
+// Binds the JSON body of the request into obj
+func bindJSONBody(c *gin.Context, obj any) error {
+
+	if err := c.ShouldBindWith(obj, strictJSONBinding{}); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBody, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+// Author: Ferran Balaguer
+
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Returned by the handlers run by Conditional that failed, so
+// that their changes are discarded
+var errHandlerFailed = errors.New("Handler failed")
+
+// Entity tag of a version of a deck
+func versionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// Entity tag of a deck, which changes with every version
+func deckETag(deck *data.Deck) string {
+	return versionETag(deck.Version)
+}
+
+// Sets the ETag header of a response with a deck
+func setETag(c *gin.Context, deck *data.Deck) {
+	c.Header("ETag", deckETag(deck))
+}
+
+// Tells if an If-None-Match header matches the entity tag, so
+// that the client already has it. Weak tags also match
+func noneMatch(header string, etag string) bool {
+
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reads the versions of an If-Match header, a list of entity
+// tags separated by commas, or nil if it has "*" and matches any
+// version. If-Match uses the strong comparison, so weak tags and
+// tags that are not versions never match
+func parseIfMatch(header string) ([]int64, error) {
+
+	versions := []int64{}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+
+		if tag == "*" {
+			return nil, nil
+		}
+
+		weak := strings.HasPrefix(tag, "W/")
+		tag = strings.TrimPrefix(tag, "W/")
+
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			return nil, &ParameterError{Name: "If-Match"}
+		}
+
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err == nil && !weak {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions, nil
+}
+
+// Response writer that holds the response until it is sent,
+// so that it can be discarded
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	return w.body.Write(b)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.status != 0
+}
+
+// Sends the held response
+func (w *bufferedWriter) send() {
+	w.ResponseWriter.WriteHeader(w.Status())
+	w.ResponseWriter.Write(w.body.Bytes())
+}
+
+// Sends the problem of an error instead of a held response,
+// whose changes were discarded, without the headers it set
+func discardedProblem(c *gin.Context, err error) {
+
+	c.Writer.Header().Del("Location")
+	c.Writer.Header().Del("ETag")
+
+	problem(c, err)
+}
+
+// Wraps a handler that changes a deck so that it honors the
+// If-Match header. The handler only runs if the deck is at one
+// of the versions of the header, or "*" for any, and no other
+// request changes the deck until it ends. Otherwise the
+// response is 412 Precondition Failed
+func Conditional(handler DeckHandlerFunc) DeckHandlerFunc {
+
+	return func(h *DeckHandler, c *gin.Context) {
+
+		header := c.GetHeader("If-Match")
+		if header == "" {
+			handler(h, c)
+			return
+		}
+
+		uuid, err := uuid.Parse(c.Param("uuid"))
+		if err != nil {
+			problem(c, ErrInvalidDeckId)
+			return
+		}
+
+		versions, err := parseIfMatch(header)
+		if err != nil {
+			problem(c, err)
+			return
+		}
+
+		var version int64
+
+		// The response is held until the changes are stored
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		err = h.controller.IfVersion(uuid, versions, func(controller *controllers.DeckController) error {
+			// Version matched by the header, which no other request
+			// can change until the handler ends
+			deck, err := controller.OpenDeck(uuid)
+			if err != nil {
+				return err
+			}
+			version = deck.Version
+
+			handler(&DeckHandler{controller: controller}, c)
+			if writer.Status() >= http.StatusMultipleChoices {
+				return errHandlerFailed
+			}
+			return nil
+		})
+
+		c.Writer = writer.ResponseWriter
+
+		if err != nil && !errors.Is(err, errHandlerFailed) {
+			discardedProblem(c, err)
+			return
+		}
+
+		// Every change of the handler is stored as one version,
+		// so the new one is known even if the handler returns no deck
+		if err == nil && c.Request.Method != http.MethodDelete {
+			c.Header("ETag", versionETag(version+1))
+		}
+
+		writer.send()
+	}
+}
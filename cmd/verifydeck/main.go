@@ -0,0 +1,47 @@
+// Author: Ferran Balaguer
+
+// Verifies a provably fair deck. It reads the response of the
+// reveal endpoint (GET /api/v1/deck/{uuid}/reveal) from the file
+// passed as argument, or from the standard input. The server seed
+// hash in the response should be compared with the one published
+// when the deck was created:
+//
+//	curl localhost:8080/api/v1/deck/{uuid}/reveal | go run ./cmd/verifydeck
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"test/cardsgame/fairness"
+)
+
+func main() {
+
+	input := io.Reader(os.Stdin)
+
+	if len(os.Args) > 1 {
+		file, err := os.Open(os.Args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var reveal fairness.Reveal
+	if err := json.NewDecoder(input).Decode(&reveal); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid reveal:", err)
+		os.Exit(2)
+	}
+
+	if err := fairness.Verify(reveal); err != nil {
+		fmt.Println("NOT VERIFIED:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("VERIFIED: %d cards of %d %s decks shuffled with the revealed seeds\n", len(reveal.Cards), reveal.Decks, reveal.Composition)
+}
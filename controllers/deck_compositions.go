@@ -0,0 +1,92 @@
+// Author: Ferran Balaguer
+
+package controllers
+
+import (
+	"strings"
+	"test/cardsgame/data"
+)
+
+// Composition names
+const (
+	CompositionStandard = "standard"
+	CompositionPiquet   = "piquet"
+	CompositionEuchre   = "euchre"
+	CompositionPinochle = "pinochle"
+)
+
+// Limits of the deck options
+const (
+	// Maximum number of jokers a deck can contain
+	MaxJokers int = 2
+	// Maximum number of decks a shoe can contain
+	MaxDecks int = 8
+)
+
+// DeckComposition describes which cards make up a deck. Every
+// value is generated for each of the four suits, and the whole
+// set is repeated Copies times
+type DeckComposition struct {
+	Name   string
+	Values []data.CardValue
+	Copies int
+}
+
+// Returns the amount of cards in the composition, without jokers
+func (d DeckComposition) Size() int {
+	return len(d.Values) * 4 * d.Copies
+}
+
+// Available deck compositions by name
+var compositions = map[string]DeckComposition{
+	// French deck, 52 cards
+	CompositionStandard: {
+		Name: CompositionStandard,
+		Values: []data.CardValue{
+			data.Ace, data.Two, data.Three, data.Four, data.Five, data.Six, data.Seven,
+			data.Eight, data.Nine, data.Ten, data.Jack, data.Queen, data.King,
+		},
+		Copies: 1,
+	},
+	// Stripped deck from Seven to Ace, 32 cards
+	CompositionPiquet: {
+		Name: CompositionPiquet,
+		Values: []data.CardValue{
+			data.Ace, data.Seven, data.Eight, data.Nine, data.Ten, data.Jack, data.Queen, data.King,
+		},
+		Copies: 1,
+	},
+	// Stripped deck from Nine to Ace, 24 cards
+	CompositionEuchre: {
+		Name: CompositionEuchre,
+		Values: []data.CardValue{
+			data.Ace, data.Nine, data.Ten, data.Jack, data.Queen, data.King,
+		},
+		Copies: 1,
+	},
+	// Two stripped decks from Nine to Ace, 48 cards
+	CompositionPinochle: {
+		Name: CompositionPinochle,
+		Values: []data.CardValue{
+			data.Ace, data.Nine, data.Ten, data.Jack, data.Queen, data.King,
+		},
+		Copies: 2,
+	},
+}
+
+// Returns the composition with the given name. An empty name
+// returns the standard composition
+func GetComposition(name string) (DeckComposition, error) {
+
+	if name == "" {
+		name = CompositionStandard
+	}
+
+	composition, ok := compositions[strings.ToLower(name)]
+
+	if !ok {
+		return DeckComposition{}, ErrInvalidComposition
+	}
+
+	return composition, nil
+}
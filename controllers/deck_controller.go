@@ -4,7 +4,10 @@ package controllers
 
 import (
 	"errors"
+	"math"
 	"math/rand"
+	"sort"
+	"sync"
 	"test/cardsgame/data"
 	"time"
 
@@ -13,157 +16,480 @@ import (
 
 // Controller errors
 var (
-	ErrInvalidCardCode = errors.New("Invalid Card Code")
-	ErrDeckNotFound    = errors.New("Deck not found")
-	ErrNotEnoughCards  = errors.New("Not enough cards left")
-	ErrInvalidAmount   = errors.New("Invalid amount of cards")
-	ErrGeneral         = errors.New("General error")
+	ErrInvalidCardCode      = errors.New("Invalid Card Code")
+	ErrDuplicateCardCode    = errors.New("Duplicate Card Code")
+	ErrDeckNotFound         = errors.New("Deck not found")
+	ErrNotEnoughCards       = errors.New("Not enough cards left")
+	ErrInvalidAmount        = errors.New("Invalid amount of cards")
+	ErrInvalidComposition   = errors.New("Invalid deck composition")
+	ErrInvalidJokers        = errors.New("Invalid amount of jokers")
+	ErrInvalidDecks         = errors.New("Invalid amount of decks")
+	ErrInvalidPenetration   = errors.New("Invalid penetration")
+	ErrSeedWithoutShuffle   = errors.New("Seed requires a shuffled deck")
+	ErrFairPartialDeck      = errors.New("Partial decks can not be fair")
+	ErrDeckClosed           = errors.New("Deck closed")
+	ErrDeckNotFair          = errors.New("Deck is not fair")
+	ErrDeckNotFinished      = errors.New("Deck has cards left and is not closed")
+	ErrInvalidShuffleMethod = errors.New("Invalid shuffle method")
+	ErrFairDeckShuffle      = errors.New("Fair decks can not be reordered")
+	ErrFairDeckSeeded       = errors.New("Fair deck already has a client seed")
+	ErrFairDeckNotSeeded    = errors.New("Fair deck has no client seed yet")
+	ErrInvalidClientSeed    = errors.New("Invalid client seed")
+	ErrCardNotDrawn         = errors.New("Card not drawn from the deck")
+	ErrInvalidPosition      = errors.New("Invalid position")
+	ErrCardNotFound         = errors.New("Card not found in the deck")
+	ErrInvalidPileName      = errors.New("Invalid pile name")
+	ErrPileNotFound         = errors.New("Pile not found")
+	ErrPileExists           = errors.New("Pile already exists")
+	ErrTooManyPiles         = errors.New("Too many piles")
+	ErrInvalidPlayer        = errors.New("Invalid player")
+	ErrNotDeckOwner         = errors.New("Only the deck owner can do this")
+	ErrNotPileOwner         = errors.New("Only the owner of both piles can move their cards")
+	ErrInvalidIdempotency   = errors.New("Invalid idempotency key")
+	ErrIdempotencyReused    = errors.New("Idempotency key reused with different parameters")
+	ErrIdempotencyInUse     = errors.New("Idempotency key in use by a request in progress")
+	ErrIdempotencyFull      = errors.New("Too many idempotency keys in use for the deck")
+	ErrVersionMismatch      = errors.New("Deck changed since the version expected")
+	ErrInvalidTag           = errors.New("Invalid tag")
+	ErrInvalidSort          = errors.New("Invalid sort order")
+	ErrInvalidCursor        = errors.New("Invalid cursor")
+	ErrInvalidPageSize      = errors.New("Invalid page size")
+	ErrGeneral              = errors.New("General error")
 )
 
+// Options used to create a new deck
+type DeckOptions struct {
+	// Randomly shuffles the card set
+	Shuffle bool
+	// If not empty only the selected cards are used
+	Codes []string
+	// Rejects Codes with the same card more than once
+	RejectDuplicates bool
+	// Name of the deck composition, standard if empty
+	Composition string
+	// Amount of jokers added to the deck, from 0 to MaxJokers
+	Jokers int
+	// Amount of decks in the shoe, from 1 to MaxDecks. 0 means 1
+	Decks int
+	// Fraction of the cards dealt before the cut card is
+	// reached, from 0 to 1. 0 means there is no cut card
+	Penetration float64
+	// Seed of the shuffle. The same seed always produces the
+	// same order. If nil the controller Shuffler is used. It
+	// requires Shuffle or ShuffleMethod, and can't be used with
+	// Codes or Fair, as those decks are not shuffled with it
+	Seed *int64
+	// Shuffle plan made of hand shuffle methods (see
+	// ParseShufflePlan), i.e. "riffle*7,cut". Implies Shuffle
+	ShuffleMethod string
+	// Creates a provably fair deck with a new server seed. Its
+	// cards are shuffled once the client seed is supplied, after
+	// publishing the server seed hash (see SeedDeck)
+	Fair bool
+	// Player that deals the deck, who is the only one that sees
+	// every card. If empty every card can be seen by anybody
+	Owner string
+	// Tags to find the deck when listing decks, up to MaxTags
+	Tags []string
+	// Hides the order of the cards left from everybody but the
+	// Owner, so that players can't learn what comes next
+	HideOrder bool
+}
+
+// Options used to open a deck
+type OpenOptions struct {
+	// Player viewing the deck. Only the cards that the player
+	// can see are shown (see ProjectDeck)
+	Viewer string
+}
+
+// Options used to draw cards from a deck
+type DrawOptions struct {
+	// Amount of cards drawn. With Codes it can be 0
+	Amount int
+	// Position the cards are drawn from: top (default),
+	// bottom or random
+	From string
+	// If not empty the cards with these codes are drawn,
+	// instead of drawing from a position
+	Codes []string
+	// Player drawing the cards, who must be the owner of the deck
+	Viewer string
+}
+
 // Controller type contains the bussiness logic
 type DeckController struct {
 	deckRepo data.DeckRepository
+	// Shuffles the decks created without seed
+	shuffler Shuffler
+	// Random numbers of the shuffle plans without seed
+	source RandomSource
+	// Current time, for the expiration of stored responses
+	// and the creation time of the decks
+	now func() time.Time
+	// Idempotency keys of the requests in progress, shared
+	// with the controllers of IfVersion
+	inProgress *sync.Map
 }
 
-// Controller constructor injects DeckRepository dependency
+// Controller constructor injects DeckRepository dependency.
+// Decks are shuffled with a cryptographically secure source
 func NewDeckController(repository data.DeckRepository) *DeckController {
+	return NewDeckControllerWithShuffler(repository, FisherYatesShuffler{Source: CryptoSource{}})
+}
+
+// Controller constructor injects DeckRepository and the
+// Shuffler used for the decks created without seed
+func NewDeckControllerWithShuffler(repository data.DeckRepository, shuffler Shuffler) *DeckController {
 
 	controller := &DeckController{
-		deckRepo: repository,
+		deckRepo:   repository,
+		shuffler:   shuffler,
+		source:     CryptoSource{},
+		now:        time.Now,
+		inProgress: &sync.Map{},
 	}
 
 	return controller
 }
 
-// Returns the card's code from its suit and value
+// Controller constructor injects DeckRepository and a
+// deterministic random source, so that the same source
+// produces the same sequence of shuffled decks
+func NewDeckControllerWithSource(repository data.DeckRepository, source rand.Source) *DeckController {
+
+	random := &lockedSource{source: rand.New(source)}
+
+	controller := NewDeckControllerWithShuffler(repository, FisherYatesShuffler{Source: random})
+	controller.source = random
+
+	return controller
+}
+
+// Returns the card's canonical code from its suit and value
 func (c *DeckController) GenerateCardsCode(suit data.CardSuit, value data.CardValue) string {
-	return string(suit.String()[0]) + string(value.String()[0])
+	return data.CardCode(value, suit)
 }
 
-// Generates a cards set without shuffle
+// Generates a standard cards set without shuffle
 func (c *DeckController) GetDefaultCardSet() []data.Card {
 
-	result := make([]data.Card, data.MaxCards)
-	index := 0
+	composition, _ := GetComposition(CompositionStandard)
 
-	for s := data.Spades; s <= data.Hearts; s++ {
-		for v := data.Ace; v <= data.King; v++ {
+	return c.GetCompositionCardSet(composition, 0)
+}
 
-			card := data.Card{
-				Value: v,
-				Suit:  s,
-				Code:  c.GenerateCardsCode(s, v),
-			}
+// Generates the cards set of a composition without shuffle. The
+// cards are sorted by suit and value, and jokers are placed at
+// the end of the set, red first
+func (c *DeckController) GetCompositionCardSet(composition DeckComposition, jokers int) []data.Card {
+	return c.GetShoeCardSet(composition, jokers, 1)
+}
 
-			if index < data.MaxCards {
-				result[index] = card
-				index++
-			}
-		}
+// Generates a shoe with as many composition card sets as decks,
+// one after the other. Every card gets a different instance
+// number following the order of the shoe
+func (c *DeckController) GetShoeCardSet(composition DeckComposition, jokers int, decks int) []data.Card {
+
+	result := make([]data.Card, 0, (composition.Size()+jokers)*decks)
+
+	for d := 0; d < decks; d++ {
+		result = append(result, c.getDeckCardSet(composition, jokers)...)
 	}
 
+	numberInstances(result)
+
 	return result
 }
 
-// Generates a random int slice with the desired length
-func (c *DeckController) getRandomIntArray(length int) []int {
+// Generates the cards of one single deck of the composition
+func (c *DeckController) getDeckCardSet(composition DeckComposition, jokers int) []data.Card {
+
+	result := make([]data.Card, 0, composition.Size()+jokers)
+
+	for i := 0; i < composition.Copies; i++ {
+		for s := data.Spades; s <= data.Hearts; s++ {
+			for _, v := range composition.Values {
 
-	a := make([]int, length)
+				card := data.Card{
+					Value: v,
+					Suit:  s,
+					Code:  c.GenerateCardsCode(s, v),
+				}
 
-	// Initialise array
-	for i := range a {
-		a[i] = i
+				result = append(result, card)
+			}
+		}
 	}
 
-	// Fisher–Yates shuffle
-	rand.Seed(time.Now().UnixNano())
-	for i := len(a) - 1; i > 0; i-- {
-		j := rand.Intn(i + 1)
-		a[i], a[j] = a[j], a[i]
+	for _, s := range []data.CardSuit{data.Red, data.Black}[:jokers] {
+		result = append(result, data.Card{
+			Value: data.Joker,
+			Suit:  s,
+			Code:  c.GenerateCardsCode(s, data.Joker),
+		})
 	}
 
-	return a
+	return result
+}
+
+// Sets the instance number of each card from its position
+func numberInstances(cards []data.Card) {
+	for i := range cards {
+		cards[i].Instance = i + 1
+	}
 }
 
-// Generates a randomly shuffled cards set
+// Generates a randomly shuffled standard cards set
 func (c *DeckController) GetShuffledCardSet() []data.Card {
+	return c.shuffleCards(c.GetDefaultCardSet())
+}
+
+// Returns a randomly shuffled copy of a cards set
+func (c *DeckController) shuffleCards(cards []data.Card) []data.Card {
+	return permuteCards(cards, c.shuffler.Permutation(len(cards)))
+}
 
-	// Creates a default cards set
-	defaultCards := c.GetDefaultCardSet()
+// Returns the Shuffler of a plan, or the Fisher–Yates shuffle
+// if plan is nil. With a seed the Shuffler always produces the
+// same permutations, otherwise it uses the controller sources
+func (c *DeckController) newShuffler(plan ShufflePlan, seed *int64) Shuffler {
 
-	random := c.getRandomIntArray(len(defaultCards))
+	var source RandomSource = c.source
+	if seed != nil {
+		source = rand.New(rand.NewSource(*seed))
+	}
 
-	shuffledCards := make([]data.Card, len(defaultCards))
+	if plan != nil {
+		return PlanShuffler{Plan: plan, Source: source}
+	} else if seed != nil {
+		return FisherYatesShuffler{Source: source}
+	}
+
+	return c.shuffler
+}
+
+// Returns a copy of a cards set where position i holds the
+// card at position permutation[i]
+func permuteCards(cards []data.Card, permutation []int) []data.Card {
+
+	shuffledCards := make([]data.Card, len(cards))
 
 	// Re-arrange cards
-	for i, v := range random {
-		shuffledCards[i] = defaultCards[v]
+	for i, v := range permutation {
+		shuffledCards[i] = cards[v]
 	}
 
 	return shuffledCards
 }
 
 // Generates as many cards as codes are passed as
-// argument
+// argument. Any code accepted by data.ParseCardCode is
+// valid, and the cards are returned with their canonical code
 func (c *DeckController) GetCardSetByCodes(codes []string) ([]data.Card, error) {
+	return c.getCardSetByCodes(codes, c.GetDefaultCardSet(), false)
+}
 
-	defaultCards := c.GetDefaultCardSet()
-	result := make([]data.Card, len(codes))
-	exists := false
-
-	for ic, vc := range codes {
-		exists = false
-		for _, vd := range defaultCards {
-			if vc == vd.Code {
-				result[ic] = vd
-				exists = true
-				break
-			}
+// Generates as many cards as codes are passed as argument,
+// only accepting the cards in the available set. The error
+// has every invalid code and, if rejectDuplicates is true,
+// every code of a card that was already selected
+func (c *DeckController) getCardSetByCodes(codes []string, available []data.Card, rejectDuplicates bool) ([]data.Card, error) {
+
+	result := make([]data.Card, 0, len(codes))
+	selected := map[string]bool{}
+	var invalid []string
+	var duplicates []string
+
+	for _, vc := range codes {
+		card, found := findCard(vc, available)
+
+		if !found {
+			invalid = append(invalid, vc)
+			continue
 		}
 
-		if !exists {
-			break
+		if selected[card.Code] && rejectDuplicates {
+			duplicates = append(duplicates, vc)
 		}
+
+		selected[card.Code] = true
+		result = append(result, card)
+	}
+
+	if len(invalid) > 0 {
+		return nil, &CardCodeError{Err: ErrInvalidCardCode, Codes: invalid, Duplicates: duplicates}
 	}
 
-	if !exists {
-		return result, ErrInvalidCardCode
+	if len(duplicates) > 0 {
+		return nil, &CardCodeError{Err: ErrDuplicateCardCode, Duplicates: duplicates}
 	}
 
 	return result, nil
 }
 
+// Returns the card of the available set with the code, which
+// can be any code accepted by data.ParseCardCode
+func findCard(code string, available []data.Card) (data.Card, bool) {
+
+	card, err := data.ParseCardCode(code)
+	if err != nil {
+		return data.Card{}, false
+	}
+
+	for _, v := range available {
+		if card.Code == v.Code {
+			return v, true
+		}
+	}
+
+	return data.Card{}, false
+}
+
 // Creates a deck with the desired cards
 // If shuffle is true the card set is randomly shuffled
 // If codes has value, only the selected cards are used
 func (c *DeckController) CreateDeck(shuffled bool, codes []string) (*data.Deck, error) {
+	return c.CreateDeckWithOptions(DeckOptions{Shuffle: shuffled, Codes: codes})
+}
+
+// Creates a deck from the composition and amount of jokers
+// in the options. If codes has value, only the selected cards
+// of the composition are used and the deck is never shuffled
+func (c *DeckController) CreateDeckWithOptions(options DeckOptions) (*data.Deck, error) {
 
 	var cardSet []data.Card
-	var err error
-	doShuffle := shuffled
+	var seed int64
+	var seeded bool
+	var serverSeed string
+	var plan ShufflePlan
+	doShuffle := options.Shuffle || options.Fair || options.ShuffleMethod != ""
+
+	composition, err := GetComposition(options.Composition)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Jokers < 0 || options.Jokers > MaxJokers {
+		return nil, ErrInvalidJokers
+	}
+
+	decks := options.Decks
+	if decks == 0 {
+		decks = 1
+	}
+
+	if decks < 0 || decks > MaxDecks {
+		return nil, ErrInvalidDecks
+	}
+
+	if options.Penetration < 0 || options.Penetration >= 1 {
+		return nil, ErrInvalidPenetration
+	}
 
-	if codes != nil && len(codes) > 0 {
+	if err := checkPlayer(options.Owner); err != nil {
+		return nil, err
+	}
+
+	tags, err := checkTags(options.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.ShuffleMethod != "" {
+		// The fair shuffle can only be verified on its own
+		if options.Fair {
+			return nil, ErrInvalidShuffleMethod
+		}
+
+		plan, err = ParseShufflePlan(options.ShuffleMethod)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A seed that would be ignored is rejected, so that nobody
+	// expects to reproduce a deck that was not shuffled with it
+	if options.Seed != nil && (!options.Shuffle && options.ShuffleMethod == "" || len(options.Codes) > 0 || options.Fair) {
+		return nil, ErrSeedWithoutShuffle
+	}
+
+	compositionCards := c.GetShoeCardSet(composition, options.Jokers, decks)
+
+	if len(options.Codes) > 0 && options.Fair {
+		return nil, ErrFairPartialDeck
+	}
+
+	if len(options.Codes) > 0 {
+		doShuffle = false
+		cardSet, err = c.getCardSetByCodes(options.Codes, compositionCards, options.RejectDuplicates)
+		// Repeated codes would share the instance otherwise
+		numberInstances(cardSet)
+	} else if options.Fair {
+		// Shuffled by SeedDeck, from the initial order
 		doShuffle = false
-		cardSet, err = c.GetCardSetByCodes(codes)
-	} else if shuffled {
-		cardSet = c.GetShuffledCardSet()
+		cardSet = compositionCards
+		serverSeed, err = newServerSeed()
+	} else if doShuffle {
+		if options.Seed != nil {
+			seed = *options.Seed
+			seeded = true
+		}
+		shuffler := c.newShuffler(plan, options.Seed)
+		cardSet = permuteCards(compositionCards, shuffler.Permutation(len(compositionCards)))
 	} else {
-		cardSet = c.GetDefaultCardSet()
+		cardSet = compositionCards
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	// The cut card is placed so that Penetration of the cards,
+	// rounded, are dealt before reaching it. At least one card is
+	// dealt before it and one is left after it, otherwise the deck
+	// would need a reshuffle from the start or never
+	cutCard := 0
+	if options.Penetration > 0 {
+		if len(cardSet) < 2 {
+			return nil, ErrInvalidPenetration
+		}
+
+		dealt := int(math.Round(options.Penetration * float64(len(cardSet))))
+		if dealt < 1 {
+			dealt = 1
+		} else if dealt > len(cardSet)-1 {
+			dealt = len(cardSet) - 1
+		}
+
+		cutCard = len(cardSet) - dealt
+	}
+
 	deck := data.Deck{
-		Id:        uuid.New(),
-		Shuffled:  doShuffle,
-		Remaining: len(cardSet),
-		Cards:     cardSet,
+		Id:          uuid.New(),
+		Composition: composition.Name,
+		Jokers:      options.Jokers,
+		Decks:       decks,
+		Shuffled:    doShuffle,
+		Remaining:   len(cardSet),
+		CutCard:     cutCard,
+		Seeded:      seeded,
+		Seed:        seed,
+		Fair:        options.Fair,
+		ServerSeed:  serverSeed,
+		HideOrder:   options.HideOrder,
+		Owner:       options.Owner,
+		CreatedAt:   c.now().UTC(),
+		Tags:        tags,
+		Cards:       cardSet,
+		// Stored as the first version
+		Version: 1,
 	}
 
 	// Adds the newly create deck to de Repository
-	c.deckRepo.Add(deck)
+	if err := c.deckRepo.Add(deck); err != nil {
+		return nil, internalError(err)
+	}
 
 	return &deck, nil
 }
@@ -175,7 +501,69 @@ func (c *DeckController) OpenDeck(uuid uuid.UUID) (*data.Deck, error) {
 	deck, err := c.deckRepo.GetDeckById(uuid)
 
 	if err != nil {
-		return nil, ErrDeckNotFound
+		return nil, repositoryError(err, nil)
+	}
+
+	return deck, nil
+}
+
+// Retrieves a deck like OpenDeck, as seen by the Viewer. If
+// the deck hides its order from the viewer the cards are sorted
+// by instance, which is the order of the composition, so that
+// the deck shows which cards are left but not their order
+func (c *DeckController) OpenDeckWithOptions(uuid uuid.UUID, options OpenOptions) (*data.Deck, error) {
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if !canSeeOrder(deck, options.Viewer) {
+		sort.Slice(deck.Cards, func(i, j int) bool {
+			return deck.Cards[i].Instance < deck.Cards[j].Instance
+		})
+	}
+
+	return ProjectDeck(deck, options.Viewer), nil
+}
+
+// Returns amount cards from the top of the deck without
+// removing them. Only the owner of the deck can see them
+func (c *DeckController) PeekCards(uuid uuid.UUID, amount int, viewer string) ([]data.Card, error) {
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if ViewerRole(deck, viewer) != RoleOwner || !canSeeOrder(deck, viewer) {
+		return nil, ErrNotDeckOwner
+	}
+
+	if err := checkSeeded(deck); err != nil {
+		return nil, err
+	}
+
+	cards, err := c.deckRepo.PeekCards(uuid, amount)
+	if err != nil {
+		return nil, c.withRemaining(uuid, amount, repositoryError(err, ErrInvalidAmount))
+	}
+
+	return cards, nil
+}
+
+// Burns amount cards from the top of the deck, moving them
+// to its burn pile instead of dealing them. Only the owner of
+// the deck can burn them
+func (c *DeckController) BurnCards(uuid uuid.UUID, amount int, viewer string) (*data.Deck, error) {
+
+	if err := c.checkDealer(uuid, viewer); err != nil {
+		return nil, err
+	}
+
+	deck, err := c.deckRepo.BurnCards(uuid, amount)
+	if err != nil {
+		return nil, c.withRemaining(uuid, amount, repositoryError(err, ErrInvalidAmount))
 	}
 
 	return deck, nil
@@ -184,19 +572,117 @@ func (c *DeckController) OpenDeck(uuid uuid.UUID) (*data.Deck, error) {
 // Draws amount cards from the deck, removing them from the deck
 // and updating the remaining value
 func (c *DeckController) DrawCards(uuid uuid.UUID, amount int) ([]data.Card, error) {
+	return c.DrawCardsWithOptions(uuid, DrawOptions{Amount: amount})
+}
 
-	cards, err := c.deckRepo.DrawCardsFromDeck(uuid, amount)
+// Draws cards from the deck as selected in the options. Either
+// all the cards are drawn or none of them. Only the owner of
+// the deck can draw them, since they are returned face up
+func (c *DeckController) DrawCardsWithOptions(uuid uuid.UUID, options DrawOptions) ([]data.Card, error) {
 
-	if err != nil {
-		switch err {
-		case data.ErrNotFound:
-			return nil, ErrDeckNotFound
-		case data.ErrInvalidParameters:
+	var cards []data.Card
+
+	if err := c.checkDealer(uuid, options.Viewer); err != nil {
+		return nil, err
+	}
+
+	if len(options.Codes) > 0 {
+		if options.From != "" {
+			return nil, ErrInvalidPosition
+		}
+
+		if options.Amount != 0 && options.Amount != len(options.Codes) {
 			return nil, ErrInvalidAmount
-		case data.ErrTruncate:
-			return nil, ErrNotEnoughCards
 		}
+
+		codes, err := canonicalCodes(options.Codes)
+		if err != nil {
+			return nil, err
+		}
+
+		cards, err = c.deckRepo.DrawCardsByCode(uuid, codes)
+		if err != nil {
+			return nil, repositoryError(err, ErrInvalidAmount)
+		}
+
+		return cards, nil
+	}
+
+	position, err := parsePosition(options.From)
+	if err != nil {
+		return nil, err
+	}
+
+	cards, err = c.deckRepo.DrawCardsAt(uuid, options.Amount, position, c.source.Intn)
+	if err != nil {
+		return nil, c.withRemaining(uuid, options.Amount, repositoryError(err, ErrInvalidAmount))
 	}
 
 	return cards, nil
 }
+
+// Closes a deck so that no more cards can be drawn from it.
+// Fair decks can be revealed once closed, so only the owner of
+// the deck can close it
+func (c *DeckController) CloseDeck(uuid uuid.UUID, viewer string) (*data.Deck, error) {
+
+	if err := c.checkOwner(uuid, viewer); err != nil {
+		return nil, err
+	}
+
+	deck, err := c.deckRepo.CloseDeck(uuid)
+
+	if err != nil {
+		return nil, repositoryError(err, nil)
+	}
+
+	return deck, nil
+}
+
+// Returns ErrNotDeckOwner if the viewer is not the owner of
+// the deck
+func (c *DeckController) checkOwner(uuid uuid.UUID, viewer string) error {
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return err
+	}
+
+	if ViewerRole(deck, viewer) != RoleOwner {
+		return ErrNotDeckOwner
+	}
+
+	return nil
+}
+
+// Returns an error if the viewer can't deal the cards of a
+// deck, because it is not its owner or the deck is not shuffled
+// yet (see checkSeeded)
+func (c *DeckController) checkDealer(uuid uuid.UUID, viewer string) error {
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return err
+	}
+
+	if ViewerRole(deck, viewer) != RoleOwner {
+		return ErrNotDeckOwner
+	}
+
+	return checkSeeded(deck)
+}
+
+// Discards a deck with all its cards and piles. Only the
+// owner of the deck can delete it
+func (c *DeckController) DeleteDeck(uuid uuid.UUID, viewer string) error {
+
+	if err := c.checkOwner(uuid, viewer); err != nil {
+		return err
+	}
+
+	if err := c.deckRepo.DeleteDeck(uuid); err != nil {
+		return repositoryError(err, nil)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+// Author: Ferran Balaguer
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"test/cardsgame/data"
+
+	"github.com/google/uuid"
+)
+
+// Wraps an unexpected error, i.e. a storage failure, so that it
+// matches ErrGeneral and keeps the cause for the logs
+func internalError(err error) error {
+	return fmt.Errorf("%w: %w", ErrGeneral, err)
+}
+
+// Translates the errors of the repository operations into
+// controller errors. invalid is returned for invalid parameters,
+// which are an internal error if it is nil. Unknown errors are
+// internal errors
+func repositoryError(err error, invalid error) error {
+
+	switch {
+	case errors.Is(err, data.ErrNotFound):
+		return ErrDeckNotFound
+	case errors.Is(err, data.ErrClosed):
+		return ErrDeckClosed
+	case errors.Is(err, data.ErrTruncate):
+		return ErrNotEnoughCards
+	case errors.Is(err, data.ErrNotDrawn):
+		return ErrCardNotDrawn
+	case errors.Is(err, data.ErrCardNotFound):
+		return ErrCardNotFound
+	case errors.Is(err, data.ErrPileNotFound):
+		return ErrPileNotFound
+	case errors.Is(err, data.ErrPileExists):
+		return ErrPileExists
+	case errors.Is(err, data.ErrVersionMismatch):
+		return ErrVersionMismatch
+	case errors.Is(err, data.ErrResponsesFull):
+		return ErrIdempotencyFull
+	case errors.Is(err, data.ErrSeeded):
+		return ErrFairDeckSeeded
+	case errors.Is(err, data.ErrInvalidParameters) && invalid != nil:
+		return invalid
+	}
+
+	return internalError(err)
+}
+
+// Error about card codes, i.e. ErrInvalidCardCode, with the
+// codes that caused it
+type CardCodeError struct {
+	Err error
+	// Invalid codes
+	Codes []string
+	// Codes of cards that were already selected
+	Duplicates []string
+}
+
+func (e *CardCodeError) Error() string {
+
+	message := e.Err.Error()
+
+	if len(e.Codes) > 0 {
+		message += ": invalid " + strings.Join(e.Codes, ",")
+	}
+
+	if len(e.Duplicates) > 0 {
+		message += ": duplicate " + strings.Join(e.Duplicates, ",")
+	}
+
+	return message
+}
+
+func (e *CardCodeError) Unwrap() error {
+	return e.Err
+}
+
+// ErrNotEnoughCards with the amount of cards requested and
+// the amount left in the deck
+type NotEnoughCardsError struct {
+	Requested int
+	Remaining int
+}
+
+func (e *NotEnoughCardsError) Error() string {
+	return fmt.Sprintf("%v: %d requested, %d left", ErrNotEnoughCards, e.Requested, e.Remaining)
+}
+
+func (e *NotEnoughCardsError) Unwrap() error {
+	return ErrNotEnoughCards
+}
+
+// Adds the amount of cards left to ErrNotEnoughCards errors, so
+// that clients know how many cards they can still request
+func (c *DeckController) withRemaining(uuid uuid.UUID, requested int, err error) error {
+
+	if !errors.Is(err, ErrNotEnoughCards) {
+		return err
+	}
+
+	deck, openErr := c.OpenDeck(uuid)
+	if openErr != nil {
+		return err
+	}
+
+	return &NotEnoughCardsError{Requested: requested, Remaining: deck.Remaining}
+}
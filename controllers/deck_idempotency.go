@@ -0,0 +1,82 @@
+// Author: Ferran Balaguer
+
+package controllers
+
+import (
+	"errors"
+	"regexp"
+	"test/cardsgame/data"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Time the responses to requests with an idempotency key are
+// replayed for their retries
+const IdempotencyWindow = 24 * time.Hour
+
+// Keys are up to 255 visible ASCII characters, i.e. a UUID
+var idempotencyKey = regexp.MustCompile(`^[!-~]{1,255}$`)
+
+// Replaces the clock used to expire the stored responses
+// and to date the new decks
+func (c *DeckController) SetClock(now func() time.Time) {
+	c.now = now
+}
+
+// Starts a request made with an idempotency key. Fingerprint
+// identifies the parameters of the request. If a response to
+// the same request was stored within IdempotencyWindow it is
+// returned, and it must be replayed instead of running the
+// request. Otherwise the key is reserved until EndRequest, so
+// that concurrent retries get ErrIdempotencyInUse
+func (c *DeckController) BeginRequest(key string, fingerprint string) (*data.StoredResponse, error) {
+
+	if !idempotencyKey.MatchString(key) {
+		return nil, ErrInvalidIdempotency
+	}
+
+	if _, loaded := c.inProgress.LoadOrStore(key, true); loaded {
+		return nil, ErrIdempotencyInUse
+	}
+
+	response, err := c.deckRepo.GetResponse(key)
+
+	if errors.Is(err, data.ErrNotFound) || (err == nil && !c.now().Before(response.ExpiresAt)) {
+		return nil, nil
+	}
+
+	// The request is not run, so the key is released
+	c.inProgress.Delete(key)
+
+	if err != nil {
+		return nil, repositoryError(err, nil)
+	}
+
+	if response.Fingerprint != fingerprint {
+		return nil, ErrIdempotencyReused
+	}
+
+	return response, nil
+}
+
+// Stores the response to a request started with BeginRequest
+// with the deck it created or changed, to be replayed until
+// IdempotencyWindow has passed
+func (c *DeckController) SaveResponse(uuid uuid.UUID, response data.StoredResponse) error {
+
+	response.CreatedAt = c.now()
+	response.ExpiresAt = response.CreatedAt.Add(IdempotencyWindow)
+
+	if err := c.deckRepo.SaveResponse(uuid, response); err != nil {
+		return repositoryError(err, ErrInvalidIdempotency)
+	}
+
+	return nil
+}
+
+// Releases the key of a request started with BeginRequest
+// that was run, once its response is stored or discarded
+func (c *DeckController) EndRequest(key string) {
+	c.inProgress.Delete(key)
+}
@@ -0,0 +1,197 @@
+// Author: Ferran Balaguer
+
+package controllers
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strconv"
+	"strings"
+	"test/cardsgame/data"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Amount of decks listed per page by default and at most
+const (
+	DefaultPageSize int = 20
+	MaxPageSize     int = 100
+)
+
+// Maximum amount of tags of a deck
+const MaxTags int = 16
+
+// Tags are short and safe to use in a query parameter
+var deckTag = regexp.MustCompile(`^[A-Za-z0-9_.:-]{1,32}$`)
+
+// Orders of the listed decks by name. A leading "-" reverses them
+var deckSorts = map[string]data.DeckSort{
+	"created_at": data.SortByCreatedAt,
+	"remaining":  data.SortByRemaining,
+}
+
+// Options used to list decks. Zero values don't filter
+type ListOptions struct {
+	Shuffled     *bool
+	MinRemaining *int
+	MaxRemaining *int
+	// Only the decks created after this time
+	CreatedAfter time.Time
+	Owner        string
+	Tag          string
+	// Order of the decks, "created_at" (default) or "remaining",
+	// reversed with a leading "-"
+	Sort string
+	// Amount of decks of the page, from 1 to MaxPageSize.
+	// 0 means DefaultPageSize
+	Limit int
+	// Cursor returned with the previous page, empty for the
+	// first one. It is only valid with the same Sort
+	Cursor string
+}
+
+// Page of listed decks. Next is the cursor of the following
+// page, empty if this is the last one
+type DeckPage struct {
+	Decks []data.Deck
+	Next  string
+}
+
+// Returns the tags without the repeated ones, or ErrInvalidTag
+func checkTags(tags []string) ([]string, error) {
+
+	var result []string
+	seen := map[string]bool{}
+
+	for _, v := range tags {
+		if !deckTag.MatchString(v) {
+			return nil, ErrInvalidTag
+		}
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+
+	if len(result) > MaxTags {
+		return nil, ErrInvalidTag
+	}
+
+	return result, nil
+}
+
+// Lists a page of the decks that match the options, without
+// their cards
+func (c *DeckController) ListDecks(options ListOptions) (*DeckPage, error) {
+
+	limit := options.Limit
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+
+	if limit < 0 || limit > MaxPageSize {
+		return nil, ErrInvalidPageSize
+	}
+
+	if options.Tag != "" && !deckTag.MatchString(options.Tag) {
+		return nil, ErrInvalidTag
+	}
+
+	if err := checkPlayer(options.Owner); err != nil {
+		return nil, err
+	}
+
+	if options.Sort == "" {
+		options.Sort = "created_at"
+	}
+
+	sort, ok := deckSorts[strings.TrimPrefix(options.Sort, "-")]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	query := data.DeckQuery{
+		Shuffled:     options.Shuffled,
+		MinRemaining: options.MinRemaining,
+		MaxRemaining: options.MaxRemaining,
+		CreatedAfter: options.CreatedAfter,
+		Owner:        options.Owner,
+		Tag:          options.Tag,
+		Sort:         sort,
+		Descending:   strings.HasPrefix(options.Sort, "-"),
+		// One more deck tells if there is another page
+		Limit: limit + 1,
+	}
+
+	if options.Cursor != "" {
+		cursor, err := decodeCursor(options.Sort, options.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query.After = cursor
+	}
+
+	decks, err := c.deckRepo.ListDecks(query)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	page := &DeckPage{Decks: decks}
+
+	if len(decks) > limit {
+		page.Decks = decks[:limit]
+		page.Next = encodeCursor(options.Sort, data.CursorOf(&decks[limit-1]))
+	}
+
+	return page, nil
+}
+
+// Encodes the position of a deck in the order of sort as an
+// opaque cursor, made of the order, the value and the deck id
+func encodeCursor(sort string, cursor data.DeckCursor) string {
+
+	value := int64(cursor.Remaining)
+	if strings.TrimPrefix(sort, "-") == "created_at" {
+		value = 0
+		if !cursor.CreatedAt.IsZero() {
+			value = cursor.CreatedAt.UnixNano()
+		}
+	}
+
+	raw := sort + "|" + strconv.FormatInt(value, 10) + "|" + cursor.Id.String()
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decodes a cursor made by encodeCursor for the same order,
+// or returns ErrInvalidCursor
+func decodeCursor(sort string, encoded string) (*data.DeckCursor, error) {
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 || parts[0] != sort {
+		return nil, ErrInvalidCursor
+	}
+
+	value, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := uuid.Parse(parts[2])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &data.DeckCursor{Id: id, Remaining: int(value)}
+	if value != 0 && strings.TrimPrefix(sort, "-") == "created_at" {
+		cursor.CreatedAt = time.Unix(0, value)
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,220 @@
+// Author: Ferran Balaguer
+
+package controllers
+
+import (
+	"regexp"
+	"test/cardsgame/data"
+
+	"github.com/google/uuid"
+)
+
+// Maximum amount of piles of a deck
+const MaxPiles int = 32
+
+// Pile names are short and safe to use in a URL
+var pileName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Options used to create a pile
+type PileOptions struct {
+	// Name of the pile, made of letters, digits, "_" and "-"
+	Name string
+	// Player holding the pile, if any
+	Owner string
+	// The cards of the pile can be seen by every player
+	FaceUp bool
+	// Player creating the pile, who must be the owner of the deck
+	Viewer string
+}
+
+// Adds an empty pile to a deck, i.e. a hand or a discard pile.
+// Names are made of letters, digits, "_" and "-"
+func (c *DeckController) CreatePile(uuid uuid.UUID, name string) (*data.Deck, error) {
+	return c.CreatePileWithOptions(uuid, PileOptions{Name: name})
+}
+
+// Adds an empty pile to a deck as selected in the options.
+// Only the owner of the deck can add piles, since holding one
+// makes a player of its owner
+func (c *DeckController) CreatePileWithOptions(uuid uuid.UUID, options PileOptions) (*data.Deck, error) {
+
+	if !pileName.MatchString(options.Name) {
+		return nil, ErrInvalidPileName
+	}
+
+	if err := checkPlayer(options.Owner); err != nil {
+		return nil, err
+	}
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if ViewerRole(deck, options.Viewer) != RoleOwner {
+		return nil, ErrNotDeckOwner
+	}
+
+	if len(deck.Piles) >= MaxPiles {
+		return nil, ErrTooManyPiles
+	}
+
+	pile := data.Pile{
+		Name:   options.Name,
+		Owner:  options.Owner,
+		FaceUp: options.FaceUp,
+	}
+
+	deck, err = c.deckRepo.CreatePile(uuid, pile)
+	if err != nil {
+		return nil, repositoryError(err, ErrInvalidPileName)
+	}
+
+	return deck, nil
+}
+
+// Returns a pile of a deck as seen by the viewer. The cards the
+// viewer can't see are replaced by the zero data.Card
+func (c *DeckController) GetPile(uuid uuid.UUID, name string, viewer string) (*data.Pile, error) {
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	pile := ProjectDeck(deck, viewer).GetPile(name)
+	if pile == nil {
+		return nil, ErrPileNotFound
+	}
+
+	return pile, nil
+}
+
+// Moves amount cards from a deck to one of its piles. from is
+// the position of the deck they are taken from, top if empty.
+// Only the owner of the deck can deal them, like drawing
+func (c *DeckController) DrawCardsToPile(uuid uuid.UUID, name string, amount int, from string, viewer string) ([]data.Card, error) {
+
+	position, err := parsePosition(from)
+	if err != nil {
+		return nil, err
+	}
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if ViewerRole(deck, viewer) != RoleOwner {
+		return nil, ErrNotDeckOwner
+	}
+
+	if err := checkSeeded(deck); err != nil {
+		return nil, err
+	}
+
+	if deck.GetPile(name) == nil {
+		return nil, ErrPileNotFound
+	}
+
+	cards, err := c.deckRepo.DrawCardsToPile(uuid, name, amount, position, c.source.Intn)
+	if err != nil {
+		return nil, c.withRemaining(uuid, amount, repositoryError(err, ErrInvalidAmount))
+	}
+
+	return cards, nil
+}
+
+// Moves cards from one pile of a deck to another. If codes is
+// empty every card of the pile is moved. Only the owner of the
+// deck, or the player holding both piles, can move them
+func (c *DeckController) MovePileCards(uuid uuid.UUID, from string, to string, codes []string, viewer string) (*data.Deck, error) {
+
+	canonical, err := canonicalCodes(codes)
+	if err != nil {
+		return nil, err
+	}
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if ViewerRole(deck, viewer) != RoleOwner {
+		source, target := deck.GetPile(from), deck.GetPile(to)
+		if source == nil || target == nil {
+			return nil, ErrPileNotFound
+		}
+		if source.Owner != viewer || target.Owner != viewer {
+			return nil, ErrNotPileOwner
+		}
+	}
+
+	deck, err = c.deckRepo.MovePileCards(uuid, from, to, canonical)
+	if err != nil {
+		return nil, repositoryError(err, ErrInvalidPileName)
+	}
+
+	return deck, nil
+}
+
+// Shuffles the cards of a pile with a shuffle plan (see
+// ParseShufflePlan), or with the Fisher–Yates shuffle if
+// method is empty. Only the owner of the deck, or the player
+// holding the pile, can shuffle it
+func (c *DeckController) ShufflePile(uuid uuid.UUID, name string, method string, viewer string) (*data.Deck, error) {
+
+	var plan ShufflePlan
+
+	if method != "" {
+		var err error
+		plan, err = ParseShufflePlan(method)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if ViewerRole(deck, viewer) != RoleOwner {
+		pile := deck.GetPile(name)
+		if pile == nil {
+			return nil, ErrPileNotFound
+		}
+		if pile.Owner != viewer {
+			return nil, ErrNotPileOwner
+		}
+	}
+
+	deck, err = c.deckRepo.ShufflePile(uuid, name, c.newShuffler(plan, nil).Permutation)
+	if err != nil {
+		return nil, repositoryError(err, nil)
+	}
+
+	return deck, nil
+}
+
+// Puts every card of a pile back in the deck at the position,
+// which is top if empty, and leaves the pile empty. Only the
+// owner of the deck can return them
+func (c *DeckController) ReturnPile(uuid uuid.UUID, name string, position string, viewer string) (*data.Deck, error) {
+
+	returnPosition, err := parsePosition(position)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.checkReorder(uuid, viewer); err != nil {
+		return nil, err
+	}
+
+	deck, err := c.deckRepo.ReturnPile(uuid, name, returnPosition, c.source.Intn)
+	if err != nil {
+		return nil, repositoryError(err, nil)
+	}
+
+	return deck, nil
+}
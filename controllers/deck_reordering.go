@@ -0,0 +1,161 @@
+// Author: Ferran Balaguer
+
+package controllers
+
+import (
+	"strings"
+	"test/cardsgame/data"
+
+	"github.com/google/uuid"
+)
+
+// Positions where returned cards can be placed
+const (
+	PositionTop    = "top"
+	PositionBottom = "bottom"
+	PositionRandom = "random"
+)
+
+// Translates a position name into a data.Position. An
+// empty name is the top of the deck
+func parsePosition(position string) (data.Position, error) {
+
+	switch strings.ToLower(position) {
+	case "", PositionTop:
+		return data.PositionTop, nil
+	case PositionBottom:
+		return data.PositionBottom, nil
+	case PositionRandom:
+		return data.PositionRandom, nil
+	}
+
+	return 0, ErrInvalidPosition
+}
+
+// Returns the canonical code of every card code, or nil
+// if there are no codes. The error has every invalid code
+func canonicalCodes(codes []string) ([]string, error) {
+
+	var canonical []string
+	var invalid []string
+
+	for _, v := range codes {
+		card, err := data.ParseCardCode(v)
+		if err != nil {
+			invalid = append(invalid, v)
+			continue
+		}
+		canonical = append(canonical, card.Code)
+	}
+
+	if len(invalid) > 0 {
+		return nil, &CardCodeError{Err: ErrInvalidCardCode, Codes: invalid}
+	}
+
+	return canonical, nil
+}
+
+// Returns an error if the viewer can't reorder the cards of a
+// deck. Only its owner can, and fair decks can't be reordered
+// at all, as their order must match the commitment
+func (c *DeckController) checkReorder(uuid uuid.UUID, viewer string) error {
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return err
+	}
+
+	if ViewerRole(deck, viewer) != RoleOwner {
+		return ErrNotDeckOwner
+	}
+
+	if deck.Fair {
+		return ErrFairDeckShuffle
+	}
+
+	return nil
+}
+
+// Shuffles the cards left in a deck with a shuffle plan (see
+// ParseShufflePlan), or with the Fisher–Yates shuffle if method
+// is empty. If includeDrawn is true the drawn cards are put back
+// in the deck before shuffling. Only the owner of the deck can
+// shuffle it
+func (c *DeckController) ShuffleDeck(uuid uuid.UUID, method string, includeDrawn bool, viewer string) (*data.Deck, error) {
+
+	var plan ShufflePlan
+
+	if method != "" {
+		var err error
+		plan, err = ParseShufflePlan(method)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.checkReorder(uuid, viewer); err != nil {
+		return nil, err
+	}
+
+	deck, err := c.deckRepo.ShuffleDeck(uuid, includeDrawn, c.newShuffler(plan, nil).Permutation)
+	if err != nil {
+		return nil, repositoryError(err, nil)
+	}
+
+	return deck, nil
+}
+
+// Puts drawn cards back in a deck at the position, which is
+// top if empty. If codes is empty every drawn card is returned
+// in the order they were drawn. Only the owner of the deck can
+// return them, as it tells which cards were drawn
+func (c *DeckController) ReturnCards(uuid uuid.UUID, codes []string, position string, viewer string) (*data.Deck, error) {
+
+	returnPosition, err := parsePosition(position)
+	if err != nil {
+		return nil, err
+	}
+
+	canonical, err := canonicalCodes(codes)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.checkReorder(uuid, viewer); err != nil {
+		return nil, err
+	}
+
+	deck, err := c.deckRepo.ReturnCards(uuid, canonical, returnPosition, c.source.Intn)
+	if err != nil {
+		return nil, repositoryError(err, nil)
+	}
+
+	return deck, nil
+}
+
+// Cuts a deck moving position cards from the top to the bottom.
+// If position is nil the deck is cut close to the middle. Only
+// the owner of the deck can cut it
+func (c *DeckController) CutDeck(uuid uuid.UUID, position *int, viewer string) (*data.Deck, error) {
+
+	if err := c.checkReorder(uuid, viewer); err != nil {
+		return nil, err
+	}
+
+	at := func(n int) int {
+		return cutPosition(n, c.source)
+	}
+
+	if position != nil {
+		at = func(n int) int {
+			return *position
+		}
+	}
+
+	deck, err := c.deckRepo.CutDeck(uuid, at)
+	if err != nil {
+		return nil, repositoryError(err, ErrInvalidPosition)
+	}
+
+	return deck, nil
+}
@@ -0,0 +1,75 @@
+// Author: Ferran Balaguer
+
+package controllers
+
+import (
+	"test/cardsgame/data"
+
+	"github.com/google/uuid"
+)
+
+// Returns a copy of the controller that uses the repository
+func (c *DeckController) withRepository(repository data.DeckRepository) *DeckController {
+
+	controller := *c
+	controller.deckRepo = repository
+
+	return &controller
+}
+
+// Runs fn only if the deck is at one of the versions, or at
+// any version if they are nil, and otherwise returns
+// ErrVersionMismatch. fn gets a controller that only sees the
+// deck, which no other request can change until fn returns.
+// The changes made by fn are stored as the next version of the
+// deck if it succeeds, and discarded if it returns an error
+func (c *DeckController) IfVersion(uuid uuid.UUID, versions []int64, fn func(controller *DeckController) error) error {
+
+	var fnErr error
+
+	err := c.deckRepo.UpdateIfVersion(uuid, versions, func(repository data.DeckRepository) error {
+		fnErr = fn(c.withRepository(repository))
+		return fnErr
+	})
+
+	// Errors of fn are already controller errors
+	if fnErr != nil {
+		return fnErr
+	}
+
+	if err != nil {
+		return repositoryError(err, nil)
+	}
+
+	return nil
+}
+
+// Runs fn with a controller whose repository starts empty, so
+// that the decks it creates are only added to the repository if
+// it succeeds, together with the responses stored with them
+func (c *DeckController) WithNewDecks(fn func(controller *DeckController) error) error {
+
+	scratch := &data.MemoryDeckRepository{}
+
+	if err := fn(c.withRepository(scratch)); err != nil {
+		return err
+	}
+
+	created, err := scratch.ListDecks(data.DeckQuery{})
+	if err != nil {
+		return internalError(err)
+	}
+
+	for _, v := range created {
+		deck, err := scratch.GetDeckById(v.Id)
+		if err != nil {
+			return internalError(err)
+		}
+
+		if err := c.deckRepo.Add(*deck); err != nil {
+			return internalError(err)
+		}
+	}
+
+	return nil
+}
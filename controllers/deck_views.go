@@ -0,0 +1,108 @@
+// Author: Ferran Balaguer
+
+package controllers
+
+import (
+	"regexp"
+	"test/cardsgame/data"
+)
+
+// Roles of the viewers of a deck, which decide the cards
+// they can see
+type Role int
+
+const (
+	// Player that dealt the deck, who sees every card
+	RoleOwner Role = iota
+	// Player holding a pile of the deck, who sees the cards of
+	// that pile and of the face up ones
+	RolePlayer
+	// Anybody else, who only sees how many cards there are
+	RoleSpectator
+)
+
+// Player identifiers are short and safe to use in a header
+var playerId = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+
+// Returns an error if player is not a valid player identifier.
+// An empty player means there is none
+func checkPlayer(player string) error {
+
+	if player != "" && !playerId.MatchString(player) {
+		return ErrInvalidPlayer
+	}
+
+	return nil
+}
+
+// Returns the role of the viewer of a deck. Decks with no owner
+// have no hidden cards, so every viewer is their owner. The
+// viewer is trusted as given, it must be authenticated before
+func ViewerRole(deck *data.Deck, viewer string) Role {
+
+	if deck.Owner == "" || deck.Owner == viewer {
+		return RoleOwner
+	}
+
+	for _, v := range deck.Piles {
+		if viewer != "" && v.Owner == viewer {
+			return RolePlayer
+		}
+	}
+
+	return RoleSpectator
+}
+
+// Returns true if the viewer can see the order of the cards
+// left in the deck. HideOrder decks only show it to their owner,
+// so decks with no owner hide it from everybody
+func canSeeOrder(deck *data.Deck, viewer string) bool {
+	return !deck.HideOrder || (deck.Owner != "" && deck.Owner == viewer)
+}
+
+// Returns true if the viewer can see the cards of the pile
+func canSeePile(deck *data.Deck, pile *data.Pile, viewer string) bool {
+
+	switch ViewerRole(deck, viewer) {
+	case RoleOwner:
+		return true
+	case RolePlayer:
+		return pile.Owner == viewer || pile.FaceUp
+	}
+
+	return false
+}
+
+// Replaces every card by the zero data.Card, which has no code,
+// so that only the amount of cards is shown
+func hideCards(cards []data.Card) []data.Card {
+
+	if cards == nil {
+		return nil
+	}
+
+	return make([]data.Card, len(cards))
+}
+
+// Returns the deck as seen by the viewer. The cards the viewer
+// can't see are replaced by the zero data.Card, which has no
+// code, so that the deck keeps the amount of cards
+func ProjectDeck(deck *data.Deck, viewer string) *data.Deck {
+
+	if ViewerRole(deck, viewer) == RoleOwner {
+		return deck
+	}
+
+	projected := deck.Clone()
+	projected.Cards = hideCards(deck.Cards)
+	projected.Drawn = hideCards(deck.Drawn)
+	projected.Burned = hideCards(deck.Burned)
+
+	for i := range projected.Piles {
+		if !canSeePile(deck, &deck.Piles[i], viewer) {
+			projected.Piles[i].Cards = hideCards(deck.Piles[i].Cards)
+		}
+	}
+
+	return projected
+}
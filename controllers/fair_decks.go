@@ -0,0 +1,132 @@
+// Author: Ferran Balaguer
+
+package controllers
+
+import (
+	"test/cardsgame/data"
+	"test/cardsgame/fairness"
+
+	"github.com/google/uuid"
+)
+
+// Returns the codes of a cards set
+func cardCodes(cards []data.Card) []string {
+
+	codes := make([]string, len(cards))
+	for i, v := range cards {
+		codes[i] = v.Code
+	}
+
+	return codes
+}
+
+// Returns a new server seed for a fair deck
+func newServerSeed() (string, error) {
+
+	seed, err := fairness.NewServerSeed()
+	if err != nil {
+		return "", internalError(err)
+	}
+
+	return seed, nil
+}
+
+// Returns ErrFairDeckNotSeeded if the deck is fair and has no
+// client seed yet, since its cards are still in the initial order
+func checkSeeded(deck *data.Deck) error {
+
+	if deck.Fair && deck.Commitment == "" {
+		return ErrFairDeckNotSeeded
+	}
+
+	return nil
+}
+
+// Shuffles a fair deck with the client seed and its server
+// seed, and stores the commitment of the shuffle. The hash of
+// the server seed is published when the deck is created, so the
+// client seed is chosen knowing that the server can't change its
+// seed anymore. Only the owner of the deck can seed it, and only
+// once, before dealing any card
+func (c *DeckController) SeedDeck(uuid uuid.UUID, clientSeed string, viewer string) (*data.Deck, error) {
+
+	if clientSeed == "" {
+		return nil, ErrInvalidClientSeed
+	}
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if ViewerRole(deck, viewer) != RoleOwner {
+		return nil, ErrNotDeckOwner
+	}
+
+	if !deck.Fair {
+		return nil, ErrDeckNotFair
+	}
+
+	if deck.Commitment != "" {
+		return nil, ErrFairDeckSeeded
+	}
+
+	// Nothing is dealt before seeding, so the stored cards are
+	// still the ones shuffled here
+	permutation := fairness.Permutation(len(deck.Cards), deck.ServerSeed, clientSeed)
+	commitment := fairness.Commitment(deck.ServerSeed, cardCodes(permuteCards(deck.Cards, permutation)))
+
+	deck, err = c.deckRepo.SeedDeck(uuid, clientSeed, commitment, func(n int) []int {
+		return permutation
+	})
+	if err != nil {
+		return nil, repositoryError(err, ErrFairDeckNotSeeded)
+	}
+
+	return deck, nil
+}
+
+// Reveals the server seed of a fair deck together with
+// everything needed to verify its shuffle with fairness.Verify.
+// The deck must be closed or have no cards left, otherwise the
+// seed would reveal the order of the remaining cards. Anybody
+// can reveal it then, since the players are the ones who verify
+// the shuffle, while only the owner can close the deck
+func (c *DeckController) RevealDeck(uuid uuid.UUID) (*fairness.Reveal, error) {
+
+	deck, err := c.OpenDeck(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if !deck.Fair {
+		return nil, ErrDeckNotFair
+	}
+
+	if err := checkSeeded(deck); err != nil {
+		return nil, err
+	}
+
+	if !deck.Closed && deck.Remaining > 0 {
+		return nil, ErrDeckNotFinished
+	}
+
+	initial, err := fairness.InitialCodes(deck.Composition, deck.Jokers, deck.Decks)
+	if err != nil {
+		return nil, internalError(err)
+	}
+
+	reveal := &fairness.Reveal{
+		ServerSeed:     deck.ServerSeed,
+		ServerSeedHash: fairness.SeedHash(deck.ServerSeed),
+		ClientSeed:     deck.ClientSeed,
+		Commitment:     deck.Commitment,
+		Composition:    deck.Composition,
+		Jokers:         deck.Jokers,
+		Decks:          deck.Decks,
+		InitialCards:   initial,
+		Cards:          fairness.ShuffleCodes(initial, deck.ServerSeed, deck.ClientSeed),
+	}
+
+	return reveal, nil
+}
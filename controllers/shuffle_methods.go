@@ -0,0 +1,194 @@
+// Author: Ferran Balaguer
+
+package controllers
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Shuffle methods that mimic how people shuffle by hand. They
+// don't produce every order with the same probability, which is
+// what makes them useful to simulate real games
+const (
+	// Gilbert–Shannon–Reeds riffle shuffle
+	MethodRiffle = "riffle"
+	// Small packets moved from the top of the deck to a new pile
+	MethodOverhand = "overhand"
+	// A few large packets moved from the top to a new pile
+	MethodStrip = "strip"
+	// The top part of the deck is placed below the bottom part
+	MethodCut = "cut"
+)
+
+// Limits of a shuffle plan
+const (
+	MaxShuffleSteps       int = 20
+	MaxShuffleRepetitions int = 100
+)
+
+// One step of a shuffle plan, applying a method Times times
+type ShuffleStep struct {
+	Method string
+	Times  int
+}
+
+// Sequence of shuffle steps applied in order, i.e. seven
+// riffles followed by a cut
+type ShufflePlan []ShuffleStep
+
+// Rearranges the cards of a permutation with a shuffle method
+type shuffleMethod func(a []int, source RandomSource) []int
+
+// Shuffle methods by name
+var shuffleMethods = map[string]shuffleMethod{
+	MethodRiffle:   riffle,
+	MethodOverhand: overhand,
+	MethodStrip:    strip,
+	MethodCut:      cut,
+}
+
+// Parses a shuffle plan made of comma separated methods, each
+// one optionally followed by "*" or "×" and the amount of times
+// it is applied, i.e. "riffle*7,cut"
+func ParseShufflePlan(text string) (ShufflePlan, error) {
+
+	steps := strings.Split(strings.ToLower(text), ",")
+	if len(steps) > MaxShuffleSteps {
+		return nil, ErrInvalidShuffleMethod
+	}
+
+	plan := make(ShufflePlan, len(steps))
+
+	for i, v := range steps {
+		method, times, found := strings.Cut(strings.ReplaceAll(v, "×", "*"), "*")
+
+		plan[i] = ShuffleStep{Method: strings.TrimSpace(method), Times: 1}
+
+		if found {
+			value, err := strconv.Atoi(strings.TrimSpace(times))
+			if err != nil {
+				return nil, ErrInvalidShuffleMethod
+			}
+			plan[i].Times = value
+		}
+
+		if _, ok := shuffleMethods[plan[i].Method]; !ok {
+			return nil, ErrInvalidShuffleMethod
+		}
+
+		if plan[i].Times < 1 || plan[i].Times > MaxShuffleRepetitions {
+			return nil, ErrInvalidShuffleMethod
+		}
+	}
+
+	return plan, nil
+}
+
+// Shuffler applying a shuffle plan with the random numbers
+// of Source
+type PlanShuffler struct {
+	Plan   ShufflePlan
+	Source RandomSource
+}
+
+// Returns the permutation of n cards produced by the plan
+func (s PlanShuffler) Permutation(n int) []int {
+
+	a := make([]int, n)
+	for i := range a {
+		a[i] = i
+	}
+
+	for _, step := range s.Plan {
+		for i := 0; i < step.Times; i++ {
+			a = shuffleMethods[step.Method](a, s.Source)
+		}
+	}
+
+	return a
+}
+
+// Returns the amount of heads of n coin flips, so that cuts
+// are usually close to the middle of the deck
+func binomial(n int, source RandomSource) int {
+
+	k := 0
+	for i := 0; i < n; i++ {
+		k += source.Intn(2)
+	}
+
+	return k
+}
+
+// Gilbert–Shannon–Reeds model: the deck is cut in two packets with
+// a binomial distribution, and the cards are interleaved dropping
+// each one from a packet with probability proportional to its size
+func riffle(a []int, source RandomSource) []int {
+
+	left := a[:binomial(len(a), source)]
+	right := a[len(left):]
+	result := make([]int, 0, len(a))
+
+	for len(left) > 0 || len(right) > 0 {
+		if source.Intn(len(left)+len(right)) < len(left) {
+			result = append(result, left[0])
+			left = left[1:]
+		} else {
+			result = append(result, right[0])
+			right = right[1:]
+		}
+	}
+
+	return result
+}
+
+// Moves packets of random size from the top of the deck to a new
+// pile, so that the packets keep their order but end reversed
+func movePackets(a []int, maxPacket int, source RandomSource) []int {
+
+	result := make([]int, len(a))
+	end := len(a)
+
+	for len(a) > 0 {
+		size := 1 + source.Intn(maxPacket)
+		if size > len(a) {
+			size = len(a)
+		}
+
+		copy(result[end-size:end], a[:size])
+		a = a[size:]
+		end -= size
+	}
+
+	return result
+}
+
+// Overhand shuffle: many small packets, up to an eighth of the deck
+func overhand(a []int, source RandomSource) []int {
+	return movePackets(a, len(a)/8+1, source)
+}
+
+// Strip shuffle: a few large packets, up to a third of the deck
+func strip(a []int, source RandomSource) []int {
+	return movePackets(a, len(a)/3+1, source)
+}
+
+// Cuts the deck close to the middle, placing the top packet
+// below the bottom one. Both packets have at least one card
+func cut(a []int, source RandomSource) []int {
+
+	if len(a) < 2 {
+		return a
+	}
+
+	k := cutPosition(len(a), source)
+
+	return append(append([]int{}, a[k:]...), a[:k]...)
+}
+
+// Returns a cut position of n cards close to the middle,
+// leaving at least one card in each packet. n must be over 1
+func cutPosition(n int, source RandomSource) int {
+	return 1 + binomial(n-2, source)
+}
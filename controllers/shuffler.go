@@ -0,0 +1,95 @@
+// Author: Ferran Balaguer
+
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+)
+
+// Source of uniform bounded random integers
+type RandomSource interface {
+	// Returns a uniform random int in [0, n). n must be
+	// greater than 0
+	Intn(n int) int
+}
+
+// Shuffler generates the random permutations used to
+// shuffle the decks
+type Shuffler interface {
+	// Returns a random permutation of [0, n). Position i of
+	// the shuffled deck holds the card at permutation[i]
+	Permutation(n int) []int
+}
+
+// Shuffler implementing the Fisher–Yates shuffle, which
+// produces every permutation with the same probability as
+// long as Source is unbiased
+type FisherYatesShuffler struct {
+	Source RandomSource
+}
+
+// Generates a random int slice with the desired length
+func (s FisherYatesShuffler) Permutation(n int) []int {
+
+	a := make([]int, n)
+
+	// Initialise array
+	for i := range a {
+		a[i] = i
+	}
+
+	// Fisher–Yates shuffle
+	for i := len(a) - 1; i > 0; i-- {
+		j := s.Source.Intn(i + 1)
+		a[i], a[j] = a[j], a[i]
+	}
+
+	return a
+}
+
+// Cryptographically secure RandomSource backed by crypto/rand.
+// Its outputs can't be predicted from the previous ones
+type CryptoSource struct{}
+
+// Returns a uniform random int in [0, n)
+func (CryptoSource) Intn(n int) int {
+
+	if n <= 0 {
+		panic("invalid argument to Intn")
+	}
+
+	bound := uint64(n)
+	// 2^64 mod bound. Values below it are discarded so that
+	// the amount of accepted values is a multiple of bound,
+	// otherwise the lowest results would be more likely
+	threshold := -bound % bound
+
+	var buf [8]byte
+	for {
+		if _, err := rand.Read(buf[:]); err != nil {
+			panic(err)
+		}
+
+		value := binary.BigEndian.Uint64(buf[:])
+		if value >= threshold {
+			return int(value % bound)
+		}
+	}
+}
+
+// RandomSource safe for concurrent use wrapping another one
+type lockedSource struct {
+	mu     sync.Mutex
+	source RandomSource
+}
+
+// Returns a uniform random int in [0, n)
+func (s *lockedSource) Intn(n int) int {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.source.Intn(n)
+}
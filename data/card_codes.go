@@ -0,0 +1,144 @@
+// Author: Ferran Balaguer
+
+package data
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidCode = errors.New("Invalid card code")
+)
+
+// Canonical code for each card value. Ten uses "T" so that
+// every canonical code is exactly two characters long
+var valueCodes = map[CardValue]string{
+	Ace:   "A",
+	Two:   "2",
+	Three: "3",
+	Four:  "4",
+	Five:  "5",
+	Six:   "6",
+	Seven: "7",
+	Eight: "8",
+	Nine:  "9",
+	Ten:   "T",
+	Jack:  "J",
+	Queen: "Q",
+	King:  "K",
+	Joker: "X",
+}
+
+// Canonical code for each card suit
+var suitCodes = map[CardSuit]string{
+	Spades:   "S",
+	Diamonds: "D",
+	Clubs:    "C",
+	Hearts:   "H",
+	Black:    "B",
+	Red:      "R",
+}
+
+// Accepted aliases for each value code, including the canonical one
+var valueAliases = map[string]CardValue{
+	"A":  Ace,
+	"2":  Two,
+	"3":  Three,
+	"4":  Four,
+	"5":  Five,
+	"6":  Six,
+	"7":  Seven,
+	"8":  Eight,
+	"9":  Nine,
+	"T":  Ten,
+	"0":  Ten,
+	"10": Ten,
+	"J":  Jack,
+	"Q":  Queen,
+	"K":  King,
+	"X":  Joker,
+}
+
+// Accepted aliases for each suit code
+var suitAliases = map[string]CardSuit{
+	"S": Spades,
+	"D": Diamonds,
+	"C": Clubs,
+	"H": Hearts,
+	"B": Black,
+	"R": Red,
+}
+
+// Returns the canonical code of a card, value first and
+// suit second (i.e. "AS", "TD", "KH"). Jokers are "XB"
+// for the black one and "XR" for the red one
+func CardCode(value CardValue, suit CardSuit) string {
+	return valueCodes[value] + suitCodes[suit]
+}
+
+// Parses any accepted card code and returns the card it
+// represents with its canonical code.
+//
+// Accepted forms are value first ("AS", "0S", "TS", "10S") and
+// the legacy suit first form ("SA", "S10"). In the legacy form
+// "1" is read as Ten, as the old decks had a "1" rank instead
+func ParseCardCode(code string) (Card, error) {
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	if len(code) < 2 {
+		return Card{}, ErrInvalidCode
+	}
+
+	// Value first: the suit is always the last character
+	if suit, ok := suitAliases[code[len(code)-1:]]; ok {
+		if value, ok := valueAliases[code[:len(code)-1]]; ok && isValidCard(value, suit) {
+			return newCard(value, suit), nil
+		}
+	}
+
+	// Legacy suit first: the suit is always the first character
+	if suit, ok := suitAliases[code[:1]]; ok {
+		valueCode := code[1:]
+		if valueCode == "1" {
+			valueCode = "T"
+		}
+		if value, ok := valueAliases[valueCode]; ok && isValidCard(value, suit) {
+			return newCard(value, suit), nil
+		}
+	}
+
+	return Card{}, ErrInvalidCode
+}
+
+// Rewrites every card of a deck with its canonical code. Decks
+// stored with the legacy suit first codes are read from their
+// code, as their Value field used the old enum numbering
+func MigrateDeck(deck *Deck) error {
+
+	for i, v := range deck.Cards {
+		card, err := ParseCardCode(v.Code)
+		if err != nil {
+			return err
+		}
+		card.Instance = v.Instance
+		deck.Cards[i] = card
+	}
+
+	return nil
+}
+
+// Jokers are the only cards that use the Black and Red suits
+func isValidCard(value CardValue, suit CardSuit) bool {
+	return (value == Joker) == (suit == Black || suit == Red)
+}
+
+// Mounts a card with its canonical code
+func newCard(value CardValue, suit CardSuit) Card {
+	return Card{
+		Value: value,
+		Suit:  suit,
+		Code:  CardCode(value, suit),
+	}
+}
@@ -0,0 +1,349 @@
+// Author: Ferran Balaguer
+
+// Operations over a deck shared by every DeckRepository
+// implementation, so that all of them behave the same way.
+// They must be called with the deck already locked
+
+package data
+
+// Returns a copy of the first card with the given code
+func findCard(cards []Card, code string) *Card {
+
+	for _, v := range cards {
+		if v.Code == code {
+			return &v
+		}
+	}
+
+	return nil
+}
+
+// Removes amount cards from the top of the deck and
+// returns a copy of them
+func drawCards(deck *Deck, amount int) ([]Card, error) {
+	return drawCardsAt(deck, amount, PositionTop, nil)
+}
+
+// Removes amount cards from the position of the deck and returns
+// a copy of them, in the order they are drawn. random returns a
+// number in [0, n) and is only used to draw from random positions
+func drawCardsAt(deck *Deck, amount int, position Position, random func(n int) int) ([]Card, error) {
+
+	cards, err := takeCards(deck, amount, position, random)
+	if err != nil {
+		return nil, err
+	}
+
+	deck.Drawn = append(deck.Drawn, cards...)
+
+	return cloneCards(cards), nil
+}
+
+// Removes amount cards from the position of the deck and
+// returns them, in the order they are taken
+func takeCards(deck *Deck, amount int, position Position, random func(n int) int) ([]Card, error) {
+
+	// Invalid amout error
+	if amount <= 0 {
+		return nil, ErrInvalidParameters
+	}
+
+	if deck.Closed {
+		return nil, ErrClosed
+	}
+
+	// Error not enough cards left
+	if amount > deck.Remaining {
+		return nil, ErrTruncate
+	}
+
+	var cards []Card
+
+	switch position {
+	case PositionTop:
+		cards = cloneCards(deck.Cards[:amount])
+		// remove "amount" cards form the top of the cards list
+		deck.Cards = deck.Cards[amount:]
+	case PositionBottom:
+		cards = make([]Card, amount)
+		for i := range cards {
+			cards[i] = deck.Cards[len(deck.Cards)-1-i]
+		}
+		deck.Cards = deck.Cards[:len(deck.Cards)-amount]
+	case PositionRandom:
+		cards = make([]Card, amount)
+		for i := range cards {
+			j := random(len(deck.Cards))
+			if j < 0 || j >= len(deck.Cards) {
+				return nil, ErrInvalidParameters
+			}
+			cards[i] = deck.Cards[j]
+			deck.Cards = append(deck.Cards[:j], deck.Cards[j+1:]...)
+		}
+	default:
+		return nil, ErrInvalidParameters
+	}
+
+	// update remaining
+	deck.Remaining -= amount
+
+	return cards, nil
+}
+
+// Removes the first card of each code from the deck and returns
+// a copy of them. If any code is not in the deck it fails
+func drawCardsByCode(deck *Deck, codes []string) ([]Card, error) {
+
+	if len(codes) == 0 {
+		return nil, ErrInvalidParameters
+	}
+
+	if deck.Closed {
+		return nil, ErrClosed
+	}
+
+	cards, left, err := takeCodes(deck.Cards, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	deck.Cards = left
+	deck.Drawn = append(deck.Drawn, cards...)
+	deck.Remaining = len(deck.Cards)
+
+	return cards, nil
+}
+
+// Returns a copy of amount cards from the top of the deck
+func peekCards(deck *Deck, amount int) ([]Card, error) {
+
+	if amount <= 0 {
+		return nil, ErrInvalidParameters
+	}
+
+	if amount > deck.Remaining {
+		return nil, ErrTruncate
+	}
+
+	return cloneCards(deck.Cards[:amount]), nil
+}
+
+// Moves amount cards from the top of the deck to the burn pile
+func burnCards(deck *Deck, amount int) error {
+
+	if amount <= 0 {
+		return ErrInvalidParameters
+	}
+
+	if deck.Closed {
+		return ErrClosed
+	}
+
+	if amount > deck.Remaining {
+		return ErrTruncate
+	}
+
+	deck.Burned = append(deck.Burned, deck.Cards[:amount]...)
+	deck.Cards = deck.Cards[amount:]
+	deck.Remaining -= amount
+
+	return nil
+}
+
+// Closes the deck. Closing a closed deck does nothing
+func closeDeck(deck *Deck) {
+	deck.Closed = true
+}
+
+// The cards are no longer in the order of the seed, so
+// it can't reproduce the deck anymore
+func unseed(deck *Deck) {
+	deck.Seeded = false
+	deck.Seed = 0
+}
+
+// Reorders the cards left in the deck so that position i holds
+// the card at position permutation[i]. If includeDrawn is true the
+// drawn and burned cards are put back on top of the deck before
+func shuffleDeck(deck *Deck, includeDrawn bool, permutation func(n int) []int) error {
+
+	if deck.Closed {
+		return ErrClosed
+	}
+
+	cards := deck.Cards
+	if includeDrawn {
+		cards = append(append(cloneCards(deck.Drawn), deck.Burned...), deck.Cards...)
+	}
+
+	shuffled, err := permute(cards, permutation(len(cards)))
+	if err != nil {
+		return err
+	}
+
+	if includeDrawn {
+		deck.Drawn = nil
+		deck.Burned = nil
+	}
+
+	deck.Cards = shuffled
+	deck.Remaining = len(shuffled)
+	deck.Shuffled = true
+	unseed(deck)
+
+	return nil
+}
+
+// Shuffles a fair deck that has no cards dealt yet like
+// shuffleDeck, and stores the client seed and the commitment of
+// the shuffle. A deck is only seeded once, as the commitment
+// must match the order its cards are dealt
+func seedDeck(deck *Deck, clientSeed string, commitment string, permutation func(n int) []int) error {
+
+	if deck.Closed {
+		return ErrClosed
+	}
+
+	if deck.Commitment != "" {
+		return ErrSeeded
+	}
+
+	if !deck.Fair || len(deck.Drawn) > 0 || len(deck.Burned) > 0 {
+		return ErrInvalidParameters
+	}
+
+	for _, v := range deck.Piles {
+		if len(v.Cards) > 0 {
+			return ErrInvalidParameters
+		}
+	}
+
+	shuffled, err := permute(deck.Cards, permutation(len(deck.Cards)))
+	if err != nil {
+		return err
+	}
+
+	deck.Cards = shuffled
+	deck.Shuffled = true
+	deck.ClientSeed = clientSeed
+	deck.Commitment = commitment
+
+	return nil
+}
+
+// Returns a copy of cards where position i holds the card at
+// position order[i]. order must be a permutation of the positions
+func permute(cards []Card, order []int) ([]Card, error) {
+
+	if len(order) != len(cards) {
+		return nil, ErrInvalidParameters
+	}
+
+	used := make([]bool, len(order))
+	result := make([]Card, len(order))
+
+	for i, v := range order {
+		// Every position must be taken exactly once
+		if v < 0 || v >= len(order) || used[v] {
+			return nil, ErrInvalidParameters
+		}
+		used[v] = true
+		result[i] = cards[v]
+	}
+
+	return result, nil
+}
+
+// Puts drawn cards back in the deck. If codes is nil every drawn
+// card is returned, otherwise the last drawn card of each code.
+// random returns a number in [0, n) and is only used to place
+// the cards at random positions
+func returnCards(deck *Deck, codes []string, position Position, random func(n int) int) error {
+
+	if deck.Closed {
+		return ErrClosed
+	}
+
+	drawn := cloneCards(deck.Drawn)
+	returned := drawn
+
+	if codes != nil {
+		returned = make([]Card, 0, len(codes))
+
+		for _, code := range codes {
+			i := len(drawn) - 1
+			for i >= 0 && drawn[i].Code != code {
+				i--
+			}
+
+			if i < 0 {
+				return ErrNotDrawn
+			}
+
+			returned = append(returned, drawn[i])
+			drawn = append(drawn[:i], drawn[i+1:]...)
+		}
+	} else {
+		drawn = nil
+	}
+
+	if err := placeCards(deck, returned, position, random); err != nil {
+		return err
+	}
+
+	deck.Drawn = drawn
+
+	return nil
+}
+
+// Puts cards in the deck at the position. random returns a
+// number in [0, n) and is only used to place the cards at
+// random positions, which shuffles the deck
+func placeCards(deck *Deck, cards []Card, position Position, random func(n int) int) error {
+
+	switch position {
+	case PositionTop:
+		deck.Cards = append(cloneCards(cards), deck.Cards...)
+	case PositionBottom:
+		deck.Cards = append(cloneCards(deck.Cards), cards...)
+	case PositionRandom:
+		for _, v := range cards {
+			i := random(len(deck.Cards) + 1)
+			if i < 0 || i > len(deck.Cards) {
+				return ErrInvalidParameters
+			}
+			deck.Cards = append(deck.Cards[:i], append([]Card{v}, deck.Cards[i:]...)...)
+		}
+		deck.Shuffled = true
+	default:
+		return ErrInvalidParameters
+	}
+
+	deck.Remaining = len(deck.Cards)
+	unseed(deck)
+
+	return nil
+}
+
+// Moves the amount of cards returned by position from the top
+// to the bottom of the deck. Both parts must have cards
+func cutDeck(deck *Deck, position func(n int) int) error {
+
+	if deck.Closed {
+		return ErrClosed
+	}
+
+	if len(deck.Cards) < 2 {
+		return ErrTruncate
+	}
+
+	k := position(len(deck.Cards))
+	if k < 1 || k >= len(deck.Cards) {
+		return ErrInvalidParameters
+	}
+
+	deck.Cards = append(cloneCards(deck.Cards[k:]), deck.Cards[:k]...)
+	unseed(deck)
+
+	return nil
+}
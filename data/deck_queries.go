@@ -0,0 +1,131 @@
+// Author: Ferran Balaguer
+
+// Filters and order of the decks listed by ListDecks, shared
+// by every DeckRepository implementation
+
+package data
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Order of the decks listed by ListDecks. Decks with the same
+// value are sorted by id, so that the order never changes
+type DeckSort int
+
+const (
+	// Oldest decks first
+	SortByCreatedAt DeckSort = iota
+	// Decks with fewer cards left first
+	SortByRemaining
+)
+
+// Filters, order and page of the decks listed by ListDecks.
+// Zero values don't filter
+type DeckQuery struct {
+	Shuffled     *bool
+	MinRemaining *int
+	MaxRemaining *int
+	// Only the decks created after this time
+	CreatedAfter time.Time
+	Owner        string
+	// Only the decks with this tag
+	Tag        string
+	Sort       DeckSort
+	Descending bool
+	// Only the decks after this position of the order, to
+	// list the next page
+	After *DeckCursor
+	// Maximum amount of decks listed, 0 means no limit
+	Limit int
+}
+
+// Position of a deck in the order of a DeckQuery, made of the
+// value it is sorted by and its id
+type DeckCursor struct {
+	CreatedAt time.Time
+	Remaining int
+	Id        uuid.UUID
+}
+
+// Returns the position of a deck in any order
+func CursorOf(deck *Deck) DeckCursor {
+	return DeckCursor{CreatedAt: deck.CreatedAt, Remaining: deck.Remaining, Id: deck.Id}
+}
+
+// Returns true if the deck passes every filter of the query
+func (q *DeckQuery) matches(deck *Deck) bool {
+
+	if q.Shuffled != nil && deck.Shuffled != *q.Shuffled {
+		return false
+	}
+
+	if q.MinRemaining != nil && deck.Remaining < *q.MinRemaining {
+		return false
+	}
+
+	if q.MaxRemaining != nil && deck.Remaining > *q.MaxRemaining {
+		return false
+	}
+
+	if !q.CreatedAfter.IsZero() && !deck.CreatedAt.After(q.CreatedAfter) {
+		return false
+	}
+
+	if q.Owner != "" && deck.Owner != q.Owner {
+		return false
+	}
+
+	if q.Tag != "" && !deck.HasTag(q.Tag) {
+		return false
+	}
+
+	return true
+}
+
+// Compares two positions in the order of the query, returning
+// -1 if a goes before b, 1 if it goes after and 0 if equal
+func (q *DeckQuery) compare(a DeckCursor, b DeckCursor) int {
+
+	result := 0
+
+	switch q.Sort {
+	case SortByRemaining:
+		if a.Remaining < b.Remaining {
+			result = -1
+		} else if a.Remaining > b.Remaining {
+			result = 1
+		}
+	default:
+		result = a.CreatedAt.Compare(b.CreatedAt)
+	}
+
+	// Ids are compared like their strings
+	if result == 0 {
+		result = bytes.Compare(a.Id[:], b.Id[:])
+	}
+
+	if q.Descending {
+		return -result
+	}
+
+	return result
+}
+
+// Returns a copy of the deck without its cards, piles and
+// stored responses, as listed by ListDecks
+func summarizeDeck(deck *Deck) Deck {
+
+	summary := *deck
+	summary.Cards = nil
+	summary.Drawn = nil
+	summary.Burned = nil
+	summary.Piles = nil
+	summary.Responses = nil
+	summary.Tags = cloneTags(deck.Tags)
+
+	return summary
+}
@@ -4,6 +4,8 @@ package data
 
 import (
 	"errors"
+	"sort"
+	"sync"
 
 	"github.com/google/uuid"
 )
@@ -12,63 +14,386 @@ var (
 	ErrNotFound          = errors.New("Not found")
 	ErrInvalidParameters = errors.New("Invalid argument")
 	ErrTruncate          = errors.New("Truncated items")
+	ErrClosed            = errors.New("Closed")
+	ErrNotDrawn          = errors.New("Not drawn")
+	ErrCardNotFound      = errors.New("Card not found")
+	ErrVersionMismatch   = errors.New("Version mismatch")
+	ErrSeeded            = errors.New("Already seeded")
+)
+
+// Where cards are drawn from or returned to in a deck
+type Position int
+
+const (
+	// Top of the deck
+	PositionTop Position = iota
+	// Bottom of the deck
+	PositionBottom
+	// Each card at a random position
+	PositionRandom
 )
 
 // Data abstraction interface to decouple the
-// Bussiness layer from the data layer
+// Bussiness layer from the data layer.
+//
+// Implementations have snapshot semantics: decks and cards
+// passed in or returned never share memory with the stored
+// ones, so later operations don't modify what a caller holds
+// and callers can't modify the stored state
 type DeckRepository interface {
 
 	// Crates a new deck in the repository
-	Add(Deck)
-	// Gets a deck from the repostory
+	Add(Deck) error
+	// Gets a copy of a deck from the repostory
 	GetDeckById(uuid.UUID) (*Deck, error)
-	// Gets a concrete card from a deck
+	// Gets a copy of a concrete card from a deck
 	GetDeckCardByCode(uuid.UUID, string) (*Card, error)
 	// Get cards from deck
 	DrawCardsFromDeck(uuid.UUID, int) ([]Card, error)
+	// Draws cards from the position of the deck. The function
+	// returns a random number in [0, n) used with PositionRandom
+	DrawCardsAt(uuid.UUID, int, Position, func(int) int) ([]Card, error)
+	// Draws the cards with the codes. If any of them is not in
+	// the deck no card is drawn
+	DrawCardsByCode(uuid.UUID, []string) ([]Card, error)
+	// Gets a copy of cards from the top of a deck without
+	// removing them
+	PeekCards(uuid.UUID, int) ([]Card, error)
+	// Moves cards from the top of a deck to its burn pile
+	BurnCards(uuid.UUID, int) (*Deck, error)
+	// Closes a deck so that no more cards can be drawn
+	CloseDeck(uuid.UUID) (*Deck, error)
+	// Removes a deck, its cards and its piles from the repository
+	DeleteDeck(uuid.UUID) error
+	// Reorders the cards left in a deck with the permutation
+	// returned for their amount. If the bool is true the drawn
+	// and burned cards are put back on top of the deck before
+	ShuffleDeck(uuid.UUID, bool, func(int) []int) (*Deck, error)
+	// Puts drawn cards back in the deck, in the order they are
+	// returned. If codes is nil every drawn card is returned. The
+	// function returns a random number in [0, n) used with
+	// PositionRandom
+	ReturnCards(uuid.UUID, []string, Position, func(int) int) (*Deck, error)
+	// Moves cards from the top to the bottom of the deck. The
+	// function returns how many from the amount of cards left
+	CutDeck(uuid.UUID, func(int) int) (*Deck, error)
+	// Shuffles a fair deck with the permutation returned for its
+	// amount of cards, and stores the client seed and commitment
+	// of the shuffle. Returns ErrSeeded if it already has them
+	SeedDeck(uuid.UUID, string, string, func(int) []int) (*Deck, error)
+
+	// Adds a new empty pile to a deck, with the name, owner
+	// and face of the supplied one
+	CreatePile(uuid.UUID, Pile) (*Deck, error)
+	// Moves cards from the position of a deck to one of its
+	// piles, like DrawCardsAt
+	DrawCardsToPile(uuid.UUID, string, int, Position, func(int) int) ([]Card, error)
+	// Moves cards from one pile of a deck to another. If codes
+	// is nil every card is moved
+	MovePileCards(uuid.UUID, string, string, []string) (*Deck, error)
+	// Reorders the cards of a pile with the permutation
+	// returned for their amount
+	ShufflePile(uuid.UUID, string, func(int) []int) (*Deck, error)
+	// Puts every card of a pile back in the deck, like
+	// ReturnCards, and leaves the pile empty
+	ReturnPile(uuid.UUID, string, Position, func(int) int) (*Deck, error)
+
+	// Stores a response with a deck, replacing the one with the
+	// same key and discarding the expired ones. Returns
+	// ErrResponsesFull if the deck has MaxResponses unexpired ones
+	SaveResponse(uuid.UUID, StoredResponse) error
+	// Gets a copy of the last response stored with the key in
+	// any deck, or ErrNotFound
+	GetResponse(string) (*StoredResponse, error)
+
+	// Gets copies of the decks that match the query, in its
+	// order, without their cards, piles or stored responses
+	ListDecks(DeckQuery) ([]Deck, error)
+
+	// Runs the change only if the deck is at one of the versions,
+	// or at any version if they are nil, and otherwise returns
+	// ErrVersionMismatch. The change gets a repository holding
+	// only the deck, and no other change is made to the deck
+	// until it returns. If it succeeds all its changes are stored
+	// at once, as the next version
+	UpdateIfVersion(uuid.UUID, []int64, func(DeckRepository) error) error
+}
+
+// Returns true if the version is one of the versions, or if
+// they are nil
+func versionMatches(version int64, versions []int64) bool {
+
+	if versions == nil {
+		return true
+	}
+
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Runs a change over a repository holding only a copy of the
+// deck, and returns the changed copy, or nil if it was deleted
+func applyChange(deck *Deck, change func(DeckRepository) error) (*Deck, error) {
+
+	scratch := &MemoryDeckRepository{
+		decks: map[uuid.UUID]*deckEntry{deck.Id: {deck: deck.Clone()}},
+	}
+
+	if err := change(scratch); err != nil {
+		return nil, err
+	}
+
+	entry, ok := scratch.decks[deck.Id]
+	if !ok {
+		return nil, nil
+	}
+
+	return entry.deck, nil
 }
 
 // Implements DeckRepository using
-// a map in memory as storage
+// a map in memory as storage. It is safe for concurrent
+// use: the map is protected by its own lock, and every deck
+// has a lock so that requests on different decks don't contend
 type MemoryDeckRepository struct {
-	decks map[uuid.UUID]*Deck
+	mu    sync.RWMutex
+	decks map[uuid.UUID]*deckEntry
+	// Deck of every stored response by key
+	keys map[string]uuid.UUID
+	// Optional hook called with the new state of a deck before
+	// storing it. If it fails the change is discarded, which
+	// allows other repositories to persist every change
+	commit func(deck *Deck) error
+	// Optional hook called with the id of a deck before
+	// removing it. If it fails the deck is kept
+	discard func(uuid uuid.UUID) error
+}
+
+// Stored deck with the lock that protects it. The deck is
+// nil once removed, for the requests that were waiting
+type deckEntry struct {
+	mu   sync.Mutex
+	deck *Deck
+}
+
+// Indexes the responses stored with a deck by their key.
+// It must be called holding the repository lock
+func (r *MemoryDeckRepository) indexResponses(id uuid.UUID, responses []StoredResponse) {
+
+	if r.keys == nil {
+		r.keys = map[string]uuid.UUID{}
+	}
+
+	for _, v := range responses {
+		r.keys[v.Key] = id
+	}
+}
+
+// Removes the keys of responses no longer stored with a deck.
+// It must be called holding the repository lock
+func (r *MemoryDeckRepository) unindexResponses(id uuid.UUID, responses []StoredResponse) {
+
+	for _, v := range responses {
+		if indexed, ok := r.keys[v.Key]; ok && indexed == id {
+			delete(r.keys, v.Key)
+		}
+	}
+}
+
+// Returns the entry of a deck if exists
+func (r *MemoryDeckRepository) getEntry(uuid uuid.UUID) (*deckEntry, bool) {
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.decks[uuid]
+
+	return entry, ok
+}
+
+// Runs fn holding the lock of the deck, so that fn
+// reads a consistent deck. fn must not modify the deck
+func (r *MemoryDeckRepository) view(uuid uuid.UUID, fn func(deck *Deck) error) error {
+
+	entry, ok := r.getEntry(uuid)
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.deck == nil {
+		return ErrNotFound
+	}
+
+	return fn(entry.deck)
+}
+
+// Runs fn over a copy of the deck holding its lock, so that
+// fn can read and modify the deck atomically. The copy is
+// only stored, as a new version, if both fn and the commit
+// hook succeed
+func (r *MemoryDeckRepository) update(uuid uuid.UUID, fn func(deck *Deck) error) error {
+	return r.updateDeck(uuid, true, fn)
+}
+
+// Runs fn like update. The version is kept unless newVersion,
+// for the changes that don't modify the cards
+func (r *MemoryDeckRepository) updateDeck(uuid uuid.UUID, newVersion bool, fn func(deck *Deck) error) error {
+
+	entry, ok := r.getEntry(uuid)
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.deck == nil {
+		return ErrNotFound
+	}
+
+	deck := entry.deck.Clone()
+
+	// Set before fn, which may return a copy of the deck.
+	// The copy is discarded if fn fails
+	if newVersion {
+		deck.Version++
+	}
+
+	if err := fn(deck); err != nil {
+		return err
+	}
+
+	if r.commit != nil {
+		if err := r.commit(deck); err != nil {
+			return err
+		}
+	}
+
+	entry.deck = deck
+
+	return nil
+}
+
+// Runs fn over the deck like update, and returns a
+// copy of the updated deck
+func (r *MemoryDeckRepository) modify(uuid uuid.UUID, fn func(deck *Deck) error) (*Deck, error) {
+
+	var deck *Deck
+
+	err := r.update(uuid, func(d *Deck) error {
+		if err := fn(d); err != nil {
+			return err
+		}
+		deck = d.Clone()
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return deck, nil
 }
 
 // DeckRepository interface implementation
 
-func (r *MemoryDeckRepository) Add(deck Deck) {
+func (r *MemoryDeckRepository) Add(deck Deck) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
 	if r.decks == nil {
-		r.decks = map[uuid.UUID]*Deck{}
+		r.decks = map[uuid.UUID]*deckEntry{}
 	}
 
-	r.decks[deck.Id] = &deck
+	stored := deck.Clone()
+	stored.Version = 1
+
+	if r.commit != nil {
+		if err := r.commit(stored); err != nil {
+			return err
+		}
+	}
+
+	r.decks[deck.Id] = &deckEntry{deck: stored}
+	r.indexResponses(deck.Id, stored.Responses)
+
+	return nil
 }
 
 func (r *MemoryDeckRepository) GetDeckById(uuid uuid.UUID) (*Deck, error) {
 
-	deck, ok := r.decks[uuid]
+	var deck *Deck
 
-	if !ok {
-		return nil, ErrNotFound
+	err := r.view(uuid, func(d *Deck) error {
+		deck = d.Clone()
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
 	return deck, nil
 }
 
-func (r *MemoryDeckRepository) GetDeckCardByCode(uuid uuid.UUID, code string) (*Card, error) {
+func (r *MemoryDeckRepository) ListDecks(query DeckQuery) ([]Deck, error) {
 
-	var card *Card
+	r.mu.RLock()
+	entries := make([]*deckEntry, 0, len(r.decks))
+	for _, v := range r.decks {
+		entries = append(entries, v)
+	}
+	r.mu.RUnlock()
 
-	if deck, ok := r.decks[uuid]; ok {
-		for _, v := range deck.Cards {
-			if v.Code == code {
-				card = &v
-				break
-			}
+	decks := []Deck{}
+
+	for _, entry := range entries {
+		entry.mu.Lock()
+		if entry.deck != nil && query.matches(entry.deck) {
+			decks = append(decks, summarizeDeck(entry.deck))
 		}
+		entry.mu.Unlock()
+	}
+
+	sort.Slice(decks, func(i, j int) bool {
+		return query.compare(CursorOf(&decks[i]), CursorOf(&decks[j])) < 0
+	})
+
+	// Skips the decks up to the cursor
+	if query.After != nil {
+		first := sort.Search(len(decks), func(i int) bool {
+			return query.compare(CursorOf(&decks[i]), *query.After) > 0
+		})
+		decks = decks[first:]
+	}
+
+	if query.Limit > 0 && len(decks) > query.Limit {
+		decks = decks[:query.Limit]
 	}
 
+	return decks, nil
+}
+
+func (r *MemoryDeckRepository) GetDeckCardByCode(uuid uuid.UUID, code string) (*Card, error) {
+
+	var card *Card
+
+	r.view(uuid, func(deck *Deck) error {
+		card = findCard(deck.Cards, code)
+		return nil
+	})
+
 	if card == nil {
 		return nil, ErrNotFound
 	}
@@ -78,26 +403,306 @@ func (r *MemoryDeckRepository) GetDeckCardByCode(uuid uuid.UUID, code string) (*
 
 func (r *MemoryDeckRepository) DrawCardsFromDeck(uuid uuid.UUID, amount int) ([]Card, error) {
 
-	// Invalid amout error
-	if amount <= 0 {
-		return nil, ErrInvalidParameters
+	var cards []Card
+
+	err := r.update(uuid, func(deck *Deck) error {
+		var err error
+		cards, err = drawCards(deck, amount)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+func (r *MemoryDeckRepository) DrawCardsAt(uuid uuid.UUID, amount int, position Position, random func(int) int) ([]Card, error) {
+
+	var cards []Card
+
+	err := r.update(uuid, func(deck *Deck) error {
+		var err error
+		cards, err = drawCardsAt(deck, amount, position, random)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+func (r *MemoryDeckRepository) DrawCardsByCode(uuid uuid.UUID, codes []string) ([]Card, error) {
+
+	var cards []Card
+
+	err := r.update(uuid, func(deck *Deck) error {
+		var err error
+		cards, err = drawCardsByCode(deck, codes)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+func (r *MemoryDeckRepository) PeekCards(uuid uuid.UUID, amount int) ([]Card, error) {
+
+	var cards []Card
+
+	err := r.view(uuid, func(deck *Deck) error {
+		var err error
+		cards, err = peekCards(deck, amount)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+func (r *MemoryDeckRepository) BurnCards(uuid uuid.UUID, amount int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return burnCards(deck, amount)
+	})
+}
+
+func (r *MemoryDeckRepository) CloseDeck(uuid uuid.UUID) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		closeDeck(deck)
+		return nil
+	})
+}
+
+func (r *MemoryDeckRepository) DeleteDeck(uuid uuid.UUID) error {
+
+	entry, ok := r.getEntry(uuid)
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	// Waits for the requests on the deck in progress
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.deck == nil {
+		return ErrNotFound
+	}
+
+	return r.remove(uuid, entry)
+}
+
+// Removes the deck of an entry. It must be called holding
+// the lock of the entry
+func (r *MemoryDeckRepository) remove(uuid uuid.UUID, entry *deckEntry) error {
+
+	if r.discard != nil {
+		if err := r.discard(uuid); err != nil {
+			return err
+		}
+	}
+
+	responses := entry.deck.Responses
+	entry.deck = nil
+
+	r.mu.Lock()
+	delete(r.decks, uuid)
+	r.unindexResponses(uuid, responses)
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *MemoryDeckRepository) ShuffleDeck(uuid uuid.UUID, includeDrawn bool, permutation func(int) []int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return shuffleDeck(deck, includeDrawn, permutation)
+	})
+}
+
+func (r *MemoryDeckRepository) ReturnCards(uuid uuid.UUID, codes []string, position Position, random func(int) int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return returnCards(deck, codes, position, random)
+	})
+}
+
+func (r *MemoryDeckRepository) CutDeck(uuid uuid.UUID, position func(int) int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return cutDeck(deck, position)
+	})
+}
+
+func (r *MemoryDeckRepository) SeedDeck(uuid uuid.UUID, clientSeed string, commitment string, permutation func(int) []int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return seedDeck(deck, clientSeed, commitment, permutation)
+	})
+}
+
+func (r *MemoryDeckRepository) CreatePile(uuid uuid.UUID, pile Pile) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return createPile(deck, pile)
+	})
+}
+
+func (r *MemoryDeckRepository) DrawCardsToPile(uuid uuid.UUID, name string, amount int, position Position, random func(int) int) ([]Card, error) {
+
+	var cards []Card
+
+	err := r.update(uuid, func(deck *Deck) error {
+		var err error
+		cards, err = drawCardsToPile(deck, name, amount, position, random)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
-	if deck, ok := r.decks[uuid]; ok {
+	return cards, nil
+}
+
+func (r *MemoryDeckRepository) MovePileCards(uuid uuid.UUID, from string, to string, codes []string) (*Deck, error) {
 
-		// Error not enough cards left
-		if amount > deck.Remaining {
-			return nil, ErrTruncate
+	return r.modify(uuid, func(deck *Deck) error {
+		return movePileCards(deck, from, to, codes)
+	})
+}
+
+func (r *MemoryDeckRepository) ShufflePile(uuid uuid.UUID, name string, permutation func(int) []int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return shufflePile(deck, name, permutation)
+	})
+}
+
+func (r *MemoryDeckRepository) ReturnPile(uuid uuid.UUID, name string, position Position, random func(int) int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return returnPile(deck, name, position, random)
+	})
+}
+
+func (r *MemoryDeckRepository) SaveResponse(uuid uuid.UUID, response StoredResponse) error {
+
+	var previous, current []StoredResponse
+
+	// Storing a response doesn't change the deck seen by clients
+	err := r.updateDeck(uuid, false, func(deck *Deck) error {
+		previous = deck.Responses
+		if err := saveResponse(deck, response); err != nil {
+			return err
 		}
+		current = deck.Responses
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.unindexResponses(uuid, previous)
+	r.indexResponses(uuid, current)
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *MemoryDeckRepository) GetResponse(key string) (*StoredResponse, error) {
 
-		cards := deck.Cards[:amount]
-		// remove "amount" cards form the top of the cards list
-		deck.Cards = deck.Cards[amount:]
-		// update remaining
-		deck.Remaining -= amount
+	r.mu.RLock()
+	id, ok := r.keys[key]
+	r.mu.RUnlock()
 
-		return cards, nil
-	} else {
+	if !ok {
 		return nil, ErrNotFound
 	}
+
+	var response *StoredResponse
+
+	err := r.view(id, func(deck *Deck) error {
+		if found := findResponse(deck, key); found != nil {
+			response = found.Clone()
+		}
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if response == nil {
+		// The deck no longer has it, so the key was indexed
+		// by a change that raced with a newer one
+		r.mu.Lock()
+		if indexed, ok := r.keys[key]; ok && indexed == id {
+			delete(r.keys, key)
+		}
+		r.mu.Unlock()
+		return nil, ErrNotFound
+	}
+
+	return response, nil
+}
+
+func (r *MemoryDeckRepository) UpdateIfVersion(uuid uuid.UUID, versions []int64, change func(DeckRepository) error) error {
+
+	entry, ok := r.getEntry(uuid)
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.deck == nil {
+		return ErrNotFound
+	}
+
+	if !versionMatches(entry.deck.Version, versions) {
+		return ErrVersionMismatch
+	}
+
+	deck, err := applyChange(entry.deck, change)
+	if err != nil {
+		return err
+	}
+
+	if deck == nil {
+		return r.remove(uuid, entry)
+	}
+
+	deck.Version = entry.deck.Version + 1
+
+	if r.commit != nil {
+		if err := r.commit(deck); err != nil {
+			return err
+		}
+	}
+
+	previous := entry.deck.Responses
+	entry.deck = deck
+
+	r.mu.Lock()
+	r.unindexResponses(uuid, previous)
+	r.indexResponses(uuid, deck.Responses)
+	r.mu.Unlock()
+
+	return nil
 }
@@ -0,0 +1,336 @@
+// Author: Ferran Balaguer
+
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCorruptedJournal = errors.New("Corrupted journal")
+)
+
+// Files written inside the repository directory
+const (
+	journalFileName  = "journal.log"
+	snapshotFileName = "snapshot.json"
+)
+
+// Default amount of journal records written between snapshots
+const DefaultSnapshotInterval int = 1000
+
+// Journal record. Every record holds the whole state of a
+// deck after a change, or the id of a removed deck, so
+// replaying a record twice is harmless
+type journalRecord struct {
+	Deck    *Deck      `json:"deck,omitempty"`
+	Deleted *uuid.UUID `json:"deleted,omitempty"`
+}
+
+// Implements DeckRepository keeping the decks in memory and
+// persisting them to a directory, so that they survive restarts.
+//
+// Every change is appended to a journal file and synced to disk
+// before it is applied, one JSON record per line. Every
+// SnapshotInterval records all decks are written to a snapshot
+// file and the journal is truncated. On startup the snapshot is
+// loaded and the journal replayed; a last record without its line
+// end was being written when the process died, so it was never
+// applied nor answered and it is discarded
+type FileDeckRepository struct {
+	MemoryDeckRepository
+
+	// Amount of journal records written between snapshots
+	SnapshotInterval int
+
+	mu      sync.Mutex
+	dir     string
+	journal *os.File
+	size    int64
+	records int
+	// Last persisted state of every deck
+	persisted map[uuid.UUID]*Deck
+}
+
+// Opens the repository stored in dir, creating it if it
+// does not exist, and recovers all its decks
+func NewFileDeckRepository(dir string) (*FileDeckRepository, error) {
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	r := &FileDeckRepository{
+		SnapshotInterval: DefaultSnapshotInterval,
+		dir:              dir,
+		persisted:        map[uuid.UUID]*Deck{},
+	}
+
+	if err := r.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	journal, err := os.OpenFile(filepath.Join(dir, journalFileName), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r.journal = journal
+
+	if err := r.replayJournal(); err != nil {
+		journal.Close()
+		return nil, err
+	}
+
+	r.decks = map[uuid.UUID]*deckEntry{}
+	for id, deck := range r.persisted {
+		if err := MigrateDeck(deck); err != nil {
+			journal.Close()
+			return nil, err
+		}
+		r.decks[id] = &deckEntry{deck: deck}
+		r.indexResponses(id, deck.Responses)
+	}
+
+	// Compacts the replayed journal into a new snapshot
+	if r.records > 0 {
+		if err := r.snapshot(); err != nil {
+			journal.Close()
+			return nil, err
+		}
+	}
+
+	r.commit = r.append
+	r.discard = r.appendDeleted
+
+	return r, nil
+}
+
+// Closes the journal file. The repository can not
+// be modified after closing it
+func (r *FileDeckRepository) Close() error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.journal.Close()
+}
+
+// Reads the snapshot file if exists
+func (r *FileDeckRepository) loadSnapshot() error {
+
+	content, err := os.ReadFile(filepath.Join(r.dir, snapshotFileName))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var decks []*Deck
+	if err := json.Unmarshal(content, &decks); err != nil {
+		return err
+	}
+
+	for _, deck := range decks {
+		r.persisted[deck.Id] = deck
+	}
+
+	return nil
+}
+
+// Applies every complete journal record and discards an
+// incomplete last one
+func (r *FileDeckRepository) replayJournal() error {
+
+	reader := bufio.NewReader(r.journal)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		if errors.Is(err, io.EOF) {
+			// Incomplete last record
+			break
+		} else if err != nil {
+			return err
+		}
+
+		var record journalRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+			return ErrCorruptedJournal
+		}
+
+		if record.Deck != nil {
+			r.persisted[record.Deck.Id] = record.Deck
+		} else if record.Deleted != nil {
+			delete(r.persisted, *record.Deleted)
+		} else {
+			return ErrCorruptedJournal
+		}
+
+		r.size += int64(len(line))
+		r.records++
+	}
+
+	return r.rewind(r.size)
+}
+
+// Truncates the journal to size and places the
+// write offset at its end
+func (r *FileDeckRepository) rewind(size int64) error {
+
+	if err := r.journal.Truncate(size); err != nil {
+		return err
+	}
+
+	_, err := r.journal.Seek(size, io.SeekStart)
+
+	return err
+}
+
+// Commit hook of the memory repository. Appends the new state
+// of a deck to the journal and waits until it is on disk
+func (r *FileDeckRepository) append(deck *Deck) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.write(journalRecord{Deck: deck}); err != nil {
+		return fmt.Errorf("writing deck %s to the journal: %w", deck.Id, err)
+	}
+
+	r.persisted[deck.Id] = deck
+	r.compact()
+
+	return nil
+}
+
+// Discard hook of the memory repository. Appends the removal
+// of a deck to the journal and waits until it is on disk
+func (r *FileDeckRepository) appendDeleted(uuid uuid.UUID) error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.write(journalRecord{Deleted: &uuid}); err != nil {
+		return fmt.Errorf("writing removal of deck %s to the journal: %w", uuid, err)
+	}
+
+	delete(r.persisted, uuid)
+	r.compact()
+
+	return nil
+}
+
+// Appends a record to the journal and syncs it. A record
+// that fails is removed from the journal
+func (r *FileDeckRepository) write(record journalRecord) error {
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encoding record: %w", err)
+	}
+	line = append(line, '\n')
+
+	if _, err := r.journal.Write(line); err != nil {
+		// Removes any partially written record
+		r.rewind(r.size)
+		return err
+	}
+
+	if err := r.journal.Sync(); err != nil {
+		r.rewind(r.size)
+		return fmt.Errorf("syncing the journal: %w", err)
+	}
+
+	r.size += int64(len(line))
+	r.records++
+
+	return nil
+}
+
+// Writes a snapshot once the journal has SnapshotInterval records
+func (r *FileDeckRepository) compact() {
+
+	// The change is already durable, so a failed snapshot
+	// is only retried with the next record
+	if r.records >= r.SnapshotInterval {
+		r.snapshot()
+	}
+}
+
+// Writes every deck to a new snapshot file and truncates the
+// journal. The snapshot is renamed over the old one once it is
+// on disk, so a crash at any point leaves a valid snapshot
+func (r *FileDeckRepository) snapshot() error {
+
+	decks := make([]*Deck, 0, len(r.persisted))
+	for _, deck := range r.persisted {
+		decks = append(decks, deck)
+	}
+
+	content, err := json.Marshal(decks)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.dir, snapshotFileName)
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	if err := syncDir(r.dir); err != nil {
+		return err
+	}
+
+	// Replaying the journal over the new snapshot would be
+	// harmless, so a crash before truncating it is safe
+	if err := r.rewind(0); err != nil {
+		return err
+	}
+
+	r.size = 0
+	r.records = 0
+
+	return r.journal.Sync()
+}
+
+// Syncs a directory so that renamed files are on disk
+func syncDir(dir string) error {
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -7,18 +7,20 @@
 package data
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
 // Constants
 const MaxCards int = 52
 
-// CardValue Enum definition
+// CardValue Enum definition. Values match the rank
+// of the card so that Ace is 1 and King is 13
 type CardValue int
 
 const (
-	Ace CardValue = iota
-	One
+	Ace CardValue = iota + 1
 	Two
 	Three
 	Four
@@ -27,9 +29,11 @@ const (
 	Seven
 	Eight
 	Nine
+	Ten
 	Jack
 	Queen
 	King
+	Joker
 )
 
 // Translates each enum value to its string representation
@@ -38,8 +42,6 @@ func (v CardValue) String() string {
 	switch v {
 	case Ace:
 		return "ACE"
-	case One:
-		return "1"
 	case Two:
 		return "2"
 	case Three:
@@ -56,17 +58,22 @@ func (v CardValue) String() string {
 		return "8"
 	case Nine:
 		return "9"
+	case Ten:
+		return "10"
 	case Jack:
 		return "JACK"
 	case Queen:
 		return "QUEEN"
 	case King:
 		return "KING"
+	case Joker:
+		return "JOKER"
 	}
 	return "Unknown"
 }
 
-// CardSuit enum definition
+// CardSuit enum definition. Jokers have no suit, so
+// they use Black or Red instead
 type CardSuit int
 
 const (
@@ -74,6 +81,8 @@ const (
 	Diamonds
 	Clubs
 	Hearts
+	Black
+	Red
 )
 
 // Translates each enum value to its string representation
@@ -88,21 +97,212 @@ func (s CardSuit) String() string {
 		return "CLUBS"
 	case Hearts:
 		return "HEARTS"
+	case Black:
+		return "BLACK"
+	case Red:
+		return "RED"
 	}
 	return "Unknown"
 }
 
-// Card type definition
+// Card type definition. Instance identifies each physical
+// card inside a deck, as decks made of several copies of the
+// same cards (i.e. shoes) contain repeated codes
 type Card struct {
-	Value CardValue
-	Suit  CardSuit
-	Code  string
+	Value    CardValue
+	Suit     CardSuit
+	Code     string
+	Instance int
 }
 
-// Deck type definition
+// Deck type definition. Version starts at 1 and the repository
+// increments it with every change, so that clients can tell if
+// the deck they saw is still current. CutCard is the amount of
+// remaining cards at which the cut card is reached, 0 if none.
+// Seed is the seed the cards were shuffled with when Seeded,
+// so that the order can be reproduced. The order of the cards
+// left in HideOrder decks is only shown to their owner.
+//
+// Drawn holds the cards drawn and not returned yet, in the
+// order they were drawn, so that they can be put back. Burned
+// holds the cards discarded from the top without dealing them.
+// Piles hold the cards dealt to named piles. Every card is in
+// only one of Cards, Drawn, Burned or a pile.
+//
+// Owner identifies the player that dealt the deck, who can see
+// all its cards. Decks with no owner have no hidden cards.
+// CreatedAt and Tags describe the deck, to find it among the
+// rest (see DeckQuery).
+//
+// Fair decks are shuffled with the server and client seeds
+// (see package fairness). ServerSeed must be kept secret until
+// the deck is closed or has no cards left
 type Deck struct {
-	Id        uuid.UUID
-	Shuffled  bool
-	Remaining int
-	Cards     []Card
+	Id          uuid.UUID
+	Version     int64
+	Composition string
+	Jokers      int
+	Decks       int
+	Shuffled    bool
+	Remaining   int
+	CutCard     int
+	Seeded      bool
+	Seed        int64
+	Fair        bool
+	ServerSeed  string
+	ClientSeed  string
+	Commitment  string
+	Closed      bool
+	HideOrder   bool
+	Owner       string
+	CreatedAt   time.Time
+	Tags        []string
+	Cards       []Card
+	Drawn       []Card
+	Burned      []Card
+	Piles       []Pile
+	Responses   []StoredResponse
+}
+
+// Named pile of cards taken from a deck, i.e. a hand or a
+// discard pile. Cards are sorted in the order they were added.
+// Owner is the player holding the pile, if any, and the cards
+// of FaceUp piles can be seen by every player
+type Pile struct {
+	Name   string
+	Owner  string
+	FaceUp bool
+	Cards  []Card
+}
+
+// Response to a request made with an idempotency key, stored
+// with the deck the request created or changed so that retries
+// get the same response. Fingerprint identifies the parameters
+// of the request, and the response is kept until ExpiresAt
+type StoredResponse struct {
+	Key         string
+	Fingerprint string
+	Status      int
+	Header      map[string][]string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// Returns a deep copy of the deck that shares no memory
+// with the original one
+func (d *Deck) Clone() *Deck {
+
+	clone := *d
+	clone.Cards = cloneCards(d.Cards)
+	clone.Drawn = cloneCards(d.Drawn)
+	clone.Burned = cloneCards(d.Burned)
+	clone.Piles = clonePiles(d.Piles)
+	clone.Responses = cloneResponses(d.Responses)
+	clone.Tags = cloneTags(d.Tags)
+
+	return &clone
+}
+
+// Returns a copy of a cards slice
+func cloneCards(cards []Card) []Card {
+
+	if cards == nil {
+		return nil
+	}
+
+	result := make([]Card, len(cards))
+	copy(result, cards)
+
+	return result
+}
+
+// Returns a copy of a tags slice
+func cloneTags(tags []string) []string {
+
+	if tags == nil {
+		return nil
+	}
+
+	return append([]string{}, tags...)
+}
+
+// Returns a deep copy of a piles slice
+func clonePiles(piles []Pile) []Pile {
+
+	if piles == nil {
+		return nil
+	}
+
+	result := make([]Pile, len(piles))
+	for i, v := range piles {
+		result[i] = v
+		result[i].Cards = cloneCards(v.Cards)
+	}
+
+	return result
+}
+
+// Returns a deep copy of a stored responses slice
+func cloneResponses(responses []StoredResponse) []StoredResponse {
+
+	if responses == nil {
+		return nil
+	}
+
+	result := make([]StoredResponse, len(responses))
+	for i, v := range responses {
+		result[i] = *v.Clone()
+	}
+
+	return result
+}
+
+// Returns a deep copy of the response
+func (r *StoredResponse) Clone() *StoredResponse {
+
+	clone := *r
+
+	if r.Header != nil {
+		clone.Header = make(map[string][]string, len(r.Header))
+		for k, v := range r.Header {
+			clone.Header[k] = append([]string(nil), v...)
+		}
+	}
+
+	if r.Body != nil {
+		clone.Body = append([]byte(nil), r.Body...)
+	}
+
+	return &clone
+}
+
+// Returns the pile with the name, or nil if there is none
+func (d *Deck) GetPile(name string) *Pile {
+
+	for i := range d.Piles {
+		if d.Piles[i].Name == name {
+			return &d.Piles[i]
+		}
+	}
+
+	return nil
+}
+
+// Returns true if the deck has the tag
+func (d *Deck) HasTag(tag string) bool {
+
+	for _, v := range d.Tags {
+		if v == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true when the cut card has been reached and the
+// deck should be reshuffled before dealing a new round
+func (d *Deck) NeedsReshuffle() bool {
+	return d.CutCard > 0 && d.Remaining <= d.CutCard
 }
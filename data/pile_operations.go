@@ -0,0 +1,178 @@
+// Author: Ferran Balaguer
+
+// Operations over the piles of a deck shared by every
+// DeckRepository implementation. Cards are only moved between
+// the deck and its piles, never copied, so every card is always
+// in one place. They must be called with the deck already locked
+
+package data
+
+import (
+	"errors"
+)
+
+var (
+	ErrPileNotFound = errors.New("Pile not found")
+	ErrPileExists   = errors.New("Pile already exists")
+)
+
+// Returns the pile with the name or ErrPileNotFound
+func findPile(deck *Deck, name string) (*Pile, error) {
+
+	pile := deck.GetPile(name)
+	if pile == nil {
+		return nil, ErrPileNotFound
+	}
+
+	return pile, nil
+}
+
+// Adds a new empty pile to the deck, ignoring the cards
+// of the supplied one. Closed decks can't be changed
+func createPile(deck *Deck, pile Pile) error {
+
+	if deck.Closed {
+		return ErrClosed
+	}
+
+	if pile.Name == "" {
+		return ErrInvalidParameters
+	}
+
+	if deck.GetPile(pile.Name) != nil {
+		return ErrPileExists
+	}
+
+	pile.Cards = []Card{}
+	deck.Piles = append(deck.Piles, pile)
+
+	return nil
+}
+
+// Moves amount cards from the position of the deck to the
+// pile, and returns a copy of them
+func drawCardsToPile(deck *Deck, name string, amount int, position Position, random func(n int) int) ([]Card, error) {
+
+	pile, err := findPile(deck, name)
+	if err != nil {
+		return nil, err
+	}
+
+	cards, err := takeCards(deck, amount, position, random)
+	if err != nil {
+		return nil, err
+	}
+
+	pile.Cards = append(pile.Cards, cards...)
+
+	return cloneCards(cards), nil
+}
+
+// Moves cards from one pile to the end of another. If codes is
+// nil every card is moved, otherwise the first card of each code.
+// Closed decks can't be changed
+func movePileCards(deck *Deck, from string, to string, codes []string) error {
+
+	if deck.Closed {
+		return ErrClosed
+	}
+
+	if from == to {
+		return ErrInvalidParameters
+	}
+
+	source, err := findPile(deck, from)
+	if err != nil {
+		return err
+	}
+
+	target, err := findPile(deck, to)
+	if err != nil {
+		return err
+	}
+
+	moved := source.Cards
+	left := []Card{}
+
+	if codes != nil {
+		moved, left, err = takeCodes(source.Cards, codes)
+		if err != nil {
+			return err
+		}
+	}
+
+	target.Cards = append(target.Cards, moved...)
+	source.Cards = left
+
+	return nil
+}
+
+// Removes the first card of each code from cards. It returns
+// the removed cards, in the order of the codes, and the rest
+func takeCodes(cards []Card, codes []string) ([]Card, []Card, error) {
+
+	left := cloneCards(cards)
+	taken := make([]Card, len(codes))
+
+	for i, code := range codes {
+		j := 0
+		for j < len(left) && left[j].Code != code {
+			j++
+		}
+
+		if j == len(left) {
+			return nil, nil, ErrCardNotFound
+		}
+
+		taken[i] = left[j]
+		left = append(left[:j], left[j+1:]...)
+	}
+
+	return taken, left, nil
+}
+
+// Reorders the cards of a pile so that position i holds
+// the card at position permutation[i]. Closed decks can't
+// be changed
+func shufflePile(deck *Deck, name string, permutation func(n int) []int) error {
+
+	if deck.Closed {
+		return ErrClosed
+	}
+
+	pile, err := findPile(deck, name)
+	if err != nil {
+		return err
+	}
+
+	shuffled, err := permute(pile.Cards, permutation(len(pile.Cards)))
+	if err != nil {
+		return err
+	}
+
+	pile.Cards = shuffled
+
+	return nil
+}
+
+// Puts every card of a pile back in the deck at the position,
+// in the order of the pile, and leaves the pile empty
+func returnPile(deck *Deck, name string, position Position, random func(n int) int) error {
+
+	if deck.Closed {
+		return ErrClosed
+	}
+
+	pile, err := findPile(deck, name)
+	if err != nil {
+		return err
+	}
+
+	if err := placeCards(deck, pile.Cards, position, random); err != nil {
+		return err
+	}
+
+	pile.Cards = []Card{}
+
+	return nil
+}
@@ -0,0 +1,1781 @@
+// Author: Ferran Balaguer
+
+// Package repotest provides a conformance test suite for
+// data.DeckRepository implementations. Any implementation,
+// including the ones outside this module, can check that it
+// behaves like the built-in ones by calling Run from a test:
+//
+//	func TestMyRepository(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) data.DeckRepository {
+//			return NewMyRepository()
+//		})
+//	}
+
+package repotest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"test/cardsgame/data"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Returns a new standard deck sorted by suit and value,
+// where each card instance is its position plus one
+func NewStandardDeck() data.Deck {
+
+	deck := data.Deck{Id: uuid.New(), Composition: "standard", Decks: 1}
+	for s := data.Spades; s <= data.Hearts; s++ {
+		for v := data.Ace; v <= data.King; v++ {
+			deck.Cards = append(deck.Cards, data.Card{
+				Value:    v,
+				Suit:     s,
+				Code:     data.CardCode(v, s),
+				Instance: len(deck.Cards) + 1,
+			})
+		}
+	}
+	deck.Remaining = len(deck.Cards)
+
+	return deck
+}
+
+// Runs every conformance test as a subtest of t. newRepository
+// is called once per subtest and must return an empty repository
+func Run(t *testing.T, newRepository func(t *testing.T) data.DeckRepository) {
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repository data.DeckRepository)
+	}{
+		{"AddAndGet", testAddAndGet},
+		{"GetNotFound", testGetNotFound},
+		{"GetDeckCardByCode", testGetDeckCardByCode},
+		{"DrawOrder", testDrawOrder},
+		{"DrawAll", testDrawAll},
+		{"DrawErrors", testDrawErrors},
+		{"DrawAt", testDrawAt},
+		{"DrawAtErrors", testDrawAtErrors},
+		{"DrawByCode", testDrawByCode},
+		{"DrawByCodeErrors", testDrawByCodeErrors},
+		{"PeekCards", testPeekCards},
+		{"BurnCards", testBurnCards},
+		{"CloseDeck", testCloseDeck},
+		{"DeleteDeck", testDeleteDeck},
+		{"ShuffleDeck", testShuffleDeck},
+		{"ShuffleErrors", testShuffleErrors},
+		{"ShuffleIncludeDrawn", testShuffleIncludeDrawn},
+		{"ReturnCards", testReturnCards},
+		{"PositionRandom", testPositionRandom},
+		{"ReturnErrors", testReturnErrors},
+		{"CutDeck", testCutDeck},
+		{"SeedDeck", testSeedDeck},
+		{"CreatePile", testCreatePile},
+		{"DrawToPile", testDrawToPile},
+		{"MovePileCards", testMovePileCards},
+		{"ShufflePile", testShufflePile},
+		{"ReturnPile", testReturnPile},
+		{"PileCardsConserved", testPileCardsConserved},
+		{"StoredResponses", testStoredResponses},
+		{"ResponsesExpire", testResponsesExpire},
+		{"Versions", testVersions},
+		{"UpdateIfVersion", testUpdateIfVersion},
+		{"UpdateIfVersionDelete", testUpdateIfVersionDelete},
+		{"ConcurrentUpdateIfVersion", testConcurrentUpdateIfVersion},
+		{"ListDecks", testListDecks},
+		{"ListDecksPages", testListDecksPages},
+		{"Snapshots", testSnapshots},
+		{"ConcurrentDraws", testConcurrentDraws},
+		{"ConcurrentDecks", testConcurrentDecks},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.fn(t, newRepository(t))
+		})
+	}
+}
+
+// Adds a deck or fails the test
+func mustAdd(t *testing.T, repository data.DeckRepository, deck data.Deck) {
+
+	if err := repository.Add(deck); err != nil {
+		t.Fatalf("There should be no error adding the deck: %v", err)
+	}
+}
+
+// Returns true if both card slices have the same cards in
+// the same order. Nil and empty slices are the same
+func sameCards(a []data.Card, b []data.Card) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i, v := range a {
+		if b[i] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the codes of the cards
+func codes(cards []data.Card) []string {
+
+	result := make([]string, len(cards))
+	for i, v := range cards {
+		result[i] = v.Code
+	}
+
+	return result
+}
+
+// Every field of an added deck is kept
+func testAddAndGet(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	deck.Shuffled = true
+	deck.CutCard = 10
+	deck.Seeded = true
+	deck.Seed = -1234567890123
+	deck.Jokers = 2
+	deck.Fair = true
+	deck.ServerSeed = "server"
+	deck.ClientSeed = "client"
+	deck.Commitment = "commitment"
+	deck.Owner = "dealer"
+	deck.HideOrder = true
+	deck.Drawn = deck.Cards[:2]
+	deck.Burned = deck.Cards[2:3]
+	deck.Piles = []data.Pile{
+		{Name: "north", Owner: "alice", Cards: deck.Cards[3:5]},
+		{Name: "discard", FaceUp: true, Cards: []data.Card{}},
+	}
+	deck.Cards = deck.Cards[5:]
+	deck.Remaining = len(deck.Cards)
+	mustAdd(t, repository, deck)
+
+	stored, err := repository.GetDeckById(deck.Id)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if stored.Id != deck.Id || stored.Composition != deck.Composition || stored.Decks != deck.Decks ||
+		stored.Shuffled != deck.Shuffled || stored.Remaining != deck.Remaining || stored.CutCard != deck.CutCard ||
+		stored.Seeded != deck.Seeded || stored.Seed != deck.Seed || stored.Jokers != deck.Jokers || stored.Fair != deck.Fair ||
+		stored.ServerSeed != deck.ServerSeed || stored.ClientSeed != deck.ClientSeed ||
+		stored.Commitment != deck.Commitment || stored.Closed != deck.Closed || stored.Owner != deck.Owner ||
+		stored.HideOrder != deck.HideOrder {
+		t.Errorf("Stored deck %+v is different from the added one", *stored)
+	}
+
+	if len(stored.Cards) != len(deck.Cards) {
+		t.Fatalf("Stored deck should have %d cards, got %d", len(deck.Cards), len(stored.Cards))
+	}
+
+	for i, v := range deck.Cards {
+		if stored.Cards[i] != v {
+			t.Errorf("Card %d should be %v, got %v", i, v, stored.Cards[i])
+		}
+	}
+
+	if !sameCards(stored.Drawn, deck.Drawn) {
+		t.Errorf("Stored drawn cards should be %v, got %v", deck.Drawn, stored.Drawn)
+	}
+
+	if !sameCards(stored.Burned, deck.Burned) {
+		t.Errorf("Stored burned cards should be %v, got %v", deck.Burned, stored.Burned)
+	}
+
+	if len(stored.Piles) != len(deck.Piles) {
+		t.Fatalf("Stored deck should have %d piles, got %d", len(deck.Piles), len(stored.Piles))
+	}
+
+	for i, v := range deck.Piles {
+		if stored.Piles[i].Name != v.Name || stored.Piles[i].Owner != v.Owner || stored.Piles[i].FaceUp != v.FaceUp ||
+			!sameCards(stored.Piles[i].Cards, v.Cards) {
+			t.Errorf("Pile %d should be %v, got %v", i, v, stored.Piles[i])
+		}
+	}
+}
+
+// Unknown decks return ErrNotFound
+func testGetNotFound(t *testing.T, repository data.DeckRepository) {
+
+	mustAdd(t, repository, NewStandardDeck())
+
+	if _, err := repository.GetDeckById(uuid.New()); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// GetDeckCardByCode only finds the cards still in the
+// deck, and doesn't remove them
+func testGetDeckCardByCode(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	card, err := repository.GetDeckCardByCode(deck.Id, "KH")
+	if err != nil {
+		t.Fatalf("Should have found KH: %v", err)
+	}
+
+	if *card != deck.Cards[data.MaxCards-1] {
+		t.Errorf("Should have returned %v, got %v", deck.Cards[data.MaxCards-1], *card)
+	}
+
+	if _, err := repository.GetDeckCardByCode(deck.Id, "KH"); err != nil {
+		t.Errorf("The card should still be in the deck")
+	}
+
+	repository.DrawCardsFromDeck(deck.Id, 1)
+	if _, err := repository.GetDeckCardByCode(deck.Id, "AS"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("A drawn card should return %v, got %v", data.ErrNotFound, err)
+	}
+
+	if _, err := repository.GetDeckCardByCode(deck.Id, "ZZ"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("An unknown code should return %v, got %v", data.ErrNotFound, err)
+	}
+
+	if _, err := repository.GetDeckCardByCode(uuid.New(), "KH"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("An unknown deck should return %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// Cards are drawn from the top, in order
+func testDrawOrder(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	first, _ := repository.DrawCardsFromDeck(deck.Id, 3)
+	second, _ := repository.DrawCardsFromDeck(deck.Id, 2)
+	drawn := append(first, second...)
+
+	if len(drawn) != 5 {
+		t.Fatalf("5 cards should have been drawn, got %d", len(drawn))
+	}
+
+	for i, v := range drawn {
+		if v != deck.Cards[i] {
+			t.Errorf("Card %d should be %v, got %v", i, deck.Cards[i].Code, v.Code)
+		}
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if stored.Remaining != data.MaxCards-5 || len(stored.Cards) != data.MaxCards-5 {
+		t.Errorf("Remaining cards must be = %d", data.MaxCards-5)
+	}
+
+	if stored.Cards[0] != deck.Cards[5] {
+		t.Errorf("First remaining card should be %v, got %v", deck.Cards[5].Code, stored.Cards[0].Code)
+	}
+
+	if !sameCards(stored.Drawn, deck.Cards[:5]) {
+		t.Errorf("Drawn cards should be %v, got %v", codes(deck.Cards[:5]), codes(stored.Drawn))
+	}
+}
+
+// The last cards can be drawn, and then the deck is empty
+func testDrawAll(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	repository.DrawCardsFromDeck(deck.Id, 50)
+
+	if cards, err := repository.DrawCardsFromDeck(deck.Id, 2); err != nil || len(cards) != 2 {
+		t.Errorf("The last 2 cards should have been drawn: %v", err)
+	}
+
+	if _, err := repository.DrawCardsFromDeck(deck.Id, 1); !errors.Is(err, data.ErrTruncate) {
+		t.Errorf("An empty deck should return %v, got %v", data.ErrTruncate, err)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if stored.Remaining != 0 || len(stored.Cards) != 0 {
+		t.Errorf("The deck should be empty")
+	}
+}
+
+// Invalid draws return an error and don't modify the deck
+func testDrawErrors(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	for _, amount := range []int{0, -1} {
+		if _, err := repository.DrawCardsFromDeck(deck.Id, amount); !errors.Is(err, data.ErrInvalidParameters) {
+			t.Errorf("Amount %d should return %v, got %v", amount, data.ErrInvalidParameters, err)
+		}
+	}
+
+	if _, err := repository.DrawCardsFromDeck(deck.Id, data.MaxCards+1); !errors.Is(err, data.ErrTruncate) {
+		t.Errorf("Should have returned %v, got %v", data.ErrTruncate, err)
+	}
+
+	if _, err := repository.DrawCardsFromDeck(uuid.New(), 1); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if stored.Remaining != data.MaxCards || len(stored.Cards) != data.MaxCards {
+		t.Errorf("Failed draws should not modify the deck")
+	}
+}
+
+// Cards are drawn from the bottom in order, and from the
+// positions returned by the random function
+func testDrawAt(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	bottom, err := repository.DrawCardsAt(deck.Id, 2, data.PositionBottom, nil)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if !sameCards(bottom, []data.Card{deck.Cards[51], deck.Cards[50]}) {
+		t.Errorf("The bottom cards should have been drawn, got %v", codes(bottom))
+	}
+
+	var lengths []int
+	random := func(n int) int {
+		lengths = append(lengths, n)
+		return 1
+	}
+
+	drawn, err := repository.DrawCardsAt(deck.Id, 2, data.PositionRandom, random)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if !sameCards(drawn, []data.Card{deck.Cards[1], deck.Cards[2]}) {
+		t.Errorf("The cards at the random positions should have been drawn, got %v", codes(drawn))
+	}
+
+	if len(lengths) != 2 || lengths[0] != data.MaxCards-2 || lengths[1] != data.MaxCards-3 {
+		t.Errorf("random should be called once per card with the cards left, got %v", lengths)
+	}
+
+	top, _ := repository.DrawCardsAt(deck.Id, 1, data.PositionTop, nil)
+	if !sameCards(top, deck.Cards[:1]) {
+		t.Errorf("The top card should have been drawn, got %v", codes(top))
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if stored.Remaining != data.MaxCards-5 || !sameCards(stored.Cards, deck.Cards[3:50]) {
+		t.Errorf("The deck should have the cards left, got %v", codes(stored.Cards))
+	}
+
+	if !sameCards(stored.Drawn, append(append(bottom, drawn...), top...)) {
+		t.Errorf("The drawn cards should be in the order they were drawn, got %v", codes(stored.Drawn))
+	}
+}
+
+// Invalid draws from a position don't modify the deck
+func testDrawAtErrors(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	outOfRange := func(n int) int { return n }
+	if _, err := repository.DrawCardsAt(deck.Id, 2, data.PositionRandom, outOfRange); !errors.Is(err, data.ErrInvalidParameters) {
+		t.Errorf("An invalid random position should return %v, got %v", data.ErrInvalidParameters, err)
+	}
+
+	if _, err := repository.DrawCardsAt(deck.Id, 1, data.Position(-1), nil); !errors.Is(err, data.ErrInvalidParameters) {
+		t.Errorf("An invalid position should return %v, got %v", data.ErrInvalidParameters, err)
+	}
+
+	if _, err := repository.DrawCardsAt(deck.Id, data.MaxCards+1, data.PositionBottom, nil); !errors.Is(err, data.ErrTruncate) {
+		t.Errorf("Should have returned %v, got %v", data.ErrTruncate, err)
+	}
+
+	if _, err := repository.DrawCardsAt(uuid.New(), 1, data.PositionBottom, nil); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if !sameCards(stored.Cards, deck.Cards) || len(stored.Drawn) != 0 {
+		t.Errorf("Failed draws should not modify the deck")
+	}
+}
+
+// Cards are drawn by code, the first one of each code
+func testDrawByCode(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	deck.Cards = append(deck.Cards, deck.Cards[0])
+	deck.Cards[data.MaxCards].Instance = data.MaxCards + 1
+	deck.Remaining = len(deck.Cards)
+	mustAdd(t, repository, deck)
+
+	drawn, err := repository.DrawCardsByCode(deck.Id, []string{"KH", "AS", "AS"})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if !sameCards(drawn, []data.Card{deck.Cards[51], deck.Cards[0], deck.Cards[52]}) {
+		t.Errorf("The cards with the codes should have been drawn, got %v", drawn)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if stored.Remaining != data.MaxCards-2 || !sameCards(stored.Cards, deck.Cards[1:51]) {
+		t.Errorf("The deck should have the cards left, got %v", codes(stored.Cards))
+	}
+
+	if !sameCards(stored.Drawn, drawn) {
+		t.Errorf("The drawn cards should be %v, got %v", codes(drawn), codes(stored.Drawn))
+	}
+}
+
+// Drawing by code fails atomically if any card is missing
+func testDrawByCodeErrors(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	for _, codes := range [][]string{{"AS", "ZZ"}, {"KH", "KH"}} {
+		if _, err := repository.DrawCardsByCode(deck.Id, codes); !errors.Is(err, data.ErrCardNotFound) {
+			t.Errorf("Drawing %v should return %v, got %v", codes, data.ErrCardNotFound, err)
+		}
+	}
+
+	if _, err := repository.DrawCardsByCode(deck.Id, nil); !errors.Is(err, data.ErrInvalidParameters) {
+		t.Errorf("Should have returned %v, got %v", data.ErrInvalidParameters, err)
+	}
+
+	if _, err := repository.DrawCardsByCode(uuid.New(), []string{"AS"}); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if !sameCards(stored.Cards, deck.Cards) || len(stored.Drawn) != 0 {
+		t.Errorf("Failed draws should not modify the deck")
+	}
+
+	repository.CloseDeck(deck.Id)
+	if _, err := repository.DrawCardsByCode(deck.Id, []string{"AS"}); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+}
+
+// Peeking returns the top cards without removing them
+func testPeekCards(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	peeked, err := repository.PeekCards(deck.Id, 3)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if !sameCards(peeked, deck.Cards[:3]) {
+		t.Errorf("The top cards should be %v, got %v", codes(deck.Cards[:3]), codes(peeked))
+	}
+
+	peeked[0].Code = "ZZ"
+	stored, _ := repository.GetDeckById(deck.Id)
+	if !sameCards(stored.Cards, deck.Cards) || len(stored.Drawn) != 0 {
+		t.Errorf("Peeking should not modify the deck")
+	}
+
+	for _, amount := range []int{0, -1} {
+		if _, err := repository.PeekCards(deck.Id, amount); !errors.Is(err, data.ErrInvalidParameters) {
+			t.Errorf("Amount %d should return %v, got %v", amount, data.ErrInvalidParameters, err)
+		}
+	}
+
+	if _, err := repository.PeekCards(deck.Id, data.MaxCards+1); !errors.Is(err, data.ErrTruncate) {
+		t.Errorf("Should have returned %v, got %v", data.ErrTruncate, err)
+	}
+
+	if _, err := repository.PeekCards(uuid.New(), 1); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// Burning moves the top cards to the burn pile
+func testBurnCards(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	burned, err := repository.BurnCards(deck.Id, 2)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if burned.Remaining != data.MaxCards-2 || !sameCards(burned.Burned, deck.Cards[:2]) || len(burned.Drawn) != 0 {
+		t.Errorf("The top cards should be burned, got %v", codes(burned.Burned))
+	}
+
+	repository.BurnCards(deck.Id, 1)
+	stored, _ := repository.GetDeckById(deck.Id)
+	if !sameCards(stored.Cards, deck.Cards[3:]) || !sameCards(stored.Burned, deck.Cards[:3]) {
+		t.Errorf("The stored deck should have 3 burned cards, got %v", codes(stored.Burned))
+	}
+
+	if _, err := repository.ReturnCards(deck.Id, []string{"AS"}, data.PositionTop, nil); !errors.Is(err, data.ErrNotDrawn) {
+		t.Errorf("Burned cards can't be returned, got %v", err)
+	}
+
+	for _, amount := range []int{0, -1} {
+		if _, err := repository.BurnCards(deck.Id, amount); !errors.Is(err, data.ErrInvalidParameters) {
+			t.Errorf("Amount %d should return %v, got %v", amount, data.ErrInvalidParameters, err)
+		}
+	}
+
+	if _, err := repository.BurnCards(deck.Id, data.MaxCards); !errors.Is(err, data.ErrTruncate) {
+		t.Errorf("Should have returned %v, got %v", data.ErrTruncate, err)
+	}
+
+	if _, err := repository.BurnCards(uuid.New(), 1); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	repository.CloseDeck(deck.Id)
+	if _, err := repository.BurnCards(deck.Id, 1); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+}
+
+// Closed decks keep their cards but can't be drawn
+func testCloseDeck(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+	repository.DrawCardsFromDeck(deck.Id, 2)
+
+	closed, err := repository.CloseDeck(deck.Id)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if !closed.Closed || closed.Remaining != data.MaxCards-2 {
+		t.Errorf("The returned deck should be closed and keep its cards")
+	}
+
+	if _, err := repository.DrawCardsFromDeck(deck.Id, 1); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+
+	if _, err := repository.CloseDeck(deck.Id); err != nil {
+		t.Errorf("Closing a closed deck should not fail: %v", err)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if !stored.Closed || stored.Remaining != data.MaxCards-2 {
+		t.Errorf("The stored deck should be closed and keep its cards")
+	}
+
+	if _, err := repository.CloseDeck(uuid.New()); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// Deleted decks are removed with their cards and piles,
+// and the other decks are kept
+func testDeleteDeck(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	other := NewStandardDeck()
+	mustAdd(t, repository, deck)
+	mustAdd(t, repository, other)
+	repository.CreatePile(deck.Id, data.Pile{Name: "north"})
+	repository.DrawCardsToPile(deck.Id, "north", 2, data.PositionTop, nil)
+	repository.DrawCardsFromDeck(deck.Id, 1)
+	repository.BurnCards(deck.Id, 1)
+
+	if err := repository.DeleteDeck(deck.Id); err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if _, err := repository.GetDeckById(deck.Id); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	if _, err := repository.DrawCardsFromDeck(deck.Id, 1); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	if err := repository.DeleteDeck(deck.Id); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	if stored, err := repository.GetDeckById(other.Id); err != nil || stored.Remaining != data.MaxCards {
+		t.Errorf("The other deck should be kept, got %v", err)
+	}
+
+	// The id can be used again, with none of the old cards
+	mustAdd(t, repository, deck)
+	stored, _ := repository.GetDeckById(deck.Id)
+	if stored.Remaining != data.MaxCards || len(stored.Drawn) != 0 || len(stored.Burned) != 0 || len(stored.Piles) != 0 {
+		t.Errorf("The added deck should not have the deleted cards, got %+v", stored)
+	}
+}
+
+// Shuffling reorders the cards left with the permutation
+func testShuffleDeck(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	deck.Seeded = true
+	deck.Seed = 42
+	mustAdd(t, repository, deck)
+	repository.DrawCardsFromDeck(deck.Id, 2)
+
+	length := 0
+	reverse := func(n int) []int {
+		length = n
+		order := make([]int, n)
+		for i := range order {
+			order[i] = n - 1 - i
+		}
+		return order
+	}
+
+	shuffled, err := repository.ShuffleDeck(deck.Id, false, reverse)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if length != data.MaxCards-2 {
+		t.Errorf("The permutation should be for %d cards, got %d", data.MaxCards-2, length)
+	}
+
+	if !shuffled.Shuffled || shuffled.Seeded || shuffled.Remaining != data.MaxCards-2 {
+		t.Errorf("The deck should be shuffled, not seeded and keep its cards")
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	for i, v := range stored.Cards {
+		if v != deck.Cards[data.MaxCards-1-i] {
+			t.Errorf("Card %d should be %v, got %v", i, deck.Cards[data.MaxCards-1-i].Code, v.Code)
+		}
+	}
+
+	if shuffled.Cards[0] != stored.Cards[0] {
+		t.Errorf("The returned deck should have the shuffled cards")
+	}
+}
+
+// Invalid permutations and closed decks return an error
+// and don't modify the deck
+func testShuffleErrors(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	identity := func(n int) []int {
+		order := make([]int, n)
+		for i := range order {
+			order[i] = i
+		}
+		return order
+	}
+
+	invalid := map[string]func(n int) []int{
+		"short":        func(n int) []int { return identity(n - 1) },
+		"repeated":     func(n int) []int { return make([]int, n) },
+		"out of range": func(n int) []int { return append(identity(n-1), n) },
+	}
+
+	for name, permutation := range invalid {
+		if _, err := repository.ShuffleDeck(deck.Id, false, permutation); !errors.Is(err, data.ErrInvalidParameters) {
+			t.Errorf("A %s permutation should return %v, got %v", name, data.ErrInvalidParameters, err)
+		}
+	}
+
+	if _, err := repository.ShuffleDeck(uuid.New(), false, func(n int) []int { return nil }); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	for i, v := range stored.Cards {
+		if v != deck.Cards[i] {
+			t.Fatalf("Failed shuffles should not modify the deck")
+		}
+	}
+
+	repository.CloseDeck(deck.Id)
+	if _, err := repository.ShuffleDeck(deck.Id, false, identity); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+}
+
+// Shuffling can put the drawn cards back in the deck first
+func testShuffleIncludeDrawn(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+	repository.DrawCardsFromDeck(deck.Id, 3)
+	repository.BurnCards(deck.Id, 2)
+
+	length := 0
+	identity := func(n int) []int {
+		length = n
+		order := make([]int, n)
+		for i := range order {
+			order[i] = i
+		}
+		return order
+	}
+
+	shuffled, err := repository.ShuffleDeck(deck.Id, true, identity)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if length != data.MaxCards || shuffled.Remaining != data.MaxCards || len(shuffled.Drawn) != 0 || len(shuffled.Burned) != 0 {
+		t.Errorf("Every card should be back in the deck")
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if !sameCards(stored.Cards, deck.Cards) || len(stored.Drawn) != 0 || len(stored.Burned) != 0 {
+		t.Errorf("The drawn and burned cards should be on top of the deck")
+	}
+}
+
+// Drawn cards are put back on top or at the bottom, in the
+// order they are returned
+func testReturnCards(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	deck.Seeded = true
+	mustAdd(t, repository, deck)
+	repository.DrawCardsFromDeck(deck.Id, 5)
+
+	returned, err := repository.ReturnCards(deck.Id, []string{"3S", "AS"}, data.PositionTop, nil)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if returned.Remaining != data.MaxCards-3 || len(returned.Cards) != data.MaxCards-3 || returned.Seeded {
+		t.Errorf("The deck should have %d cards and not be seeded", data.MaxCards-3)
+	}
+
+	expected := append([]data.Card{deck.Cards[2], deck.Cards[0]}, deck.Cards[5:]...)
+	if !sameCards(returned.Cards, expected) {
+		t.Errorf("The cards should be returned on top, got %v", codes(returned.Cards[:3]))
+	}
+
+	if !sameCards(returned.Drawn, []data.Card{deck.Cards[1], deck.Cards[3], deck.Cards[4]}) {
+		t.Errorf("The returned cards should not be drawn, got %v", codes(returned.Drawn))
+	}
+
+	// Every drawn card, in the order they were drawn
+	returned, err = repository.ReturnCards(deck.Id, nil, data.PositionBottom, nil)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	expected = append(expected, deck.Cards[1], deck.Cards[3], deck.Cards[4])
+	if !sameCards(returned.Cards, expected) || returned.Remaining != data.MaxCards || len(returned.Drawn) != 0 {
+		t.Errorf("The cards should be returned at the bottom, got %v", codes(returned.Cards[data.MaxCards-3:]))
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if !sameCards(stored.Cards, expected) || len(stored.Drawn) != 0 {
+		t.Errorf("The stored deck should have the returned cards")
+	}
+
+	if returned.Shuffled {
+		t.Errorf("Returning cards on top or at the bottom should not shuffle the deck")
+	}
+}
+
+// Cards returned at random are placed at the positions given
+// by the random function, and the deck becomes shuffled
+func testPositionRandom(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+	repository.DrawCardsFromDeck(deck.Id, 2)
+
+	var lengths []int
+	random := func(n int) int {
+		lengths = append(lengths, n)
+		return 1
+	}
+
+	returned, err := repository.ReturnCards(deck.Id, nil, data.PositionRandom, random)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if len(lengths) != 2 || lengths[0] != data.MaxCards-1 || lengths[1] != data.MaxCards {
+		t.Errorf("random should be called once per card with the positions available, got %v", lengths)
+	}
+
+	if returned.Cards[1] != deck.Cards[1] || returned.Cards[2] != deck.Cards[0] || !returned.Shuffled {
+		t.Errorf("The cards should be returned at the random positions, got %v", codes(returned.Cards[:3]))
+	}
+}
+
+// Invalid returns fail and don't modify the deck
+func testReturnErrors(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+	repository.DrawCardsFromDeck(deck.Id, 2)
+
+	for _, codes := range [][]string{{"KH"}, {"AS", "AS"}, {"AS", "ZZ"}} {
+		if _, err := repository.ReturnCards(deck.Id, codes, data.PositionTop, nil); !errors.Is(err, data.ErrNotDrawn) {
+			t.Errorf("Returning %v should return %v, got %v", codes, data.ErrNotDrawn, err)
+		}
+	}
+
+	outOfRange := func(n int) int { return n + 1 }
+	if _, err := repository.ReturnCards(deck.Id, nil, data.PositionRandom, outOfRange); !errors.Is(err, data.ErrInvalidParameters) {
+		t.Errorf("An invalid random position should return %v, got %v", data.ErrInvalidParameters, err)
+	}
+
+	if _, err := repository.ReturnCards(deck.Id, nil, data.Position(-1), nil); !errors.Is(err, data.ErrInvalidParameters) {
+		t.Errorf("An invalid position should return %v, got %v", data.ErrInvalidParameters, err)
+	}
+
+	if _, err := repository.ReturnCards(uuid.New(), nil, data.PositionTop, nil); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if stored.Remaining != data.MaxCards-2 || len(stored.Drawn) != 2 {
+		t.Errorf("Failed returns should not modify the deck")
+	}
+
+	repository.CloseDeck(deck.Id)
+	if _, err := repository.ReturnCards(deck.Id, nil, data.PositionTop, nil); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+}
+
+// Cutting moves cards from the top to the bottom
+func testCutDeck(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	length := 0
+	cut, err := repository.CutDeck(deck.Id, func(n int) int {
+		length = n
+		return 10
+	})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	expected := append(append([]data.Card{}, deck.Cards[10:]...), deck.Cards[:10]...)
+	if length != data.MaxCards || !sameCards(cut.Cards, expected) || cut.Remaining != data.MaxCards {
+		t.Errorf("The first 10 cards should be at the bottom, got %v", codes(cut.Cards[:3]))
+	}
+
+	for _, position := range []int{0, data.MaxCards} {
+		at := func(n int) int { return position }
+		if _, err := repository.CutDeck(deck.Id, at); !errors.Is(err, data.ErrInvalidParameters) {
+			t.Errorf("Cutting at %d should return %v, got %v", position, data.ErrInvalidParameters, err)
+		}
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if !sameCards(stored.Cards, expected) {
+		t.Errorf("The stored deck should be cut once")
+	}
+
+	repository.DrawCardsFromDeck(deck.Id, data.MaxCards-1)
+	if _, err := repository.CutDeck(deck.Id, func(n int) int { return 1 }); !errors.Is(err, data.ErrTruncate) {
+		t.Errorf("A deck with 1 card should return %v, got %v", data.ErrTruncate, err)
+	}
+
+	repository.CloseDeck(deck.Id)
+	if _, err := repository.CutDeck(deck.Id, func(n int) int { return 1 }); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+
+	if _, err := repository.CutDeck(uuid.New(), func(n int) int { return 1 }); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// Tests that a fair deck is seeded only once, reversed by the
+// permutation, and keeps the client seed and commitment
+func testSeedDeck(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	deck.Fair = true
+	deck.ServerSeed = "server"
+	mustAdd(t, repository, deck)
+
+	reverse := func(n int) []int {
+		order := make([]int, n)
+		for i := range order {
+			order[i] = n - 1 - i
+		}
+		return order
+	}
+
+	seeded, err := repository.SeedDeck(deck.Id, "client", "commitment", reverse)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	for _, v := range []*data.Deck{seeded, stored} {
+		if v.Cards[0].Code != "KH" || !v.Shuffled || v.ClientSeed != "client" || v.Commitment != "commitment" {
+			t.Errorf("The deck should be shuffled with the seed and commitment")
+		}
+	}
+
+	if _, err := repository.SeedDeck(deck.Id, "other", "other", reverse); !errors.Is(err, data.ErrSeeded) {
+		t.Errorf("Should have returned %v, got %v", data.ErrSeeded, err)
+	}
+
+	unfair := NewStandardDeck()
+	mustAdd(t, repository, unfair)
+	if _, err := repository.SeedDeck(unfair.Id, "client", "commitment", reverse); !errors.Is(err, data.ErrInvalidParameters) {
+		t.Errorf("Should have returned %v, got %v", data.ErrInvalidParameters, err)
+	}
+
+	closed := NewStandardDeck()
+	closed.Fair = true
+	closed.Closed = true
+	mustAdd(t, repository, closed)
+	if _, err := repository.SeedDeck(closed.Id, "client", "commitment", reverse); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+}
+
+// Piles are created empty with unique names
+func testCreatePile(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	created, err := repository.CreatePile(deck.Id, data.Pile{Name: "north", Owner: "alice", Cards: deck.Cards[:1]})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	pile := created.GetPile("north")
+	if pile == nil || len(pile.Cards) != 0 || pile.Owner != "alice" || created.Remaining != data.MaxCards {
+		t.Errorf("The deck should have an empty pile, got %+v", created.Piles)
+	}
+
+	repository.CreatePile(deck.Id, data.Pile{Name: "discard", FaceUp: true})
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if len(stored.Piles) != 2 || stored.Piles[0].Name != "north" || stored.Piles[1].Name != "discard" || !stored.Piles[1].FaceUp {
+		t.Errorf("The stored deck should have the piles in the order they were created, got %+v", stored.Piles)
+	}
+
+	if _, err := repository.CreatePile(deck.Id, data.Pile{Name: "north"}); !errors.Is(err, data.ErrPileExists) {
+		t.Errorf("Should have returned %v, got %v", data.ErrPileExists, err)
+	}
+
+	if _, err := repository.CreatePile(deck.Id, data.Pile{Name: ""}); !errors.Is(err, data.ErrInvalidParameters) {
+		t.Errorf("Should have returned %v, got %v", data.ErrInvalidParameters, err)
+	}
+
+	if _, err := repository.CreatePile(uuid.New(), data.Pile{Name: "north"}); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	repository.CloseDeck(deck.Id)
+	if _, err := repository.CreatePile(deck.Id, data.Pile{Name: "south"}); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+}
+
+// Cards drawn to a pile leave the deck and are added to the
+// end of the pile
+func testDrawToPile(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+	repository.CreatePile(deck.Id, data.Pile{Name: "north"})
+
+	top, err := repository.DrawCardsToPile(deck.Id, "north", 2, data.PositionTop, nil)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	bottom, _ := repository.DrawCardsToPile(deck.Id, "north", 1, data.PositionBottom, nil)
+
+	if !sameCards(top, deck.Cards[:2]) || !sameCards(bottom, deck.Cards[data.MaxCards-1:]) {
+		t.Errorf("Should have drawn the top and bottom cards, got %v and %v", codes(top), codes(bottom))
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	expected := append(append([]data.Card{}, top...), bottom...)
+
+	if !sameCards(stored.GetPile("north").Cards, expected) {
+		t.Errorf("The pile should have %v, got %v", codes(expected), codes(stored.GetPile("north").Cards))
+	}
+
+	if stored.Remaining != data.MaxCards-3 || !sameCards(stored.Cards, deck.Cards[2:data.MaxCards-1]) || len(stored.Drawn) != 0 {
+		t.Errorf("The cards should have left the deck without being drawn")
+	}
+
+	if _, err := repository.DrawCardsToPile(deck.Id, "south", 1, data.PositionTop, nil); !errors.Is(err, data.ErrPileNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrPileNotFound, err)
+	}
+
+	if _, err := repository.DrawCardsToPile(deck.Id, "north", data.MaxCards, data.PositionTop, nil); !errors.Is(err, data.ErrTruncate) {
+		t.Errorf("Should have returned %v, got %v", data.ErrTruncate, err)
+	}
+
+	if _, err := repository.DrawCardsToPile(deck.Id, "north", 0, data.PositionTop, nil); !errors.Is(err, data.ErrInvalidParameters) {
+		t.Errorf("Should have returned %v, got %v", data.ErrInvalidParameters, err)
+	}
+
+	repository.CloseDeck(deck.Id)
+	if _, err := repository.DrawCardsToPile(deck.Id, "north", 1, data.PositionTop, nil); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+
+	if _, err := repository.DrawCardsToPile(uuid.New(), "north", 1, data.PositionTop, nil); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// Cards are moved between piles by code or all at once
+func testMovePileCards(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+	repository.CreatePile(deck.Id, data.Pile{Name: "north"})
+	repository.CreatePile(deck.Id, data.Pile{Name: "discard"})
+	hand, _ := repository.DrawCardsToPile(deck.Id, "north", 4, data.PositionTop, nil)
+
+	moved, err := repository.MovePileCards(deck.Id, "north", "discard", []string{hand[2].Code, hand[0].Code})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if !sameCards(moved.GetPile("discard").Cards, []data.Card{hand[2], hand[0]}) {
+		t.Errorf("The discard pile should have the cards in the order of the codes, got %v", codes(moved.GetPile("discard").Cards))
+	}
+
+	if !sameCards(moved.GetPile("north").Cards, []data.Card{hand[1], hand[3]}) {
+		t.Errorf("The other cards should stay in the pile, got %v", codes(moved.GetPile("north").Cards))
+	}
+
+	if _, err := repository.MovePileCards(deck.Id, "north", "discard", []string{hand[1].Code, hand[0].Code}); !errors.Is(err, data.ErrCardNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrCardNotFound, err)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if len(stored.GetPile("north").Cards) != 2 {
+		t.Errorf("A failed move should not change the piles")
+	}
+
+	all, _ := repository.MovePileCards(deck.Id, "north", "discard", nil)
+	if len(all.GetPile("north").Cards) != 0 || !sameCards(all.GetPile("discard").Cards, []data.Card{hand[2], hand[0], hand[1], hand[3]}) {
+		t.Errorf("Every card should have been moved, got %v", codes(all.GetPile("discard").Cards))
+	}
+
+	if _, err := repository.MovePileCards(deck.Id, "discard", "discard", nil); !errors.Is(err, data.ErrInvalidParameters) {
+		t.Errorf("Should have returned %v, got %v", data.ErrInvalidParameters, err)
+	}
+
+	if _, err := repository.MovePileCards(deck.Id, "discard", "south", nil); !errors.Is(err, data.ErrPileNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrPileNotFound, err)
+	}
+
+	repository.CloseDeck(deck.Id)
+	if _, err := repository.MovePileCards(deck.Id, "discard", "north", nil); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+
+	stored, _ = repository.GetDeckById(deck.Id)
+	if len(stored.GetPile("north").Cards) != 0 {
+		t.Errorf("The piles of a closed deck should not change")
+	}
+
+	if _, err := repository.MovePileCards(uuid.New(), "north", "discard", nil); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// Shuffling a pile only reorders its cards
+func testShufflePile(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+	repository.CreatePile(deck.Id, data.Pile{Name: "north"})
+	hand, _ := repository.DrawCardsToPile(deck.Id, "north", 3, data.PositionTop, nil)
+
+	length := 0
+	shuffled, err := repository.ShufflePile(deck.Id, "north", func(n int) []int {
+		length = n
+		return []int{2, 0, 1}
+	})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	expected := []data.Card{hand[2], hand[0], hand[1]}
+	if length != 3 || !sameCards(shuffled.GetPile("north").Cards, expected) {
+		t.Errorf("The pile should be %v, got %v", codes(expected), codes(shuffled.GetPile("north").Cards))
+	}
+
+	if shuffled.Shuffled || !sameCards(shuffled.Cards, deck.Cards[3:]) {
+		t.Errorf("Shuffling a pile should not change the deck")
+	}
+
+	if _, err := repository.ShufflePile(deck.Id, "north", func(n int) []int { return []int{0, 0, 1} }); !errors.Is(err, data.ErrInvalidParameters) {
+		t.Errorf("Should have returned %v, got %v", data.ErrInvalidParameters, err)
+	}
+
+	if _, err := repository.ShufflePile(deck.Id, "south", func(n int) []int { return []int{} }); !errors.Is(err, data.ErrPileNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrPileNotFound, err)
+	}
+
+	if _, err := repository.ShufflePile(uuid.New(), "north", func(n int) []int { return []int{} }); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	repository.CloseDeck(deck.Id)
+	if _, err := repository.ShufflePile(deck.Id, "north", func(n int) []int { return []int{2, 0, 1} }); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+}
+
+// Returning a pile puts its cards back in the deck and leaves
+// the pile empty
+func testReturnPile(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+	repository.CreatePile(deck.Id, data.Pile{Name: "north"})
+	hand, _ := repository.DrawCardsToPile(deck.Id, "north", 3, data.PositionTop, nil)
+
+	returned, err := repository.ReturnPile(deck.Id, "north", data.PositionBottom, nil)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	expected := append(append([]data.Card{}, deck.Cards[3:]...), hand...)
+	if !sameCards(returned.Cards, expected) || returned.Remaining != data.MaxCards {
+		t.Errorf("The pile should be at the bottom of the deck, got %v", codes(returned.Cards[data.MaxCards-3:]))
+	}
+
+	if pile := returned.GetPile("north"); pile == nil || len(pile.Cards) != 0 {
+		t.Errorf("The pile should be kept empty")
+	}
+
+	if _, err := repository.ReturnPile(deck.Id, "south", data.PositionTop, nil); !errors.Is(err, data.ErrPileNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrPileNotFound, err)
+	}
+
+	repository.CloseDeck(deck.Id)
+	if _, err := repository.ReturnPile(deck.Id, "north", data.PositionTop, nil); !errors.Is(err, data.ErrClosed) {
+		t.Errorf("Should have returned %v, got %v", data.ErrClosed, err)
+	}
+
+	if _, err := repository.ReturnPile(uuid.New(), "north", data.PositionTop, nil); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// Every card of a deck is always in one place: the deck, the
+// drawn or burned cards or a pile
+func testPileCardsConserved(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+	repository.CreatePile(deck.Id, data.Pile{Name: "north"})
+	repository.CreatePile(deck.Id, data.Pile{Name: "discard"})
+
+	random := func(n int) int { return n / 2 }
+	repository.DrawCardsToPile(deck.Id, "north", 5, data.PositionRandom, random)
+	repository.DrawCardsFromDeck(deck.Id, 3)
+	repository.BurnCards(deck.Id, 2)
+	repository.MovePileCards(deck.Id, "north", "discard", nil)
+	repository.DrawCardsToPile(deck.Id, "north", 4, data.PositionBottom, nil)
+	repository.ReturnPile(deck.Id, "discard", data.PositionRandom, random)
+	repository.ShuffleDeck(deck.Id, true, func(n int) []int {
+		a := make([]int, n)
+		for i := range a {
+			a[i] = n - 1 - i
+		}
+		return a
+	})
+
+	stored, _ := repository.GetDeckById(deck.Id)
+
+	seen := map[int]int{}
+	cards := append(append(append([]data.Card{}, stored.Cards...), stored.Drawn...), stored.Burned...)
+	for _, pile := range stored.Piles {
+		cards = append(cards, pile.Cards...)
+	}
+
+	for _, v := range cards {
+		seen[v.Instance]++
+	}
+
+	if len(cards) != data.MaxCards || len(seen) != data.MaxCards {
+		t.Errorf("Every card should be in one place, got %d cards and %d different ones", len(cards), len(seen))
+	}
+
+	if stored.Remaining != len(stored.Cards) || len(stored.GetPile("north").Cards) != 4 {
+		t.Errorf("The deck should have %d cards and the pile 4, got %d and %d", stored.Remaining, len(stored.Cards), len(stored.GetPile("north").Cards))
+	}
+}
+
+// Responses are stored with their deck and found by key
+// while the deck exists, keeping the deck unchanged
+func testStoredResponses(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	now := time.Now()
+	response := data.StoredResponse{
+		Key:         "key-1",
+		Fingerprint: "POST /deck",
+		Status:      201,
+		Header:      map[string][]string{"Location": {"/deck/1"}},
+		Body:        []byte(`{"remaining":52}`),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+	}
+
+	if err := repository.SaveResponse(deck.Id, response); err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	// Other changes keep the stored responses
+	repository.DrawCardsFromDeck(deck.Id, 1)
+
+	stored, err := repository.GetResponse("key-1")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if stored.Fingerprint != response.Fingerprint || stored.Status != 201 || !bytes.Equal(stored.Body, response.Body) ||
+		stored.Header["Location"][0] != "/deck/1" || !stored.CreatedAt.Equal(now) || !stored.ExpiresAt.Equal(response.ExpiresAt) {
+		t.Errorf("Should have returned %+v, got %+v", response, stored)
+	}
+
+	// The returned response is a copy
+	stored.Body[0] = 'x'
+	if again, _ := repository.GetResponse("key-1"); !bytes.Equal(again.Body, response.Body) {
+		t.Errorf("The stored response should not be modified")
+	}
+
+	deckStored, _ := repository.GetDeckById(deck.Id)
+	if deckStored.Remaining != data.MaxCards-1 || len(deckStored.Responses) != 1 {
+		t.Errorf("The deck should keep its cards and have 1 response, got %+v", deckStored.Responses)
+	}
+
+	// The same key replaces the response
+	response.Status = 200
+	repository.SaveResponse(deck.Id, response)
+	if stored, _ := repository.GetResponse("key-1"); stored == nil || stored.Status != 200 {
+		t.Errorf("The response should have been replaced, got %+v", stored)
+	}
+
+	if _, err := repository.GetResponse("key-2"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	if err := repository.SaveResponse(uuid.New(), response); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	response.Key = ""
+	if err := repository.SaveResponse(deck.Id, response); !errors.Is(err, data.ErrInvalidParameters) {
+		t.Errorf("Should have returned %v, got %v", data.ErrInvalidParameters, err)
+	}
+
+	// Deleting the deck deletes its responses
+	repository.DeleteDeck(deck.Id)
+	if _, err := repository.GetResponse("key-1"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// Expired responses are discarded when a new one is stored,
+// and no more than MaxResponses unexpired ones are kept
+func testResponsesExpire(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	now := time.Now()
+	repository.SaveResponse(deck.Id, data.StoredResponse{Key: "old", CreatedAt: now, ExpiresAt: now.Add(time.Minute)})
+	repository.SaveResponse(deck.Id, data.StoredResponse{Key: "new", CreatedAt: now.Add(time.Hour), ExpiresAt: now.Add(2 * time.Hour)})
+
+	if _, err := repository.GetResponse("old"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	for i := 1; i < data.MaxResponses; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if err := repository.SaveResponse(deck.Id, data.StoredResponse{Key: key, CreatedAt: now.Add(time.Hour), ExpiresAt: now.Add(2 * time.Hour)}); err != nil {
+			t.Fatalf("There should be no error: %v", err)
+		}
+	}
+
+	// The unexpired responses are kept, and new keys rejected
+	full := data.StoredResponse{Key: "full", CreatedAt: now.Add(time.Hour), ExpiresAt: now.Add(2 * time.Hour)}
+	if err := repository.SaveResponse(deck.Id, full); !errors.Is(err, data.ErrResponsesFull) {
+		t.Errorf("Should have returned %v, got %v", data.ErrResponsesFull, err)
+	}
+
+	if _, err := repository.GetResponse("new"); err != nil {
+		t.Errorf("There should be no error: %v", err)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if len(stored.Responses) != data.MaxResponses {
+		t.Errorf("There should be %d responses, got %d", data.MaxResponses, len(stored.Responses))
+	}
+
+	// Until they expire
+	full.CreatedAt = now.Add(2 * time.Hour)
+	full.ExpiresAt = now.Add(3 * time.Hour)
+	if err := repository.SaveResponse(deck.Id, full); err != nil {
+		t.Errorf("There should be no error: %v", err)
+	}
+
+	if _, err := repository.GetResponse("new"); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// New decks are at version 1, and every change stores a new
+// version. Failed changes and stored responses keep it
+func testVersions(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if stored.Version != 1 {
+		t.Errorf("The version should be 1, got %d", stored.Version)
+	}
+
+	repository.DrawCardsFromDeck(deck.Id, 1)
+	repository.CreatePile(deck.Id, data.Pile{Name: "north"})
+	closed, _ := repository.CloseDeck(deck.Id)
+
+	if closed.Version != 4 {
+		t.Errorf("The version should be 4, got %d", closed.Version)
+	}
+
+	repository.DrawCardsFromDeck(deck.Id, 1)
+	repository.SaveResponse(deck.Id, data.StoredResponse{Key: "key-1", ExpiresAt: time.Now().Add(time.Hour)})
+
+	stored, _ = repository.GetDeckById(deck.Id)
+	if stored.Version != 4 {
+		t.Errorf("The version should still be 4, got %d", stored.Version)
+	}
+}
+
+// Changes at the expected version are stored as one new
+// version, and otherwise nothing is changed
+func testUpdateIfVersion(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	err := repository.UpdateIfVersion(deck.Id, []int64{1}, func(r data.DeckRepository) error {
+		if _, err := r.DrawCardsFromDeck(deck.Id, 2); err != nil {
+			return err
+		}
+		_, err := r.BurnCards(deck.Id, 1)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if stored.Version != 2 || stored.Remaining != data.MaxCards-3 || len(stored.Drawn) != 2 || len(stored.Burned) != 1 {
+		t.Errorf("The changes should be stored as version 2, got version %d with %d cards", stored.Version, stored.Remaining)
+	}
+
+	called := false
+	err = repository.UpdateIfVersion(deck.Id, []int64{1}, func(r data.DeckRepository) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, data.ErrVersionMismatch) || called {
+		t.Errorf("Should have returned %v without changing the deck, got %v", data.ErrVersionMismatch, err)
+	}
+
+	// A failed change discards every change made before
+	err = repository.UpdateIfVersion(deck.Id, []int64{2}, func(r data.DeckRepository) error {
+		r.DrawCardsFromDeck(deck.Id, 1)
+		_, err := r.DrawCardsFromDeck(deck.Id, data.MaxCards)
+		return err
+	})
+	if !errors.Is(err, data.ErrTruncate) {
+		t.Errorf("Should have returned %v, got %v", data.ErrTruncate, err)
+	}
+
+	stored, _ = repository.GetDeckById(deck.Id)
+	if stored.Version != 2 || stored.Remaining != data.MaxCards-3 {
+		t.Errorf("The deck should not change, got version %d with %d cards", stored.Version, stored.Remaining)
+	}
+
+	// Any of the versions, or any version at all if nil
+	noChange := func(r data.DeckRepository) error { return nil }
+	if err := repository.UpdateIfVersion(deck.Id, []int64{}, noChange); !errors.Is(err, data.ErrVersionMismatch) {
+		t.Errorf("Should have returned %v, got %v", data.ErrVersionMismatch, err)
+	}
+
+	if err := repository.UpdateIfVersion(deck.Id, []int64{1, 2}, noChange); err != nil {
+		t.Errorf("There should be no error: %v", err)
+	}
+
+	if err := repository.UpdateIfVersion(deck.Id, nil, noChange); err != nil {
+		t.Errorf("There should be no error: %v", err)
+	}
+
+	stored, _ = repository.GetDeckById(deck.Id)
+	if stored.Version != 4 {
+		t.Errorf("The version should be 4, got %d", stored.Version)
+	}
+
+	err = repository.UpdateIfVersion(uuid.New(), []int64{1}, func(r data.DeckRepository) error { return nil })
+	if !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// Decks deleted by a change at the expected version are removed
+func testUpdateIfVersionDelete(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	err := repository.UpdateIfVersion(deck.Id, []int64{1}, func(r data.DeckRepository) error {
+		return r.DeleteDeck(deck.Id)
+	})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if _, err := repository.GetDeckById(deck.Id); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// Only one of the concurrent changes at the same version
+// is stored
+func testConcurrentUpdateIfVersion(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	const workers = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repository.UpdateIfVersion(deck.Id, []int64{1}, func(r data.DeckRepository) error {
+				_, err := r.DrawCardsFromDeck(deck.Id, 1)
+				return err
+			})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			} else if !errors.Is(err, data.ErrVersionMismatch) {
+				t.Errorf("Should have returned %v, got %v", data.ErrVersionMismatch, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if succeeded != 1 || stored.Remaining != data.MaxCards-1 || stored.Version != 2 {
+		t.Errorf("Only one change should be stored, got %d with %d cards", succeeded, stored.Remaining)
+	}
+}
+
+// Returns the ids of the listed decks, in order
+func listedIds(t *testing.T, repository data.DeckRepository, query data.DeckQuery) []uuid.UUID {
+
+	decks, err := repository.ListDecks(query)
+	if err != nil {
+		t.Fatalf("There should be no error listing the decks: %v", err)
+	}
+
+	ids := make([]uuid.UUID, len(decks))
+	for i, v := range decks {
+		ids[i] = v.Id
+	}
+
+	return ids
+}
+
+// Returns true if both id slices are equal
+func sameIds(a []uuid.UUID, b []uuid.UUID) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i, v := range a {
+		if b[i] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Decks are listed with their metadata and filtered by every
+// field of the query
+func testListDecks(t *testing.T, repository data.DeckRepository) {
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	first := NewStandardDeck()
+	first.CreatedAt = created
+	first.Owner = "alice"
+	first.Tags = []string{"poker", "table-1"}
+	mustAdd(t, repository, first)
+
+	second := NewStandardDeck()
+	second.CreatedAt = created.Add(time.Minute)
+	second.Shuffled = true
+	second.Tags = []string{"poker"}
+	mustAdd(t, repository, second)
+	repository.DrawCardsFromDeck(second.Id, 20)
+
+	deleted := NewStandardDeck()
+	deleted.CreatedAt = created.Add(2 * time.Minute)
+	mustAdd(t, repository, deleted)
+	repository.DeleteDeck(deleted.Id)
+
+	decks, err := repository.ListDecks(data.DeckQuery{})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if len(decks) != 2 {
+		t.Fatalf("There should be 2 decks, got %d", len(decks))
+	}
+
+	listed := decks[0]
+	if listed.Id != first.Id || !listed.CreatedAt.Equal(created) || listed.Owner != "alice" ||
+		len(listed.Tags) != 2 || listed.Tags[1] != "table-1" || listed.Remaining != data.MaxCards {
+		t.Errorf("The first deck should be listed with its metadata, got %+v", listed)
+	}
+
+	if len(listed.Cards) != 0 || len(listed.Drawn) != 0 {
+		t.Errorf("The decks should be listed without cards")
+	}
+
+	shuffled := true
+	minimum, maximum := 40, 52
+
+	tests := []struct {
+		query    data.DeckQuery
+		expected []uuid.UUID
+	}{
+		{data.DeckQuery{Shuffled: &shuffled}, []uuid.UUID{second.Id}},
+		{data.DeckQuery{MinRemaining: &minimum}, []uuid.UUID{first.Id}},
+		{data.DeckQuery{MaxRemaining: &maximum}, []uuid.UUID{first.Id, second.Id}},
+		{data.DeckQuery{CreatedAfter: created}, []uuid.UUID{second.Id}},
+		{data.DeckQuery{Owner: "alice"}, []uuid.UUID{first.Id}},
+		{data.DeckQuery{Tag: "poker"}, []uuid.UUID{first.Id, second.Id}},
+		{data.DeckQuery{Tag: "table-1", Owner: "bob"}, []uuid.UUID{}},
+	}
+
+	for _, v := range tests {
+		if ids := listedIds(t, repository, v.query); !sameIds(ids, v.expected) {
+			t.Errorf("Should have listed %v for %+v, got %v", v.expected, v.query, ids)
+		}
+	}
+}
+
+// Decks are sorted by the query, with the id breaking ties,
+// and listed in pages that start after a cursor
+func testListDecksPages(t *testing.T, repository data.DeckRepository) {
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var decks []data.Deck
+	for i := 0; i < 5; i++ {
+		deck := NewStandardDeck()
+		deck.CreatedAt = created.Add(time.Duration(i) * time.Second)
+		// Two decks of each amount of cards
+		deck.Remaining = data.MaxCards - i/2
+		deck.Cards = deck.Cards[:deck.Remaining]
+		mustAdd(t, repository, deck)
+		decks = append(decks, deck)
+	}
+
+	for _, query := range []data.DeckQuery{
+		{Sort: data.SortByCreatedAt},
+		{Sort: data.SortByCreatedAt, Descending: true},
+		{Sort: data.SortByRemaining},
+		{Sort: data.SortByRemaining, Descending: true},
+	} {
+		all, _ := repository.ListDecks(query)
+		if len(all) != len(decks) {
+			t.Fatalf("There should be %d decks, got %d", len(decks), len(all))
+		}
+
+		for i := 1; i < len(all); i++ {
+			previous, current := all[i-1], all[i]
+			var before bool
+			switch query.Sort {
+			case data.SortByRemaining:
+				before = previous.Remaining < current.Remaining ||
+					previous.Remaining == current.Remaining && previous.Id.String() < current.Id.String()
+			default:
+				before = previous.CreatedAt.Before(current.CreatedAt)
+			}
+			if before == query.Descending {
+				t.Errorf("The decks should be sorted by %+v, got %v before %v", query, previous.Id, current.Id)
+			}
+		}
+
+		// Pages of 2 decks list every deck once, in the same order
+		var paged []uuid.UUID
+		query.Limit = 2
+		for page := 0; page < len(decks); page++ {
+			listed, _ := repository.ListDecks(query)
+			if len(listed) > 2 {
+				t.Fatalf("There should be at most 2 decks, got %d", len(listed))
+			}
+			for _, v := range listed {
+				paged = append(paged, v.Id)
+			}
+			if len(listed) < 2 {
+				break
+			}
+			cursor := data.CursorOf(&listed[len(listed)-1])
+			query.After = &cursor
+		}
+
+		query.Limit = 0
+		query.After = nil
+		if expected := listedIds(t, repository, query); !sameIds(paged, expected) {
+			t.Errorf("The pages should list %v, got %v", expected, paged)
+		}
+	}
+}
+
+// Returned decks and cards don't share memory with the
+// stored ones
+func testSnapshots(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+	deck.Cards[0].Code = "ZZ"
+
+	opened, _ := repository.GetDeckById(deck.Id)
+	drawn, _ := repository.DrawCardsFromDeck(deck.Id, 2)
+	drawn = append(drawn, data.Card{Code: "ZZ"})
+	drawn[0].Code = "ZZ"
+	opened.Cards[2].Code = "ZZ"
+
+	if opened.Remaining != data.MaxCards || opened.Cards[0].Code != "AS" {
+		t.Errorf("An opened deck should not change after drawing")
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if stored.Cards[0].Code != "3S" {
+		t.Errorf("Stored cards should not change from outside the repository")
+	}
+
+	more, _ := repository.DrawCardsFromDeck(deck.Id, 1)
+	if drawn[1].Code != "2S" || more[0].Code != "3S" {
+		t.Errorf("Drawn cards should not change after later draws")
+	}
+}
+
+// Concurrent draws from one deck deal every card once
+func testConcurrentDraws(t *testing.T, repository data.DeckRepository) {
+
+	deck := NewStandardDeck()
+	mustAdd(t, repository, deck)
+
+	var mu sync.Mutex
+	dealt := map[int]int{}
+
+	var wg sync.WaitGroup
+	for p := 0; p < 8; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				cards, err := repository.DrawCardsFromDeck(deck.Id, 1)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				dealt[cards[0].Instance]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(dealt) != data.MaxCards {
+		t.Errorf("%d cards should have been dealt, got %d", data.MaxCards, len(dealt))
+	}
+
+	for instance, n := range dealt {
+		if n != 1 {
+			t.Errorf("Card instance %d was dealt %d times", instance, n)
+		}
+	}
+}
+
+// Decks added and drawn concurrently don't interfere
+func testConcurrentDecks(t *testing.T, repository data.DeckRepository) {
+
+	decks := make([]data.Deck, 8)
+
+	var wg sync.WaitGroup
+	for i := range decks {
+		decks[i] = NewStandardDeck()
+		wg.Add(1)
+		go func(deck data.Deck) {
+			defer wg.Done()
+			if err := repository.Add(deck); err != nil {
+				t.Errorf("There should be no error adding the deck: %v", err)
+				return
+			}
+			for j := 0; j < 10; j++ {
+				repository.DrawCardsFromDeck(deck.Id, 1)
+			}
+		}(decks[i])
+	}
+	wg.Wait()
+
+	for _, deck := range decks {
+		stored, err := repository.GetDeckById(deck.Id)
+		if err != nil {
+			t.Fatalf("Deck should exist: %v", err)
+		}
+		if stored.Remaining != data.MaxCards-10 || stored.Cards[0] != deck.Cards[10] {
+			t.Errorf("Each deck should have %d cards left, got %d", data.MaxCards-10, stored.Remaining)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Author: Ferran Balaguer
+
+// Operations over the stored responses of a deck shared by
+// every DeckRepository implementation. They must be called
+// with the deck already locked
+
+package data
+
+import (
+	"errors"
+)
+
+var ErrResponsesFull = errors.New("Too many stored responses")
+
+// Maximum amount of unexpired responses stored with a deck.
+// Once reached no response with a new key is stored until the
+// oldest ones expire
+const MaxResponses int = 128
+
+// Returns the response stored with the key, or nil if
+// there is none
+func findResponse(deck *Deck, key string) *StoredResponse {
+
+	for i := range deck.Responses {
+		if deck.Responses[i].Key == key {
+			return &deck.Responses[i]
+		}
+	}
+
+	return nil
+}
+
+// Stores the response with the deck, replacing the one with
+// the same key. The responses expired when it was created are
+// discarded, and the unexpired ones are never discarded, so it
+// returns ErrResponsesFull if there are MaxResponses of them
+func saveResponse(deck *Deck, response StoredResponse) error {
+
+	if response.Key == "" {
+		return ErrInvalidParameters
+	}
+
+	kept := []StoredResponse{}
+	for _, v := range deck.Responses {
+		if v.Key != response.Key && v.ExpiresAt.After(response.CreatedAt) {
+			kept = append(kept, v)
+		}
+	}
+
+	if len(kept) >= MaxResponses {
+		return ErrResponsesFull
+	}
+
+	deck.Responses = append(kept, *response.Clone())
+
+	return nil
+}
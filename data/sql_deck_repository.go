@@ -0,0 +1,1011 @@
+// Author: Ferran Balaguer
+
+package data
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+// Implements DeckRepository using an embedded SQLite database
+// with a pure Go driver, so no external server is needed.
+//
+// Every operation runs in its own transaction, so draws are
+// atomic. The database only allows one connection, which
+// serialises the transactions and avoids lock upgrade errors
+type SqlDeckRepository struct {
+	db *sql.DB
+}
+
+// Opens the SQLite database at path, creating it if it does
+// not exist, and applies the pending schema migrations. The
+// path ":memory:" creates a database that is never persisted
+func NewSqlDeckRepository(path string) (*SqlDeckRepository, error) {
+
+	// The pragma is run by every new connection, so the foreign
+	// keys are enforced even if the connection is reopened
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	db, err := sql.Open("sqlite", path+separator+"_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SqlDeckRepository{db: db}, nil
+}
+
+// Closes the database
+func (r *SqlDeckRepository) Close() error {
+	return r.db.Close()
+}
+
+// Runs fn over the deck inside a transaction, and stores
+// the deck as a new version if fn succeeds
+func (r *SqlDeckRepository) update(uuid uuid.UUID, fn func(deck *Deck) error) error {
+	return r.updateDeck(uuid, true, fn)
+}
+
+// Runs fn like update. The version is kept unless newVersion,
+// for the changes that don't modify the cards
+func (r *SqlDeckRepository) updateDeck(uuid uuid.UUID, newVersion bool, fn func(deck *Deck) error) error {
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deck, err := loadDeck(tx, uuid)
+	if err != nil {
+		return err
+	}
+
+	// Only the rows that differ from the stored deck are written
+	stored := deck.Clone()
+
+	// Set before fn, which may return the deck
+	if newVersion {
+		deck.Version++
+	}
+
+	if err := fn(deck); err != nil {
+		return err
+	}
+
+	if err := saveDeck(tx, stored, deck); err != nil {
+		return fmt.Errorf("saving deck %s: %w", uuid, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing deck %s: %w", uuid, err)
+	}
+
+	return nil
+}
+
+// Runs fn over the deck like update, and returns
+// the updated deck
+func (r *SqlDeckRepository) modify(uuid uuid.UUID, fn func(deck *Deck) error) (*Deck, error) {
+
+	var deck *Deck
+
+	err := r.update(uuid, func(d *Deck) error {
+		if err := fn(d); err != nil {
+			return err
+		}
+		deck = d
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return deck, nil
+}
+
+// Columns of the decks table besides the id, in the
+// same order as the fields returned by deckFields
+var deckColumns = []string{
+	"composition", "jokers", "decks", "shuffled", "remaining", "cut_card",
+	"seed", "fair", "server_seed", "client_seed", "commitment", "closed", "seeded",
+	"owner", "version", "created_at", "hide_order",
+}
+
+// Returns pointers to the deck fields stored in the decks
+// table, so that they can be both scanned and written
+func deckFields(deck *Deck) []any {
+	return []any{
+		&deck.Composition, &deck.Jokers, &deck.Decks, &deck.Shuffled, &deck.Remaining, &deck.CutCard,
+		&deck.Seed, &deck.Fair, &deck.ServerSeed, &deck.ClientSeed, &deck.Commitment, &deck.Closed, &deck.Seeded,
+		&deck.Owner, &deck.Version, (*nanoTime)(&deck.CreatedAt), &deck.HideOrder,
+	}
+}
+
+// Time stored as nanoseconds since the epoch. The zero time
+// is stored as 0
+type nanoTime time.Time
+
+func (t *nanoTime) Scan(value any) error {
+
+	nanos, ok := value.(int64)
+	if !ok {
+		return fmt.Errorf("invalid time %v", value)
+	}
+
+	*t = nanoTime{}
+	if nanos != 0 {
+		*t = nanoTime(time.Unix(0, nanos))
+	}
+
+	return nil
+}
+
+func (t nanoTime) Value() (driver.Value, error) {
+
+	if time.Time(t).IsZero() {
+		return int64(0), nil
+	}
+
+	return time.Time(t).UnixNano(), nil
+}
+
+// Statements built from deckColumns
+var (
+	selectDeckSql = `SELECT ` + strings.Join(deckColumns, ", ") + ` FROM decks WHERE id = ?`
+	upsertDeckSql = upsertSql("decks", "id", deckColumns)
+)
+
+// Builds an insert statement that updates the columns
+// when the key already exists
+func upsertSql(table string, key string, columns []string) string {
+
+	updates := make([]string, len(columns))
+	for i, v := range columns {
+		updates[i] = v + " = excluded." + v
+	}
+
+	return `INSERT INTO ` + table + ` (` + key + `, ` + strings.Join(columns, ", ") + `)
+		VALUES (?` + strings.Repeat(", ?", len(columns)) + `)
+		ON CONFLICT (` + key + `) DO UPDATE SET ` + strings.Join(updates, ", ")
+}
+
+// Reads a deck and its cards
+func loadDeck(tx *sql.Tx, uuid uuid.UUID) (*Deck, error) {
+
+	deck := &Deck{Id: uuid}
+
+	err := tx.QueryRow(selectDeckSql, uuid.String()).Scan(deckFields(deck)...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("loading deck %s: %w", uuid, err)
+	}
+
+	cards, err := loadCards(tx, cardsTable, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("loading deck %s: %w", uuid, err)
+	}
+	deck.Cards = fromBottom(cards)
+
+	if deck.Drawn, err = loadCards(tx, drawnCardsTable, uuid); err != nil {
+		return nil, fmt.Errorf("loading deck %s: %w", uuid, err)
+	}
+
+	if deck.Burned, err = loadCards(tx, burnedCardsTable, uuid); err != nil {
+		return nil, fmt.Errorf("loading deck %s: %w", uuid, err)
+	}
+
+	if deck.Piles, err = loadPiles(tx, uuid); err != nil {
+		return nil, fmt.Errorf("loading deck %s: %w", uuid, err)
+	}
+
+	if deck.Responses, err = loadResponses(tx, uuid); err != nil {
+		return nil, fmt.Errorf("loading deck %s: %w", uuid, err)
+	}
+
+	if deck.Tags, err = loadTags(tx, uuid); err != nil {
+		return nil, fmt.Errorf("loading deck %s: %w", uuid, err)
+	}
+
+	return deck, nil
+}
+
+// Tables holding the cards of the decks, sorted by position.
+// The cards left are sorted from the bottom of the deck, and the
+// drawn and burned ones in the order they left it, so that the
+// usual changes only add or delete rows at the end
+const (
+	cardsTable       = "cards"
+	drawnCardsTable  = "drawn_cards"
+	burnedCardsTable = "burned_cards"
+)
+
+// Returns the cards in reverse order, to turn the cards left in
+// a deck into their order in the cards table and back
+func fromBottom(cards []Card) []Card {
+
+	result := make([]Card, len(cards))
+	for i, v := range cards {
+		result[len(cards)-1-i] = v
+	}
+
+	return result
+}
+
+// Reads the cards of a deck from one of the card tables
+func loadCards(tx *sql.Tx, table string, uuid uuid.UUID) ([]Card, error) {
+
+	rows, err := tx.Query(
+		`SELECT value, suit, code, instance FROM `+table+` WHERE deck_id = ? ORDER BY position`,
+		uuid.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := []Card{}
+	for rows.Next() {
+		var card Card
+		if err := rows.Scan(&card.Value, &card.Suit, &card.Code, &card.Instance); err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+
+	return cards, rows.Err()
+}
+
+// Reads the piles of a deck and their cards
+func loadPiles(tx *sql.Tx, uuid uuid.UUID) ([]Pile, error) {
+
+	rows, err := tx.Query(`SELECT name, owner, face_up FROM piles WHERE deck_id = ? ORDER BY position`, uuid.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var piles []Pile
+	for rows.Next() {
+		pile := Pile{Cards: []Card{}}
+		if err := rows.Scan(&pile.Name, &pile.Owner, &pile.FaceUp); err != nil {
+			return nil, err
+		}
+		piles = append(piles, pile)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cards, err := tx.Query(
+		`SELECT pile, value, suit, code, instance FROM pile_cards WHERE deck_id = ? ORDER BY pile, position`,
+		uuid.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cards.Close()
+
+	for cards.Next() {
+		var pile int
+		var card Card
+		if err := cards.Scan(&pile, &card.Value, &card.Suit, &card.Code, &card.Instance); err != nil {
+			return nil, err
+		}
+		piles[pile].Cards = append(piles[pile].Cards, card)
+	}
+
+	return piles, cards.Err()
+}
+
+// Writes the piles of a deck and their cards that differ from
+// the stored ones
+func savePiles(tx *sql.Tx, uuid uuid.UUID, stored []Pile, piles []Pile) error {
+
+	for i, pile := range piles {
+		var storedCards []Card
+
+		if i < len(stored) {
+			storedCards = stored[i].Cards
+		}
+
+		if i >= len(stored) || stored[i].Name != pile.Name || stored[i].Owner != pile.Owner || stored[i].FaceUp != pile.FaceUp {
+			_, err := tx.Exec(
+				`INSERT INTO piles (deck_id, position, name, owner, face_up) VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT (deck_id, position) DO UPDATE SET name = excluded.name, owner = excluded.owner, face_up = excluded.face_up`,
+				uuid.String(), i, pile.Name, pile.Owner, pile.FaceUp,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := saveRows(tx, `pile_cards`, []any{uuid.String(), i}, len(storedCards), len(pile.Cards),
+			func(j int) bool { return storedCards[j] == pile.Cards[j] },
+			func(j int) []any {
+				v := pile.Cards[j]
+				return []any{v.Value, v.Suit, v.Code, v.Instance}
+			},
+			`value`, `suit`, `code`, `instance`,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(piles) < len(stored) {
+		if _, err := tx.Exec(`DELETE FROM pile_cards WHERE deck_id = ? AND pile >= ?`, uuid.String(), len(piles)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM piles WHERE deck_id = ? AND position >= ?`, uuid.String(), len(piles)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reads the tags of a deck
+func loadTags(tx *sql.Tx, uuid uuid.UUID) ([]string, error) {
+
+	rows, err := tx.Query(`SELECT tag FROM deck_tags WHERE deck_id = ? ORDER BY position`, uuid.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// Writes the tags of a deck that differ from the stored ones
+func saveTags(tx *sql.Tx, uuid uuid.UUID, stored []string, tags []string) error {
+
+	return saveRows(tx, `deck_tags`, []any{uuid.String()}, len(stored), len(tags),
+		func(i int) bool { return stored[i] == tags[i] },
+		func(i int) []any { return []any{tags[i]} },
+		`tag`,
+	)
+}
+
+// Columns of the responses table read by scanResponse
+const responseColumns = `idempotency_key, fingerprint, status, header, body, created_at, expires_at`
+
+// Reads a response from a row of the responses table
+func scanResponse(row interface{ Scan(...any) error }) (*StoredResponse, error) {
+
+	var response StoredResponse
+	var header string
+	var createdAt, expiresAt int64
+
+	err := row.Scan(&response.Key, &response.Fingerprint, &response.Status, &header, &response.Body, &createdAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(header), &response.Header); err != nil {
+		return nil, err
+	}
+
+	response.CreatedAt = time.Unix(0, createdAt)
+	response.ExpiresAt = time.Unix(0, expiresAt)
+
+	return &response, nil
+}
+
+// Reads the responses stored with a deck
+func loadResponses(tx *sql.Tx, uuid uuid.UUID) ([]StoredResponse, error) {
+
+	rows, err := tx.Query(`SELECT `+responseColumns+` FROM responses WHERE deck_id = ? ORDER BY position`, uuid.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var responses []StoredResponse
+	for rows.Next() {
+		response, err := scanResponse(rows)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, *response)
+	}
+
+	return responses, rows.Err()
+}
+
+// Writes the responses stored with a deck that differ from the
+// stored ones
+func saveResponses(tx *sql.Tx, uuid uuid.UUID, stored []StoredResponse, responses []StoredResponse) error {
+
+	var err error
+
+	same := func(i int) bool {
+		a, b := stored[i], responses[i]
+		return a.Key == b.Key && a.Fingerprint == b.Fingerprint && a.Status == b.Status &&
+			reflect.DeepEqual(a.Header, b.Header) && bytes.Equal(a.Body, b.Body) &&
+			a.CreatedAt.Equal(b.CreatedAt) && a.ExpiresAt.Equal(b.ExpiresAt)
+	}
+
+	values := func(i int) []any {
+		v := responses[i]
+
+		header, marshalErr := json.Marshal(v.Header)
+		if marshalErr != nil {
+			err = marshalErr
+		}
+
+		body := v.Body
+		if body == nil {
+			body = []byte{}
+		}
+
+		return []any{v.Key, v.Fingerprint, v.Status, string(header), body, v.CreatedAt.UnixNano(), v.ExpiresAt.UnixNano()}
+	}
+
+	saveErr := saveRows(tx, `responses`, []any{uuid.String()}, len(stored), len(responses), same, values,
+		strings.Split(responseColumns, ", ")...)
+
+	if err != nil {
+		return err
+	}
+
+	return saveErr
+}
+
+// Writes a deck and the rows of its cards, piles, tags and
+// responses that differ from the stored deck, which is empty
+// for a new deck
+func saveDeck(tx *sql.Tx, stored *Deck, deck *Deck) error {
+
+	_, err := tx.Exec(upsertDeckSql, append([]any{deck.Id.String()}, deckFields(deck)...)...)
+	if err != nil {
+		return err
+	}
+
+	if err := saveCards(tx, cardsTable, deck.Id, fromBottom(stored.Cards), fromBottom(deck.Cards)); err != nil {
+		return err
+	}
+
+	if err := saveCards(tx, drawnCardsTable, deck.Id, stored.Drawn, deck.Drawn); err != nil {
+		return err
+	}
+
+	if err := saveCards(tx, burnedCardsTable, deck.Id, stored.Burned, deck.Burned); err != nil {
+		return err
+	}
+
+	if err := savePiles(tx, deck.Id, stored.Piles, deck.Piles); err != nil {
+		return err
+	}
+
+	if err := saveTags(tx, deck.Id, stored.Tags, deck.Tags); err != nil {
+		return err
+	}
+
+	return saveResponses(tx, deck.Id, stored.Responses, deck.Responses)
+}
+
+// Writes the cards of a deck in one of the card tables that
+// differ from the stored ones at the same position
+func saveCards(tx *sql.Tx, table string, uuid uuid.UUID, stored []Card, cards []Card) error {
+
+	return saveRows(tx, table, []any{uuid.String()}, len(stored), len(cards),
+		func(i int) bool { return stored[i] == cards[i] },
+		func(i int) []any {
+			v := cards[i]
+			return []any{v.Value, v.Suit, v.Code, v.Instance}
+		},
+		`value`, `suit`, `code`, `instance`,
+	)
+}
+
+// Writes the rows of a table sorted by position, from the stored
+// amount of rows to the new amount. The key has the values of the
+// key columns before the position: the deck id, and the pile for
+// pile cards. Only the rows that are not the same as the stored
+// ones are written, with the values of the columns, and the rows
+// past the new amount are deleted
+func saveRows(tx *sql.Tx, table string, key []any, stored int, amount int, same func(i int) bool, values func(i int) []any, columns ...string) error {
+
+	keyColumns := []string{`deck_id`, `pile`}[:len(key)]
+	keyColumns = append(keyColumns, `position`)
+
+	var upsert *sql.Stmt
+
+	for i := 0; i < amount; i++ {
+		if i < stored && same(i) {
+			continue
+		}
+
+		if upsert == nil {
+			updates := make([]string, len(columns))
+			for j, v := range columns {
+				updates[j] = v + ` = excluded.` + v
+			}
+
+			var err error
+			upsert, err = tx.Prepare(`INSERT INTO ` + table + ` (` + strings.Join(append(keyColumns, columns...), `, `) + `)
+				VALUES (?` + strings.Repeat(`, ?`, len(keyColumns)+len(columns)-1) + `)
+				ON CONFLICT (` + strings.Join(keyColumns, `, `) + `) DO UPDATE SET ` + strings.Join(updates, `, `))
+			if err != nil {
+				return err
+			}
+			defer upsert.Close()
+		}
+
+		args := append(append(append([]any{}, key...), i), values(i)...)
+		if _, err := upsert.Exec(args...); err != nil {
+			return err
+		}
+	}
+
+	if amount < stored {
+		conditions := make([]string, len(key))
+		for i, v := range keyColumns[:len(key)] {
+			conditions[i] = v + ` = ?`
+		}
+
+		statement := `DELETE FROM ` + table + ` WHERE ` + strings.Join(conditions, ` AND `) + ` AND position >= ?`
+		if _, err := tx.Exec(statement, append(append([]any{}, key...), amount)...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeckRepository interface implementation
+
+func (r *SqlDeckRepository) Add(deck Deck) error {
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deck.Version = 1
+
+	if err := saveDeck(tx, &Deck{Id: deck.Id}, &deck); err != nil {
+		return fmt.Errorf("saving deck %s: %w", deck.Id, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing deck %s: %w", deck.Id, err)
+	}
+
+	return nil
+}
+
+func (r *SqlDeckRepository) GetDeckById(uuid uuid.UUID) (*Deck, error) {
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	return loadDeck(tx, uuid)
+}
+
+func (r *SqlDeckRepository) ListDecks(query DeckQuery) ([]Deck, error) {
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statement, args := listDecksSql(query)
+
+	rows, err := tx.Query(statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing decks: %w", err)
+	}
+	defer rows.Close()
+
+	decks := []Deck{}
+	for rows.Next() {
+		var id string
+		var deck Deck
+		if err := rows.Scan(append([]any{&id}, deckFields(&deck)...)...); err != nil {
+			return nil, fmt.Errorf("listing decks: %w", err)
+		}
+		if deck.Id, err = uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("listing decks: %w", err)
+		}
+		decks = append(decks, deck)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing decks: %w", err)
+	}
+
+	for i := range decks {
+		if decks[i].Tags, err = loadTags(tx, decks[i].Id); err != nil {
+			return nil, fmt.Errorf("loading deck %s: %w", decks[i].Id, err)
+		}
+	}
+
+	return decks, nil
+}
+
+// Builds the statement that lists the decks of a query, in
+// the same order as DeckQuery.compare, with its arguments
+func listDecksSql(query DeckQuery) (string, []any) {
+
+	var conditions []string
+	var args []any
+
+	if query.Shuffled != nil {
+		conditions = append(conditions, `shuffled = ?`)
+		args = append(args, *query.Shuffled)
+	}
+
+	if query.MinRemaining != nil {
+		conditions = append(conditions, `remaining >= ?`)
+		args = append(args, *query.MinRemaining)
+	}
+
+	if query.MaxRemaining != nil {
+		conditions = append(conditions, `remaining <= ?`)
+		args = append(args, *query.MaxRemaining)
+	}
+
+	if !query.CreatedAfter.IsZero() {
+		conditions = append(conditions, `created_at > ?`)
+		args = append(args, nanoTime(query.CreatedAfter))
+	}
+
+	if query.Owner != "" {
+		conditions = append(conditions, `owner = ?`)
+		args = append(args, query.Owner)
+	}
+
+	if query.Tag != "" {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM deck_tags WHERE deck_tags.deck_id = decks.id AND tag = ?)`)
+		args = append(args, query.Tag)
+	}
+
+	column := "created_at"
+	if query.Sort == SortByRemaining {
+		column = "remaining"
+	}
+
+	order, after := "ASC", ">"
+	if query.Descending {
+		order, after = "DESC", "<"
+	}
+
+	// Decks after the cursor, by value and then by id
+	if query.After != nil {
+		var value any = nanoTime(query.After.CreatedAt)
+		if query.Sort == SortByRemaining {
+			value = query.After.Remaining
+		}
+		conditions = append(conditions, `(`+column+` `+after+` ? OR (`+column+` = ? AND id `+after+` ?))`)
+		args = append(args, value, value, query.After.Id.String())
+	}
+
+	statement := `SELECT id, ` + strings.Join(deckColumns, ", ") + ` FROM decks`
+	if len(conditions) > 0 {
+		statement += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	statement += ` ORDER BY ` + column + ` ` + order + `, id ` + order
+
+	if query.Limit > 0 {
+		statement += ` LIMIT ?`
+		args = append(args, query.Limit)
+	}
+
+	return statement, args
+}
+
+func (r *SqlDeckRepository) GetDeckCardByCode(uuid uuid.UUID, code string) (*Card, error) {
+
+	card := &Card{}
+
+	err := r.db.QueryRow(
+		`SELECT value, suit, code, instance FROM cards WHERE deck_id = ? AND code = ? ORDER BY position DESC LIMIT 1`,
+		uuid.String(), code,
+	).Scan(&card.Value, &card.Suit, &card.Code, &card.Instance)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("loading card %s of deck %s: %w", code, uuid, err)
+	}
+
+	return card, nil
+}
+
+func (r *SqlDeckRepository) DrawCardsFromDeck(uuid uuid.UUID, amount int) ([]Card, error) {
+
+	var cards []Card
+
+	err := r.update(uuid, func(deck *Deck) error {
+		var err error
+		cards, err = drawCards(deck, amount)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+func (r *SqlDeckRepository) DrawCardsAt(uuid uuid.UUID, amount int, position Position, random func(int) int) ([]Card, error) {
+
+	var cards []Card
+
+	err := r.update(uuid, func(deck *Deck) error {
+		var err error
+		cards, err = drawCardsAt(deck, amount, position, random)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+func (r *SqlDeckRepository) DrawCardsByCode(uuid uuid.UUID, codes []string) ([]Card, error) {
+
+	var cards []Card
+
+	err := r.update(uuid, func(deck *Deck) error {
+		var err error
+		cards, err = drawCardsByCode(deck, codes)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+func (r *SqlDeckRepository) PeekCards(uuid uuid.UUID, amount int) ([]Card, error) {
+
+	deck, err := r.GetDeckById(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	return peekCards(deck, amount)
+}
+
+func (r *SqlDeckRepository) BurnCards(uuid uuid.UUID, amount int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return burnCards(deck, amount)
+	})
+}
+
+func (r *SqlDeckRepository) CloseDeck(uuid uuid.UUID) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		closeDeck(deck)
+		return nil
+	})
+}
+
+func (r *SqlDeckRepository) DeleteDeck(uuid uuid.UUID) error {
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteDeck(tx, uuid); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing deck %s: %w", uuid, err)
+	}
+
+	return nil
+}
+
+// Deletes a deck with its cards, piles and responses
+func deleteDeck(tx *sql.Tx, uuid uuid.UUID) error {
+
+	// Cards and piles are deleted before their deck, so that
+	// they don't depend on the foreign keys being enforced
+	for _, table := range []string{"cards", "drawn_cards", "burned_cards", "pile_cards", "piles", "responses", "deck_tags"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE deck_id = ?`, uuid.String()); err != nil {
+			return fmt.Errorf("deleting deck %s: %w", uuid, err)
+		}
+	}
+
+	result, err := tx.Exec(`DELETE FROM decks WHERE id = ?`, uuid.String())
+	if err != nil {
+		return fmt.Errorf("deleting deck %s: %w", uuid, err)
+	}
+
+	if deleted, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("deleting deck %s: %w", uuid, err)
+	} else if deleted == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *SqlDeckRepository) ShuffleDeck(uuid uuid.UUID, includeDrawn bool, permutation func(int) []int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return shuffleDeck(deck, includeDrawn, permutation)
+	})
+}
+
+func (r *SqlDeckRepository) ReturnCards(uuid uuid.UUID, codes []string, position Position, random func(int) int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return returnCards(deck, codes, position, random)
+	})
+}
+
+func (r *SqlDeckRepository) CutDeck(uuid uuid.UUID, position func(int) int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return cutDeck(deck, position)
+	})
+}
+
+func (r *SqlDeckRepository) SeedDeck(uuid uuid.UUID, clientSeed string, commitment string, permutation func(int) []int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return seedDeck(deck, clientSeed, commitment, permutation)
+	})
+}
+
+func (r *SqlDeckRepository) CreatePile(uuid uuid.UUID, pile Pile) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return createPile(deck, pile)
+	})
+}
+
+func (r *SqlDeckRepository) DrawCardsToPile(uuid uuid.UUID, name string, amount int, position Position, random func(int) int) ([]Card, error) {
+
+	var cards []Card
+
+	err := r.update(uuid, func(deck *Deck) error {
+		var err error
+		cards, err = drawCardsToPile(deck, name, amount, position, random)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+func (r *SqlDeckRepository) MovePileCards(uuid uuid.UUID, from string, to string, codes []string) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return movePileCards(deck, from, to, codes)
+	})
+}
+
+func (r *SqlDeckRepository) ShufflePile(uuid uuid.UUID, name string, permutation func(int) []int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return shufflePile(deck, name, permutation)
+	})
+}
+
+func (r *SqlDeckRepository) ReturnPile(uuid uuid.UUID, name string, position Position, random func(int) int) (*Deck, error) {
+
+	return r.modify(uuid, func(deck *Deck) error {
+		return returnPile(deck, name, position, random)
+	})
+}
+
+func (r *SqlDeckRepository) SaveResponse(uuid uuid.UUID, response StoredResponse) error {
+
+	// Storing a response doesn't change the deck seen by clients
+	return r.updateDeck(uuid, false, func(deck *Deck) error {
+		return saveResponse(deck, response)
+	})
+}
+
+func (r *SqlDeckRepository) GetResponse(key string) (*StoredResponse, error) {
+
+	row := r.db.QueryRow(
+		`SELECT `+responseColumns+` FROM responses WHERE idempotency_key = ? ORDER BY created_at DESC LIMIT 1`,
+		key,
+	)
+
+	response, err := scanResponse(row)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("loading response %s: %w", key, err)
+	}
+
+	return response, nil
+}
+
+func (r *SqlDeckRepository) UpdateIfVersion(uuid uuid.UUID, versions []int64, change func(DeckRepository) error) error {
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	deck, err := loadDeck(tx, uuid)
+	if err != nil {
+		return err
+	}
+
+	if !versionMatches(deck.Version, versions) {
+		return ErrVersionMismatch
+	}
+
+	changed, err := applyChange(deck, change)
+	if err != nil {
+		return err
+	}
+
+	if changed == nil {
+		err = deleteDeck(tx, uuid)
+	} else {
+		changed.Version = deck.Version + 1
+		err = saveDeck(tx, deck, changed)
+	}
+
+	if err != nil {
+		return fmt.Errorf("saving deck %s: %w", uuid, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing deck %s: %w", uuid, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+// Author: Ferran Balaguer
+
+package data
+
+import (
+	"database/sql"
+)
+
+// Schema migrations of the SQL repository. Each one is applied
+// once in its own transaction, in order, and its position in
+// the slice is its version. Applied migrations must never change,
+// new ones are appended at the end
+var sqlMigrations = []string{
+	// 1: decks and their cards, sorted by position from the top
+	`CREATE TABLE decks (
+		id          TEXT PRIMARY KEY,
+		composition TEXT NOT NULL,
+		decks       INTEGER NOT NULL,
+		shuffled    INTEGER NOT NULL,
+		remaining   INTEGER NOT NULL,
+		cut_card    INTEGER NOT NULL
+	);
+	CREATE TABLE cards (
+		deck_id  TEXT NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		value    INTEGER NOT NULL,
+		suit     INTEGER NOT NULL,
+		code     TEXT NOT NULL,
+		instance INTEGER NOT NULL,
+		PRIMARY KEY (deck_id, position)
+	);`,
+	// 2: seed of the shuffle
+	`ALTER TABLE decks ADD COLUMN seed INTEGER NOT NULL DEFAULT 0;`,
+	// 3: jokers and provably fair decks
+	`ALTER TABLE decks ADD COLUMN jokers INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE decks ADD COLUMN fair INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE decks ADD COLUMN server_seed TEXT NOT NULL DEFAULT '';
+	ALTER TABLE decks ADD COLUMN client_seed TEXT NOT NULL DEFAULT '';
+	ALTER TABLE decks ADD COLUMN commitment TEXT NOT NULL DEFAULT '';
+	ALTER TABLE decks ADD COLUMN closed INTEGER NOT NULL DEFAULT 0;`,
+	// 4: whether the deck was shuffled with the stored seed
+	`ALTER TABLE decks ADD COLUMN seeded INTEGER NOT NULL DEFAULT 0;`,
+	// 5: cards drawn and not returned, in the order they were drawn
+	`CREATE TABLE drawn_cards (
+		deck_id  TEXT NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		value    INTEGER NOT NULL,
+		suit     INTEGER NOT NULL,
+		code     TEXT NOT NULL,
+		instance INTEGER NOT NULL,
+		PRIMARY KEY (deck_id, position)
+	);`,
+	// 6: cards burned from the top, in the order they were burned
+	`CREATE TABLE burned_cards (
+		deck_id  TEXT NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		value    INTEGER NOT NULL,
+		suit     INTEGER NOT NULL,
+		code     TEXT NOT NULL,
+		instance INTEGER NOT NULL,
+		PRIMARY KEY (deck_id, position)
+	);`,
+	// 7: named piles of every deck and their cards. The pile of
+	// a card is the position of the pile in the deck
+	`CREATE TABLE piles (
+		deck_id  TEXT NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		name     TEXT NOT NULL,
+		PRIMARY KEY (deck_id, position),
+		UNIQUE (deck_id, name)
+	);
+	CREATE TABLE pile_cards (
+		deck_id  TEXT NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+		pile     INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		value    INTEGER NOT NULL,
+		suit     INTEGER NOT NULL,
+		code     TEXT NOT NULL,
+		instance INTEGER NOT NULL,
+		PRIMARY KEY (deck_id, pile, position)
+	);`,
+	// 8: player that dealt each deck and holder and face of each pile
+	`ALTER TABLE decks ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+	ALTER TABLE piles ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+	ALTER TABLE piles ADD COLUMN face_up INTEGER NOT NULL DEFAULT 0;`,
+	// 9: responses to the requests made with an idempotency key,
+	// with their header as JSON and their times in nanoseconds
+	`CREATE TABLE responses (
+		deck_id         TEXT NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+		position        INTEGER NOT NULL,
+		idempotency_key TEXT NOT NULL,
+		fingerprint     TEXT NOT NULL,
+		status          INTEGER NOT NULL,
+		header          TEXT NOT NULL,
+		body            BLOB NOT NULL,
+		created_at      INTEGER NOT NULL,
+		expires_at      INTEGER NOT NULL,
+		PRIMARY KEY (deck_id, position)
+	);
+	CREATE INDEX responses_key ON responses (idempotency_key);`,
+	// 10: version of every deck, incremented with each change
+	`ALTER TABLE decks ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+	// 11: creation time of every deck in nanoseconds, 0 for the
+	// decks created before, and their tags
+	`ALTER TABLE decks ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX decks_created_at ON decks (created_at, id);
+	CREATE INDEX decks_remaining ON decks (remaining, id);
+	CREATE TABLE deck_tags (
+		deck_id  TEXT NOT NULL REFERENCES decks(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		tag      TEXT NOT NULL,
+		PRIMARY KEY (deck_id, position)
+	);
+	CREATE INDEX deck_tags_tag ON deck_tags (tag);`,
+	// 12: whether the order of the cards left is hidden
+	`ALTER TABLE decks ADD COLUMN hide_order INTEGER NOT NULL DEFAULT 0;`,
+	// 13: cards left sorted by position from the bottom, so that
+	// drawing from the top only deletes rows. Positions are made
+	// negative first, so that no two cards share one meanwhile
+	`UPDATE cards SET position = -1 - position;
+	UPDATE cards SET position = position + (SELECT COUNT(*) FROM cards AS c WHERE c.deck_id = cards.deck_id);`,
+}
+
+// Applies every migration not applied yet
+func migrate(db *sql.DB) error {
+
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	err = db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(sqlMigrations); i++ {
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(sqlMigrations[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+// Author: Ferran Balaguer
+
+// Package fairness implements the provably fair shuffle. It only
+// depends on the standard library, so that anyone can verify a
+// deck with it (see cmd/verifydeck).
+//
+// The shuffle is a Fisher–Yates shuffle whose random numbers come
+// from HMAC-SHA256, keyed with the server seed, over the messages
+// "<client seed>:0", "<client seed>:1"... Every 32 bytes block is
+// read as 8 big endian uint32, and bounded numbers are generated
+// by rejection sampling so that they are unbiased.
+//
+// The hex SHA-256 of the server seed is published when the deck
+// is created, before the client seed is chosen, so that the
+// server can't pick its seed after knowing the client one. The
+// commitment published once the deck is shuffled is the hex
+// SHA-256 of "<server seed>:<card codes of the shuffled deck
+// joined by commas>".
+//
+// The deck is shuffled from its initial order (see InitialCodes),
+// which only depends on its composition, jokers and decks
+
+package fairness
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrSeedHashMismatch   = errors.New("Server seed hash does not match the server seed")
+	ErrCommitmentMismatch = errors.New("Commitment does not match the server seed and cards")
+	ErrOrderMismatch      = errors.New("Cards order does not match the seeds")
+	ErrInitialMismatch    = errors.New("Initial cards do not match the composition of the deck")
+	ErrInvalidDeck        = errors.New("Invalid composition, jokers or decks")
+)
+
+// Everything needed to verify a fair deck once the server
+// seed has been revealed
+type Reveal struct {
+	ServerSeed     string `json:"server_seed"`
+	ServerSeedHash string `json:"server_seed_hash"`
+	ClientSeed     string `json:"client_seed"`
+	Commitment     string `json:"commitment"`
+	// Cards of the deck, from which its initial order is built
+	Composition string `json:"composition"`
+	Jokers      int    `json:"jokers"`
+	Decks       int    `json:"decks"`
+	// Card codes before shuffling, which must be the initial
+	// order of the composition
+	InitialCards []string `json:"initial_cards"`
+	// Card codes after shuffling, as the deck was dealt
+	Cards []string `json:"cards"`
+}
+
+// Deterministic random numbers generated from the seeds
+type Source struct {
+	serverSeed string
+	clientSeed string
+	counter    uint64
+	block      []byte
+}
+
+// Creates the random source of a pair of seeds
+func NewSource(serverSeed string, clientSeed string) *Source {
+	return &Source{
+		serverSeed: serverSeed,
+		clientSeed: clientSeed,
+	}
+}
+
+// Returns the next uint32 of the stream
+func (s *Source) Uint32() uint32 {
+
+	if len(s.block) == 0 {
+		mac := hmac.New(sha256.New, []byte(s.serverSeed))
+		mac.Write([]byte(s.clientSeed + ":" + strconv.FormatUint(s.counter, 10)))
+		s.block = mac.Sum(nil)
+		s.counter++
+	}
+
+	value := binary.BigEndian.Uint32(s.block)
+	s.block = s.block[4:]
+
+	return value
+}
+
+// Returns a uniform random int in [0, n). n must be greater
+// than 0 and fit in a uint32
+func (s *Source) Intn(n int) int {
+
+	bound := uint64(n)
+	// Values from limit up are discarded, as they would
+	// make the lowest results more likely
+	limit := (1 << 32) / bound * bound
+
+	for {
+		value := uint64(s.Uint32())
+		if value < limit {
+			return int(value % bound)
+		}
+	}
+}
+
+// Returns a new random server seed
+func NewServerSeed() (string, error) {
+
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(seed), nil
+}
+
+// Returns the hash of a server seed, published before the
+// client seed is chosen
+func SeedHash(serverSeed string) string {
+
+	sum := sha256.Sum256([]byte(serverSeed))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Values of each composition, repeated for every suit, and how
+// many times the whole set is repeated in one deck
+var compositions = map[string]struct {
+	values string
+	copies int
+}{
+	"standard": {"A23456789TJQK", 1},
+	"piquet":   {"A789TJQK", 1},
+	"euchre":   {"A9TJQK", 1},
+	"pinochle": {"A9TJQK", 2},
+}
+
+// Returns the card codes of a deck before shuffling: every deck
+// of the shoe one after the other, each one with its cards sorted
+// by suit (spades, diamonds, clubs, hearts) and value, followed
+// by its jokers, red first
+func InitialCodes(composition string, jokers int, decks int) ([]string, error) {
+
+	set, ok := compositions[composition]
+	if !ok || jokers < 0 || jokers > 2 || decks < 1 {
+		return nil, fmt.Errorf("%w: %q, %d jokers, %d decks", ErrInvalidDeck, composition, jokers, decks)
+	}
+
+	var codes []string
+
+	for d := 0; d < decks; d++ {
+		for i := 0; i < set.copies; i++ {
+			for _, suit := range "SDCH" {
+				for _, value := range set.values {
+					codes = append(codes, string(value)+string(suit))
+				}
+			}
+		}
+		codes = append(codes, []string{"XR", "XB"}[:jokers]...)
+	}
+
+	return codes, nil
+}
+
+// Returns the Fisher–Yates permutation of length n for the
+// seeds. Position i of the shuffled deck holds the card at
+// position permutation[i] of the initial deck
+func Permutation(n int, serverSeed string, clientSeed string) []int {
+
+	source := NewSource(serverSeed, clientSeed)
+	a := make([]int, n)
+
+	for i := range a {
+		a[i] = i
+	}
+
+	for i := len(a) - 1; i > 0; i-- {
+		j := source.Intn(i + 1)
+		a[i], a[j] = a[j], a[i]
+	}
+
+	return a
+}
+
+// Shuffles the card codes with the seeds
+func ShuffleCodes(codes []string, serverSeed string, clientSeed string) []string {
+
+	result := make([]string, len(codes))
+
+	for i, v := range Permutation(len(codes), serverSeed, clientSeed) {
+		result[i] = codes[v]
+	}
+
+	return result
+}
+
+// Returns the commitment of a server seed and a shuffled deck
+func Commitment(serverSeed string, codes []string) string {
+
+	sum := sha256.Sum256([]byte(serverSeed + ":" + strings.Join(codes, ",")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Checks that the server seed matches the hash published before
+// the client seed, that the commitment matches the server seed
+// and cards, and that the cards are the shuffle with the seeds
+// of the initial order of the deck. The initial order is built
+// from the composition, jokers and decks, so the revealed initial
+// cards must be that order
+func Verify(reveal Reveal) error {
+
+	if SeedHash(reveal.ServerSeed) != reveal.ServerSeedHash {
+		return ErrSeedHashMismatch
+	}
+
+	if Commitment(reveal.ServerSeed, reveal.Cards) != reveal.Commitment {
+		return ErrCommitmentMismatch
+	}
+
+	initial, err := InitialCodes(reveal.Composition, reveal.Jokers, reveal.Decks)
+	if err != nil {
+		return err
+	}
+
+	if len(initial) != len(reveal.InitialCards) {
+		return ErrInitialMismatch
+	}
+
+	for i, v := range initial {
+		if reveal.InitialCards[i] != v {
+			return ErrInitialMismatch
+		}
+	}
+
+	shuffled := ShuffleCodes(initial, reveal.ServerSeed, reveal.ClientSeed)
+
+	if len(shuffled) != len(reveal.Cards) {
+		return ErrOrderMismatch
+	}
+
+	for i, v := range shuffled {
+		if reveal.Cards[i] != v {
+			return ErrOrderMismatch
+		}
+	}
+
+	return nil
+}
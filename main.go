@@ -1,12 +1,17 @@
 package main
 
 import (
+	"log"
 	"test/cardsgame/routes"
 )
 
 func main() {
 
 	// Setup and start server
-	router := routes.InitialiseRoutes()
+	router, err := routes.InitialiseRoutes(routes.LoadConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	router.Run("localhost:8080")
 }
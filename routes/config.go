@@ -0,0 +1,49 @@
+// Author: Ferran Balaguer
+
+package routes
+
+import (
+	"os"
+)
+
+// Storage backends
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+	StorageSql    = "sqlite"
+)
+
+// Service configuration
+type Config struct {
+	// Storage backend used by the deck repository
+	Storage string
+	// Directory where the file and sqlite storages keep their data
+	DataDir string
+	// Token the operators send to list every deck. Nobody can
+	// list them if it is empty
+	OperatorToken string
+}
+
+// Reads the configuration from the environment:
+//   - CARDSGAME_STORAGE: "memory" (default), "file" or "sqlite"
+//   - CARDSGAME_DATA_DIR: data directory, "./storage" by default
+//   - CARDSGAME_OPERATOR_TOKEN: token of the operators, none by default
+func LoadConfig() Config {
+
+	config := Config{
+		Storage: StorageMemory,
+		DataDir: "./storage",
+	}
+
+	if value := os.Getenv("CARDSGAME_STORAGE"); value != "" {
+		config.Storage = value
+	}
+
+	if value := os.Getenv("CARDSGAME_DATA_DIR"); value != "" {
+		config.DataDir = value
+	}
+
+	config.OperatorToken = os.Getenv("CARDSGAME_OPERATOR_TOKEN")
+
+	return config
+}
@@ -3,7 +3,10 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
+	"os"
+	"path/filepath"
 	"test/cardsgame/api"
 	"test/cardsgame/controllers"
 	"test/cardsgame/data"
@@ -11,8 +14,30 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	ErrInvalidStorage = errors.New("Invalid storage")
+)
+
+// Creates the deck repository selected in the configuration
+func newDeckRepository(config Config) (data.DeckRepository, error) {
+
+	switch config.Storage {
+	case StorageMemory:
+		return &data.MemoryDeckRepository{}, nil
+	case StorageFile:
+		return data.NewFileDeckRepository(config.DataDir)
+	case StorageSql:
+		if err := os.MkdirAll(config.DataDir, 0755); err != nil {
+			return nil, err
+		}
+		return data.NewSqlDeckRepository(filepath.Join(config.DataDir, "cardsgame.db"))
+	}
+
+	return nil, ErrInvalidStorage
+}
+
 // Initialise all required routes and handlers
-func InitialiseRoutes() *gin.Engine {
+func InitialiseRoutes(config Config) (*gin.Engine, error) {
 
 	router := gin.Default()
 
@@ -25,16 +50,47 @@ func InitialiseRoutes() *gin.Engine {
 	router.Static("/swagger/v1", "./dist")
 
 	// REST Handler Initialisation
-	deckRepo := &data.MemoryDeckRepository{}
+	deckRepo, err := newDeckRepository(config)
+	if err != nil {
+		return nil, err
+	}
 	deckController := controllers.NewDeckController(deckRepo)
 	deckHandler := api.NewDeckHandler(deckController)
 
 	// REST Routes definition
 
-	api := router.Group("/api/v1")
-	api.POST("/deck", deckHandler.CreateDeck)
-	api.GET("/deck/:uuid", deckHandler.OpenDeck)
-	api.GET("/deck/:uuid/cards", deckHandler.DrawCard)
+	v1 := router.Group("/api/v1")
+	// Retries of the requests with an Idempotency-Key header
+	// get the first response instead of running them again, and
+	// the changes with an If-Match header only apply to the
+	// version of the deck in the header
+	handle := deckHandler.Handle
+	idempotent := deckHandler.Idempotent
+	conditional := api.Conditional
+
+	// Listing every deck shows their ids, so it is only for operators
+	v1.GET("/decks", api.RequireOperator(config.OperatorToken), deckHandler.ListDecks)
+	v1.POST("/deck", idempotent((*api.DeckHandler).CreateDeck))
+	v1.GET("/deck/:uuid", deckHandler.OpenDeck)
+	v1.HEAD("/deck/:uuid", deckHandler.OpenDeck)
+	v1.DELETE("/deck/:uuid", handle(conditional((*api.DeckHandler).DeleteDeck)))
+	v1.POST("/deck/:uuid/draw", idempotent(conditional((*api.DeckHandler).DrawCard)))
+	// Deprecated, drawing with GET is replaced by the POST
+	v1.GET("/deck/:uuid/cards", idempotent(conditional((*api.DeckHandler).DrawCardDeprecated)))
+	v1.GET("/deck/:uuid/peek", deckHandler.PeekCards)
+	v1.POST("/deck/:uuid/burn", handle(conditional((*api.DeckHandler).BurnCards)))
+	v1.POST("/deck/:uuid/seed", handle(conditional((*api.DeckHandler).SeedDeck)))
+	v1.POST("/deck/:uuid/close", handle(conditional((*api.DeckHandler).CloseDeck)))
+	v1.POST("/deck/:uuid/shuffle", idempotent(conditional((*api.DeckHandler).ShuffleDeck)))
+	v1.POST("/deck/:uuid/return", handle(conditional((*api.DeckHandler).ReturnCards)))
+	v1.POST("/deck/:uuid/cut", handle(conditional((*api.DeckHandler).CutDeck)))
+	v1.POST("/deck/:uuid/piles", handle(conditional((*api.DeckHandler).CreatePile)))
+	v1.GET("/deck/:uuid/piles/:pile", deckHandler.GetPile)
+	v1.POST("/deck/:uuid/piles/:pile/draw", idempotent(conditional((*api.DeckHandler).DrawCardsToPile)))
+	v1.POST("/deck/:uuid/piles/:pile/move", handle(conditional((*api.DeckHandler).MovePileCards)))
+	v1.POST("/deck/:uuid/piles/:pile/shuffle", idempotent(conditional((*api.DeckHandler).ShufflePile)))
+	v1.POST("/deck/:uuid/piles/:pile/return", handle(conditional((*api.DeckHandler).ReturnPile)))
+	v1.GET("/deck/:uuid/reveal", deckHandler.RevealDeck)
 
-	return router
+	return router, nil
 }
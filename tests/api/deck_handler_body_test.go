@@ -0,0 +1,129 @@
+// Author: Ferran Balaguer
+
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"test/cardsgame/api"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sends a request with a JSON body to the router
+func sendJSON(router *gin.Engine, method string, path string, body string) *httptest.ResponseRecorder {
+
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+// Tests the creation of decks with a JSON body
+func TestCreateDeckBody(t *testing.T) {
+
+	router := newRouter(t)
+
+	recorder := sendJSON(router, http.MethodPost, "/api/v1/deck", `{"shuffle":false,"cards":["as","KH","0S"]}`)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("The status should be %d, got %d", http.StatusCreated, recorder.Code)
+	}
+
+	var created api.DeckNoCardsDto
+	if err := json.Unmarshal(recorder.Body.Bytes(), &created); err != nil {
+		t.Fatalf("The body should be a deck: %v", err)
+	}
+
+	if created.Remaining != 3 || created.Shuffled {
+		t.Errorf("There should be 3 cards not shuffled, got %+v", created)
+	}
+
+	recorder = send(router, http.MethodGet, "/api/v1/deck/"+created.Id.String())
+
+	var opened api.DeckDto
+	if err := json.Unmarshal(recorder.Body.Bytes(), &opened); err != nil {
+		t.Fatalf("The body should be a deck: %v", err)
+	}
+
+	codes := []string{}
+	for _, v := range opened.Cards {
+		codes = append(codes, v.Code)
+	}
+	if strings.Join(codes, ",") != "AS,KH,TS" {
+		t.Errorf("Should have returned AS,KH,TS, got %v", codes)
+	}
+
+	// Query parameters are ignored with a body
+	recorder = sendJSON(router, http.MethodPost, "/api/v1/deck?jokers=x", `{"composition":"piquet","seed":42,"shuffle":true}`)
+	if recorder.Code != http.StatusCreated {
+		t.Errorf("The status should be %d, got %d", http.StatusCreated, recorder.Code)
+	}
+
+	// The query string form is still accepted
+	recorder = send(router, http.MethodPost, "/api/v1/deck?cards=AS,KH")
+	if recorder.Code != http.StatusCreated {
+		t.Errorf("The status should be %d, got %d", http.StatusCreated, recorder.Code)
+	}
+
+	// An empty body falls back to the query string, even with
+	// a JSON content type
+	for _, body := range []string{"", " \n"} {
+		recorder = sendJSON(router, http.MethodPost, "/api/v1/deck?cards=AS,KH", body)
+		json.Unmarshal(recorder.Body.Bytes(), &created)
+		if recorder.Code != http.StatusCreated || created.Remaining != 2 {
+			t.Errorf("An empty body %q should create the deck of the query, got %d with %d cards", body, recorder.Code, created.Remaining)
+		}
+	}
+
+	// Also when its length is unknown
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/deck?cards=AS,KH", strings.NewReader(""))
+	request.Header.Set("Content-Type", "application/json")
+	request.ContentLength = -1
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	json.Unmarshal(recorder.Body.Bytes(), &created)
+	if recorder.Code != http.StatusCreated || created.Remaining != 2 {
+		t.Errorf("An empty chunked body should create the deck of the query, got %d with %d cards", recorder.Code, created.Remaining)
+	}
+}
+
+// Tests the problem responses of invalid JSON bodies
+func TestCreateDeckInvalidBody(t *testing.T) {
+
+	router := newRouter(t)
+
+	tests := []struct {
+		body   string
+		status int
+		code   string
+	}{
+		{`{"shuffle":"yes"}`, http.StatusBadRequest, "invalid_body"},
+		{`{"shufle":true}`, http.StatusBadRequest, "invalid_body"},
+		{`{"cards":"AS,KH"}`, http.StatusBadRequest, "invalid_body"},
+		{`{"cards":["AS",""]}`, http.StatusBadRequest, "invalid_body"},
+		{`{"jokers":1.5}`, http.StatusBadRequest, "invalid_body"},
+		{`{} {}`, http.StatusBadRequest, "invalid_body"},
+		{`{`, http.StatusBadRequest, "invalid_body"},
+		{`{"jokers":-1}`, http.StatusBadRequest, "invalid_jokers"},
+		{`{"seed":42}`, http.StatusBadRequest, "seed_without_shuffle"},
+		{`{"cards":["XX","AS","AS"],"allow_duplicates":false}`, http.StatusBadRequest, "invalid_card_code"},
+		{`{"cards":["AS","AS"],"allow_duplicates":false}`, http.StatusBadRequest, "duplicate_card_code"},
+	}
+
+	for _, v := range tests {
+		recorder := sendJSON(router, http.MethodPost, "/api/v1/deck", v.body)
+		expectProblem(t, recorder, v.status, v.code)
+	}
+
+	// Repeated codes are allowed by default
+	recorder := sendJSON(router, http.MethodPost, "/api/v1/deck", `{"cards":["AS","AS"]}`)
+	if recorder.Code != http.StatusCreated {
+		t.Errorf("The status should be %d, got %d", http.StatusCreated, recorder.Code)
+	}
+}
@@ -0,0 +1,131 @@
+// Author: Ferran Balaguer
+
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"test/cardsgame/api"
+	"test/cardsgame/data"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sends a request with an idempotency key to the router
+func sendWithKey(router *gin.Engine, method string, path string, key string, body string) *httptest.ResponseRecorder {
+
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set(api.IdempotencyHeader, key)
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+// Tests that retried creations and draws get the first response
+func TestIdempotentRetries(t *testing.T) {
+
+	router := newRouter(t)
+
+	created := sendWithKey(router, http.MethodPost, "/api/v1/deck", "create-1", `{"shuffle":true}`)
+	retried := sendWithKey(router, http.MethodPost, "/api/v1/deck", "create-1", `{"shuffle":true}`)
+
+	if retried.Code != http.StatusCreated || retried.Body.String() != created.Body.String() {
+		t.Errorf("The retry should get the first response, got %d %s", retried.Code, retried.Body.String())
+	}
+
+	location := created.Header().Get("Location")
+	if retried.Header().Get("Location") != location || retried.Header().Get(api.ReplayedHeader) != "true" {
+		t.Errorf("The retry should get the first headers, got %v", retried.Header())
+	}
+
+	if created.Header().Get(api.ReplayedHeader) != "" {
+		t.Errorf("The first response should not be replayed")
+	}
+
+	drawn := sendWithKey(router, http.MethodPost, location+"/draw?amount=3", "draw-1", "")
+	retried = sendWithKey(router, http.MethodPost, location+"/draw?amount=3", "draw-1", "")
+
+	if retried.Code != http.StatusOK || retried.Body.String() != drawn.Body.String() {
+		t.Errorf("The retry should get the same cards, got %s", retried.Body.String())
+	}
+
+	var deck api.DeckDto
+	json.Unmarshal(send(router, http.MethodGet, location).Body.Bytes(), &deck)
+	if deck.Remaining != 49 {
+		t.Errorf("The cards should only be drawn once, %d remaining", deck.Remaining)
+	}
+
+	// Another key draws again
+	sendWithKey(router, http.MethodPost, location+"/draw?amount=3", "draw-2", "")
+	json.Unmarshal(send(router, http.MethodGet, location).Body.Bytes(), &deck)
+	if deck.Remaining != 46 {
+		t.Errorf("Remaining cards must be = 46, got %d", deck.Remaining)
+	}
+}
+
+// Tests the keys reused for different requests and the failed
+// requests, which are not stored
+func TestIdempotentConflicts(t *testing.T) {
+
+	router := newRouter(t)
+
+	location := sendWithKey(router, http.MethodPost, "/api/v1/deck", "create-1", "").Header().Get("Location")
+
+	sendWithKey(router, http.MethodPost, location+"/draw?amount=1", "draw-1", "")
+	recorder := sendWithKey(router, http.MethodPost, location+"/draw?amount=2", "draw-1", "")
+	expectProblem(t, recorder, http.StatusUnprocessableEntity, "idempotency_key_reused")
+
+	recorder = sendWithKey(router, http.MethodPost, "/api/v1/deck", "create-1", `{"shuffle":true}`)
+	expectProblem(t, recorder, http.StatusUnprocessableEntity, "idempotency_key_reused")
+
+	recorder = sendWithKey(router, http.MethodPost, location+"/draw", "two words", "")
+	expectProblem(t, recorder, http.StatusBadRequest, "invalid_idempotency_key")
+
+	// A failed draw is run again when retried
+	recorder = sendWithKey(router, http.MethodPost, location+"/draw?amount=60", "draw-2", "")
+	expectProblem(t, recorder, http.StatusBadRequest, "not_enough_cards")
+
+	recorder = sendWithKey(router, http.MethodPost, location+"/draw?amount=60", "draw-2", "")
+	if recorder.Header().Get(api.ReplayedHeader) != "" {
+		t.Errorf("Failed requests should not be replayed")
+	}
+	expectProblem(t, recorder, http.StatusBadRequest, "not_enough_cards")
+}
+
+// Tests that a deck with every response slot in use rejects new
+// keys without changing, and still replays the stored responses
+func TestIdempotencyStoreFull(t *testing.T) {
+
+	router := newRouter(t)
+
+	location := send(router, http.MethodPost, "/api/v1/deck").Header().Get("Location")
+
+	for i := 0; i < data.MaxResponses; i++ {
+		if recorder := sendWithKey(router, http.MethodPost, location+"/shuffle", fmt.Sprintf("shuffle-%d", i), ""); recorder.Code != http.StatusOK {
+			t.Fatalf("Should have returned %d, got %d", http.StatusOK, recorder.Code)
+		}
+	}
+
+	before := send(router, http.MethodGet, location).Header().Get("ETag")
+
+	recorder := sendWithKey(router, http.MethodPost, location+"/draw", "draw-1", "")
+	expectProblem(t, recorder, http.StatusTooManyRequests, "idempotency_store_full")
+
+	if etag := send(router, http.MethodGet, location).Header().Get("ETag"); etag != before {
+		t.Errorf("The rejected draw should not change the deck, got %s instead of %s", etag, before)
+	}
+
+	recorder = sendWithKey(router, http.MethodPost, location+"/shuffle", "shuffle-0", "")
+	if recorder.Code != http.StatusOK || recorder.Header().Get(api.ReplayedHeader) != "true" {
+		t.Errorf("The stored responses should still be replayed, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,54 @@
+// Author: Ferran Balaguer
+
+package api_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"test/cardsgame/api"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Memory repository whose reads fail with a storage error
+type brokenRepository struct {
+	*data.MemoryDeckRepository
+}
+
+func (r *brokenRepository) GetDeckById(uuid.UUID) (*data.Deck, error) {
+	return nil, errors.New("disk failure at /var/lib/cardsgame")
+}
+
+// Tests that internal errors return 500 with a correlation id
+// and without their details
+func TestInternalErrorProblem(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	controller := controllers.NewDeckController(&brokenRepository{&data.MemoryDeckRepository{}})
+	router := gin.New()
+	router.GET("/deck/:uuid", api.NewDeckHandler(controller).OpenDeck)
+
+	recorder := send(router, http.MethodGet, "/deck/"+uuid.New().String())
+	problem := expectProblem(t, recorder, http.StatusInternalServerError, "internal_error")
+
+	if problem.CorrelationId == "" || recorder.Header().Get(api.CorrelationHeader) != problem.CorrelationId {
+		t.Errorf("The problem should have the correlation id of its header, got %q", problem.CorrelationId)
+	}
+
+	if problem.Detail != "" || strings.Contains(recorder.Body.String(), "disk") {
+		t.Errorf("The problem should not have the details of the error, got %s", recorder.Body.String())
+	}
+
+	var other api.ProblemDto
+	json.Unmarshal(send(router, http.MethodGet, "/deck/"+uuid.New().String()).Body.Bytes(), &other)
+	if other.CorrelationId == problem.CorrelationId {
+		t.Errorf("Every internal error should have its own correlation id")
+	}
+}
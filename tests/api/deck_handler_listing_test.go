@@ -0,0 +1,154 @@
+// Author: Ferran Balaguer
+
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"test/cardsgame/api"
+	"test/cardsgame/data"
+	"test/cardsgame/routes"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Token of the operators of the routers created by
+// newOperatorRouter
+const operatorToken = "operator-token"
+
+// Creates the service router with the memory storage and the
+// operator token
+func newOperatorRouter(t *testing.T) *gin.Engine {
+
+	gin.SetMode(gin.TestMode)
+
+	router, err := routes.InitialiseRoutes(routes.Config{Storage: routes.StorageMemory, OperatorToken: operatorToken})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	return router
+}
+
+// Sends a request with the operator token to the router
+func sendAsOperator(router *gin.Engine, method string, path string) *httptest.ResponseRecorder {
+	return sendWithHeader(router, method, path, "Authorization", "Bearer "+operatorToken)
+}
+
+// Tests listing the decks with filters and pages
+func TestListDecks(t *testing.T) {
+
+	router := newOperatorRouter(t)
+
+	before := time.Now().UTC().Add(-time.Second)
+
+	send(router, http.MethodPost, "/api/v1/deck?tags=poker,table-1")
+	send(router, http.MethodPost, "/api/v1/deck?shuffle=true&tags=poker")
+	sendJSON(router, http.MethodPost, "/api/v1/deck", `{"tags":["blackjack"],"decks":2}`)
+
+	var list api.DeckListDto
+	recorder := sendAsOperator(router, http.MethodGet, "/api/v1/decks")
+	json.Unmarshal(recorder.Body.Bytes(), &list)
+
+	if recorder.Code != http.StatusOK || len(list.Decks) != 3 || list.NextCursor != "" {
+		t.Fatalf("There should be 3 decks in one page, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	first := list.Decks[0]
+	if len(first.Tags) != 2 || first.Tags[1] != "table-1" || first.CreatedAt.Before(before) {
+		t.Errorf("The decks should be listed with their metadata, got %+v", first)
+	}
+
+	tests := []struct {
+		query    string
+		expected int
+	}{
+		{"tag=poker", 2},
+		{"tag=poker&shuffled=false", 1},
+		{"shuffled=true", 1},
+		{"min_remaining=53", 1},
+		{"max_remaining=52", 2},
+		{"created_after=" + url.QueryEscape(before.Format(time.RFC3339Nano)), 3},
+		{"created_after=" + url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339)), 0},
+		{"owner=alice", 0},
+	}
+
+	for _, v := range tests {
+		var filtered api.DeckListDto
+		json.Unmarshal(sendAsOperator(router, http.MethodGet, "/api/v1/decks?"+v.query).Body.Bytes(), &filtered)
+		if len(filtered.Decks) != v.expected {
+			t.Errorf("There should be %d decks for %s, got %d", v.expected, v.query, len(filtered.Decks))
+		}
+	}
+
+	// Pages of 2 decks with the most cards left first
+	var page api.DeckListDto
+	json.Unmarshal(sendAsOperator(router, http.MethodGet, "/api/v1/decks?sort=-remaining&limit=2").Body.Bytes(), &page)
+
+	if len(page.Decks) != 2 || page.NextCursor == "" || page.Decks[0].Remaining != 2*data.MaxCards {
+		t.Fatalf("The first page should have 2 decks and a cursor, got %+v", page)
+	}
+
+	var last api.DeckListDto
+	next := "/api/v1/decks?sort=-remaining&limit=2&cursor=" + page.NextCursor
+	json.Unmarshal(sendAsOperator(router, http.MethodGet, next).Body.Bytes(), &last)
+
+	if len(last.Decks) != 1 || last.NextCursor != "" || last.Decks[0].Id == page.Decks[1].Id {
+		t.Errorf("The last page should have the other deck and no cursor, got %+v", last)
+	}
+}
+
+// Tests the invalid list parameters
+func TestListDecksProblems(t *testing.T) {
+
+	router := newOperatorRouter(t)
+
+	tests := []struct {
+		method string
+		path   string
+		code   string
+	}{
+		{http.MethodGet, "/api/v1/decks?shuffled=maybe", "invalid_parameter"},
+		{http.MethodGet, "/api/v1/decks?min_remaining=many", "invalid_parameter"},
+		{http.MethodGet, "/api/v1/decks?created_after=yesterday", "invalid_parameter"},
+		{http.MethodGet, "/api/v1/decks?limit=1000", "invalid_page_size"},
+		{http.MethodGet, "/api/v1/decks?sort=owner", "invalid_sort"},
+		{http.MethodGet, "/api/v1/decks?cursor=nope", "invalid_cursor"},
+		{http.MethodGet, "/api/v1/decks?tag=a,b", "invalid_tag"},
+		{http.MethodPost, "/api/v1/deck?tags=a,,b", "invalid_tag"},
+	}
+
+	for _, v := range tests {
+		expectProblem(t, sendAsOperator(router, v.method, v.path), http.StatusBadRequest, v.code)
+	}
+}
+
+// Tests that only operators list the decks, which don't show
+// their owner
+func TestListDecksOperators(t *testing.T) {
+
+	router := newOperatorRouter(t)
+	sendWithHeader(router, http.MethodPost, "/api/v1/deck", api.PlayerHeader, "dealer")
+
+	for _, header := range []string{"", "Bearer wrong", operatorToken, "Basic " + operatorToken} {
+		recorder := sendWithHeader(router, http.MethodGet, "/api/v1/decks", "Authorization", header)
+		expectProblem(t, recorder, http.StatusUnauthorized, "not_operator")
+		if !strings.HasPrefix(recorder.Header().Get("WWW-Authenticate"), "Bearer") {
+			t.Errorf("The response should ask for a bearer token, got %q", recorder.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	recorder := sendAsOperator(router, http.MethodGet, "/api/v1/decks")
+	if recorder.Code != http.StatusOK || strings.Contains(recorder.Body.String(), `"owner"`) {
+		t.Errorf("The decks should be listed without their owner, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// Nobody lists the decks if there is no token
+	recorder = sendWithHeader(newRouter(t), http.MethodGet, "/api/v1/decks", "Authorization", "Bearer ")
+	expectProblem(t, recorder, http.StatusUnauthorized, "not_operator")
+}
@@ -0,0 +1,105 @@
+// Author: Ferran Balaguer
+
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"test/cardsgame/api"
+	"test/cardsgame/routes"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Creates the service router with the memory storage
+func newRouter(t *testing.T) *gin.Engine {
+
+	gin.SetMode(gin.TestMode)
+
+	router, err := routes.InitialiseRoutes(routes.Config{Storage: routes.StorageMemory})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	return router
+}
+
+// Sends a request to the router and returns the response
+func send(router *gin.Engine, method string, path string) *httptest.ResponseRecorder {
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+
+	return recorder
+}
+
+// Decodes a problem response, checking its status and code
+func expectProblem(t *testing.T, recorder *httptest.ResponseRecorder, status int, code string) *api.ProblemDto {
+
+	if recorder.Code != status {
+		t.Errorf("The status should be %d, got %d", status, recorder.Code)
+	}
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != api.ProblemContentType {
+		t.Errorf("The content type should be %s, got %s", api.ProblemContentType, contentType)
+	}
+
+	var problem api.ProblemDto
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("The body should be a problem: %v", err)
+	}
+
+	if problem.Code != code || problem.Status != status || problem.Title == "" || problem.Type == "" {
+		t.Errorf("The problem should have code %s and status %d, got %+v", code, status, problem)
+	}
+
+	return &problem
+}
+
+// Tests the problem responses of invalid requests
+func TestProblems(t *testing.T) {
+
+	router := newRouter(t)
+
+	expectProblem(t, send(router, http.MethodGet, "/api/v1/deck/invalid"), http.StatusBadRequest, "invalid_deck_id")
+
+	missing := "/api/v1/deck/" + uuid.New().String()
+	if problem := expectProblem(t, send(router, http.MethodGet, missing), http.StatusNotFound, "deck_not_found"); problem.Instance != missing {
+		t.Errorf("The instance should be the path of the request, got %s", problem.Instance)
+	}
+
+	problem := expectProblem(t, send(router, http.MethodPost, "/api/v1/deck?cards=AS,XX"), http.StatusBadRequest, "invalid_card_code")
+	if len(problem.Cards) != 1 || problem.Cards[0] != "XX" {
+		t.Errorf("The problem should have the invalid code, got %v", problem.Cards)
+	}
+
+	problem = expectProblem(t, send(router, http.MethodPost, "/api/v1/deck?cards=XX,AS,AS,YY&allow_duplicates=false"), http.StatusBadRequest, "invalid_card_code")
+	if len(problem.Cards) != 2 || len(problem.Duplicates) != 1 || problem.Duplicates[0] != "AS" {
+		t.Errorf("The problem should have every invalid and duplicated code, got %v and %v", problem.Cards, problem.Duplicates)
+	}
+
+	expectProblem(t, send(router, http.MethodPost, "/api/v1/deck?cards=AS,AS&allow_duplicates=false"), http.StatusBadRequest, "duplicate_card_code")
+
+	problem = expectProblem(t, send(router, http.MethodPost, "/api/v1/deck?jokers=many"), http.StatusBadRequest, "invalid_parameter")
+	if problem.Parameter != "jokers" {
+		t.Errorf("The problem should have the invalid parameter, got %s", problem.Parameter)
+	}
+
+	created := send(router, http.MethodPost, "/api/v1/deck")
+	var deck api.DeckNoCardsDto
+	json.Unmarshal(created.Body.Bytes(), &deck)
+	path := "/api/v1/deck/" + deck.Id.String()
+
+	problem = expectProblem(t, send(router, http.MethodGet, path+"/cards?amount=60"), http.StatusBadRequest, "not_enough_cards")
+	if problem.Requested == nil || *problem.Requested != 60 || problem.Remaining == nil || *problem.Remaining != 52 {
+		t.Errorf("The problem should have the amount of cards requested and left, got %+v", problem)
+	}
+
+	expectProblem(t, send(router, http.MethodGet, path+"/cards?amount=0"), http.StatusBadRequest, "invalid_amount")
+
+	send(router, http.MethodPost, path+"/close")
+	expectProblem(t, send(router, http.MethodGet, path+"/cards"), http.StatusConflict, "deck_closed")
+}
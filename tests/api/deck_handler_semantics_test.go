@@ -0,0 +1,235 @@
+// Author: Ferran Balaguer
+
+package api_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"test/cardsgame/api"
+	"test/cardsgame/fairness"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Tests the Location of the new decks and the POST draw route
+func TestCreateDeckLocationAndDraw(t *testing.T) {
+
+	router := newRouter(t)
+
+	recorder := send(router, http.MethodPost, "/api/v1/deck?cards=AS,KH,QD")
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("The status should be %d, got %d", http.StatusCreated, recorder.Code)
+	}
+
+	var created api.DeckNoCardsDto
+	json.Unmarshal(recorder.Body.Bytes(), &created)
+
+	location := recorder.Header().Get("Location")
+	if location != "/api/v1/deck/"+created.Id.String() {
+		t.Errorf("The location should be the new deck, got %s", location)
+	}
+
+	if recorder := send(router, http.MethodGet, location); recorder.Code != http.StatusOK {
+		t.Errorf("The location should be opened, got %d", recorder.Code)
+	}
+
+	recorder = send(router, http.MethodPost, location+"/draw?amount=2")
+	var cards []api.CardDto
+	json.Unmarshal(recorder.Body.Bytes(), &cards)
+
+	if recorder.Code != http.StatusOK || len(cards) != 2 || cards[0].Code != "AS" {
+		t.Errorf("Should have drawn AS and KH, got %d %+v", recorder.Code, cards)
+	}
+
+	if recorder.Header().Get("Deprecation") != "" {
+		t.Errorf("The POST draw should not be deprecated")
+	}
+
+	// The GET draw still works, marked as deprecated
+	recorder = send(router, http.MethodGet, location+"/cards")
+	json.Unmarshal(recorder.Body.Bytes(), &cards)
+
+	if recorder.Code != http.StatusOK || len(cards) != 1 || cards[0].Code != "QD" {
+		t.Errorf("Should have drawn QD, got %d %+v", recorder.Code, cards)
+	}
+
+	if recorder.Header().Get("Deprecation") != "true" {
+		t.Errorf("The GET draw should be deprecated")
+	}
+
+	if link := recorder.Header().Get("Link"); !strings.Contains(link, "<"+location+"/draw>") {
+		t.Errorf("The GET draw should link to its successor, got %s", link)
+	}
+}
+
+// Tests the deletion of decks
+func TestDeleteDeck(t *testing.T) {
+
+	router := newRouter(t)
+
+	recorder := send(router, http.MethodPost, "/api/v1/deck")
+	location := recorder.Header().Get("Location")
+
+	recorder = send(router, http.MethodDelete, location)
+	if recorder.Code != http.StatusNoContent || recorder.Body.Len() != 0 {
+		t.Errorf("The status should be %d with no body, got %d", http.StatusNoContent, recorder.Code)
+	}
+
+	expectProblem(t, send(router, http.MethodGet, location), http.StatusNotFound, "deck_not_found")
+	expectProblem(t, send(router, http.MethodDelete, location), http.StatusNotFound, "deck_not_found")
+	expectProblem(t, send(router, http.MethodDelete, "/api/v1/deck/1234"), http.StatusBadRequest, "invalid_deck_id")
+
+	// Only the owner can delete an owned deck
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/deck", nil)
+	request.Header.Set(api.PlayerHeader, "dealer")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	location = recorder.Header().Get("Location")
+
+	expectProblem(t, send(router, http.MethodDelete, location), http.StatusForbidden, "not_deck_owner")
+
+	request = httptest.NewRequest(http.MethodDelete, location, nil)
+	request.Header.Set(api.PlayerHeader, "dealer")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("The status should be %d, got %d", http.StatusNoContent, recorder.Code)
+	}
+}
+
+// Tests the existence checks with HEAD, which have no body
+func TestHeadDeck(t *testing.T) {
+
+	server := httptest.NewServer(newRouter(t))
+	defer server.Close()
+
+	response, err := http.Post(server.URL+"/api/v1/deck", "", nil)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+	response.Body.Close()
+	location := response.Header.Get("Location")
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{location, http.StatusOK},
+		{"/api/v1/deck/" + uuid.New().String(), http.StatusNotFound},
+		{"/api/v1/deck/1234", http.StatusBadRequest},
+	}
+
+	for _, v := range tests {
+		response, err := http.Head(server.URL + v.path)
+		if err != nil {
+			t.Fatalf("There should be no error: %v", err)
+		}
+
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+
+		if response.StatusCode != v.status || len(body) != 0 {
+			t.Errorf("The status should be %d with no body, got %d", v.status, response.StatusCode)
+		}
+	}
+}
+
+// Tests that the decks return the amount of jokers they
+// were created with
+func TestDeckJokers(t *testing.T) {
+
+	router := newRouter(t)
+
+	var created api.DeckNoCardsDto
+	json.Unmarshal(send(router, http.MethodPost, "/api/v1/deck?jokers=2&decks=2").Body.Bytes(), &created)
+
+	if created.Jokers != 2 || created.Decks != 2 {
+		t.Errorf("The new deck should have 2 jokers and 2 decks, got %d and %d", created.Jokers, created.Decks)
+	}
+
+	var opened api.DeckDto
+	json.Unmarshal(send(router, http.MethodGet, "/api/v1/deck/"+created.Id.String()).Body.Bytes(), &opened)
+
+	if opened.Jokers != 2 {
+		t.Errorf("The deck should have 2 jokers, got %d", opened.Jokers)
+	}
+}
+
+// Tests that other players can't change an owned deck, and
+// that anybody can reveal it once it is closed
+func TestOwnerOnlyActions(t *testing.T) {
+
+	router := newRouter(t)
+
+	recorder := sendWithHeader(router, http.MethodPost, "/api/v1/deck?fair=true", api.PlayerHeader, "dealer")
+	location := recorder.Header().Get("Location")
+
+	paths := []string{
+		"/seed?client_seed=carol", "/draw", "/burn", "/shuffle", "/return", "/cut",
+		// A pile of their own would let them deal to it
+		"/piles?name=mine&owner=carol", "/piles/mine/draw", "/piles/mine/return",
+		"/close",
+	}
+
+	for _, path := range paths {
+		expectProblem(t, sendWithHeader(router, http.MethodPost, location+path, api.PlayerHeader, "carol"), http.StatusForbidden, "not_deck_owner")
+		expectProblem(t, send(router, http.MethodPost, location+path), http.StatusForbidden, "not_deck_owner")
+	}
+
+	for _, path := range []string{"/seed?client_seed=player", "/close"} {
+		if recorder = sendWithHeader(router, http.MethodPost, location+path, api.PlayerHeader, "dealer"); recorder.Code != http.StatusOK {
+			t.Fatalf("The owner should post %s, got %d", path, recorder.Code)
+		}
+	}
+
+	// As sent by the verifier
+	if recorder = send(router, http.MethodGet, location+"/reveal"); recorder.Code != http.StatusOK {
+		t.Errorf("Anybody should reveal the closed deck, got %d", recorder.Code)
+	}
+}
+
+// Tests that a fair deck publishes its server seed hash before
+// getting the client seed, and that its reveal is verified
+func TestFairDeckSeed(t *testing.T) {
+
+	router := newRouter(t)
+
+	expectProblem(t, send(router, http.MethodPost, "/api/v1/deck?fair=true&client_seed=player"), http.StatusBadRequest, "invalid_parameter")
+
+	recorder := send(router, http.MethodPost, "/api/v1/deck?fair=true&composition=euchre")
+	location := recorder.Header().Get("Location")
+
+	var created api.DeckNoCardsDto
+	json.Unmarshal(recorder.Body.Bytes(), &created)
+
+	if created.ServerSeedHash == "" || created.Commitment != "" || created.Shuffled {
+		t.Errorf("A new fair deck should only publish its server seed hash")
+	}
+
+	expectProblem(t, send(router, http.MethodPost, location+"/draw"), http.StatusConflict, "fair_deck_not_seeded")
+	expectProblem(t, send(router, http.MethodPost, location+"/seed"), http.StatusBadRequest, "invalid_client_seed")
+
+	recorder = send(router, http.MethodPost, location+"/seed?client_seed=player")
+	var seeded api.DeckNoCardsDto
+	json.Unmarshal(recorder.Body.Bytes(), &seeded)
+
+	if recorder.Code != http.StatusOK || seeded.Commitment == "" || seeded.ClientSeed != "player" || seeded.ServerSeedHash != created.ServerSeedHash {
+		t.Errorf("The seeded deck should publish its commitment, got %d", recorder.Code)
+	}
+
+	expectProblem(t, send(router, http.MethodPost, location+"/seed?client_seed=other"), http.StatusConflict, "fair_deck_seeded")
+
+	send(router, http.MethodPost, location+"/draw?amount=24")
+
+	var reveal fairness.Reveal
+	json.Unmarshal(send(router, http.MethodGet, location+"/reveal").Body.Bytes(), &reveal)
+
+	if err := fairness.Verify(reveal); err != nil || reveal.ServerSeedHash != created.ServerSeedHash {
+		t.Errorf("The reveal should be verified with the published hash: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Author: Ferran Balaguer
+
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"test/cardsgame/api"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sends a request with a header to the router
+func sendWithHeader(router *gin.Engine, method string, path string, name string, value string) *httptest.ResponseRecorder {
+
+	request := httptest.NewRequest(method, path, nil)
+	request.Header.Set(name, value)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	return recorder
+}
+
+// Tests the versions and entity tags of the decks
+func TestDeckVersions(t *testing.T) {
+
+	router := newRouter(t)
+
+	created := send(router, http.MethodPost, "/api/v1/deck")
+	location := created.Header().Get("Location")
+
+	if etag := created.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf(`The ETag should be "1", got %s`, etag)
+	}
+
+	send(router, http.MethodPost, location+"/draw?amount=2")
+	opened := send(router, http.MethodGet, location)
+
+	var deck api.DeckDto
+	json.Unmarshal(opened.Body.Bytes(), &deck)
+
+	if deck.Version != 2 || opened.Header().Get("ETag") != `"2"` {
+		t.Errorf("Should have returned version 2, got %d and %s", deck.Version, opened.Header().Get("ETag"))
+	}
+
+	// Reading doesn't change the version
+	send(router, http.MethodGet, location+"/peek")
+	if etag := send(router, http.MethodGet, location).Header().Get("ETag"); etag != `"2"` {
+		t.Errorf(`The ETag should still be "2", got %s`, etag)
+	}
+}
+
+// Tests that reads with If-None-Match get 304 while the deck
+// doesn't change
+func TestIfNoneMatch(t *testing.T) {
+
+	router := newRouter(t)
+
+	location := send(router, http.MethodPost, "/api/v1/deck").Header().Get("Location")
+
+	for _, header := range []string{`"1"`, `W/"1"`, `"5", "1"`, `*`} {
+		recorder := sendWithHeader(router, http.MethodGet, location, "If-None-Match", header)
+		if recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+			t.Errorf("Should have returned %d for %s, got %d", http.StatusNotModified, header, recorder.Code)
+		}
+		if recorder.Header().Get("ETag") != `"1"` {
+			t.Errorf("The ETag should be sent with 304")
+		}
+	}
+
+	send(router, http.MethodPost, location+"/draw")
+
+	recorder := sendWithHeader(router, http.MethodGet, location, "If-None-Match", `"1"`)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Should have returned %d, got %d", http.StatusOK, recorder.Code)
+	}
+}
+
+// Tests that changes with If-Match only apply to the version
+// of the header
+func TestIfMatch(t *testing.T) {
+
+	router := newRouter(t)
+
+	location := send(router, http.MethodPost, "/api/v1/deck").Header().Get("Location")
+
+	drawn := sendWithHeader(router, http.MethodPost, location+"/draw?amount=2", "If-Match", `"1"`)
+	if drawn.Code != http.StatusOK || drawn.Header().Get("ETag") != `"2"` {
+		t.Errorf(`Should have returned %d with ETag "2", got %d and %s`, http.StatusOK, drawn.Code, drawn.Header().Get("ETag"))
+	}
+
+	// The deck is already at version 2
+	stale := sendWithHeader(router, http.MethodPost, location+"/draw?amount=2", "If-Match", `"1"`)
+	expectProblem(t, stale, http.StatusPreconditionFailed, "version_mismatch")
+
+	var deck api.DeckDto
+	json.Unmarshal(send(router, http.MethodGet, location).Body.Bytes(), &deck)
+	if deck.Remaining != 50 || deck.Version != 2 {
+		t.Errorf("The stale draw should be discarded, got %d remaining at version %d", deck.Remaining, deck.Version)
+	}
+
+	// Any version
+	recorder := sendWithHeader(router, http.MethodPost, location+"/shuffle", "If-Match", "*")
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Should have returned %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	// Lists match if any of their strong tags does
+	recorder = sendWithHeader(router, http.MethodPost, location+"/shuffle", "If-Match", `"9", "3"`)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("ETag") != `"4"` {
+		t.Errorf(`Should have returned %d with ETag "4", got %d and %s`, http.StatusOK, recorder.Code, recorder.Header().Get("ETag"))
+	}
+
+	// Weak tags never match with the strong comparison
+	for _, header := range []string{`W/"4"`, `"three"`, `"1", W/"4"`} {
+		recorder = sendWithHeader(router, http.MethodPost, location+"/shuffle", "If-Match", header)
+		expectProblem(t, recorder, http.StatusPreconditionFailed, "version_mismatch")
+	}
+
+	for _, header := range []string{`4`, `"4", 5`} {
+		recorder = sendWithHeader(router, http.MethodPost, location+"/shuffle", "If-Match", header)
+		expectProblem(t, recorder, http.StatusBadRequest, "invalid_parameter")
+	}
+}
+
+// Tests that the failed changes with If-Match keep the version
+func TestIfMatchFailure(t *testing.T) {
+
+	router := newRouter(t)
+
+	location := send(router, http.MethodPost, "/api/v1/deck").Header().Get("Location")
+
+	recorder := sendWithHeader(router, http.MethodPost, location+"/draw?amount=53", "If-Match", `"1"`)
+	expectProblem(t, recorder, http.StatusBadRequest, "not_enough_cards")
+
+	if etag := send(router, http.MethodGet, location).Header().Get("ETag"); etag != `"1"` {
+		t.Errorf(`The ETag should still be "1", got %s`, etag)
+	}
+
+	recorder = sendWithHeader(router, http.MethodPost, "/api/v1/deck/not-a-uuid/draw", "If-Match", `"1"`)
+	expectProblem(t, recorder, http.StatusBadRequest, "invalid_deck_id")
+}
+
+// Tests deleting a deck with If-Match
+func TestIfMatchDelete(t *testing.T) {
+
+	router := newRouter(t)
+
+	location := send(router, http.MethodPost, "/api/v1/deck").Header().Get("Location")
+
+	recorder := sendWithHeader(router, http.MethodDelete, location, "If-Match", `"2"`)
+	expectProblem(t, recorder, http.StatusPreconditionFailed, "version_mismatch")
+
+	recorder = sendWithHeader(router, http.MethodDelete, location, "If-Match", `"1"`)
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("Should have returned %d, got %d", http.StatusNoContent, recorder.Code)
+	}
+
+	recorder = send(router, http.MethodGet, location)
+	expectProblem(t, recorder, http.StatusNotFound, "deck_not_found")
+}
@@ -0,0 +1,128 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Tests that every composition creates a deck with
+// the expected amount of cards
+func TestCreateDeckCompositionSizes(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	sizes := map[string]int{
+		"":                              data.MaxCards,
+		controllers.CompositionStandard: data.MaxCards,
+		controllers.CompositionPiquet:   32,
+		controllers.CompositionEuchre:   24,
+		controllers.CompositionPinochle: 48,
+	}
+
+	for name, size := range sizes {
+		deck, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Composition: name, Shuffle: true})
+
+		if err != nil {
+			t.Fatalf("There should be no error: %v", err)
+		}
+
+		if len(deck.Cards) != size || deck.Remaining != size {
+			t.Errorf("Composition %q should have %d cards, got %d", name, size, len(deck.Cards))
+		}
+	}
+}
+
+// Tests that stripped compositions only contain their values
+// and pinochle contains every card twice
+func TestCreateDeckPinochle(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	deck, _ := controller.CreateDeckWithOptions(controllers.DeckOptions{Composition: controllers.CompositionPinochle})
+
+	count := map[string]int{}
+	for _, v := range deck.Cards {
+		if v.Value > data.Ace && v.Value < data.Nine {
+			t.Errorf("Card %v should not be in a pinochle deck", v.Code)
+		}
+		count[v.Code]++
+	}
+
+	for code, n := range count {
+		if n != 2 {
+			t.Errorf("Card %v should appear twice, got %d", code, n)
+		}
+	}
+
+	if deck.Composition != controllers.CompositionPinochle {
+		t.Errorf("Deck composition should be %v", controllers.CompositionPinochle)
+	}
+}
+
+// Tests that jokers are added at the end of the deck
+func TestCreateDeckWithJokers(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	deck, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Jokers: 2})
+
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if deck.Remaining != data.MaxCards+2 {
+		t.Errorf("Remaining cards must be = %d", data.MaxCards+2)
+	}
+
+	if deck.Cards[data.MaxCards].Code != "XR" || deck.Cards[data.MaxCards+1].Code != "XB" {
+		t.Errorf("The last cards should be the red and black jokers")
+	}
+}
+
+// Tests that jokers can only be requested in a partial deck
+// when the composition includes them
+func TestCreateDeckPartialJokers(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	codes := []string{"AS", "XB"}
+
+	_, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Codes: codes, Jokers: 1})
+	if !errors.Is(err, controllers.ErrInvalidCardCode) {
+		t.Errorf("Should have returned %v", controllers.ErrInvalidCardCode)
+	}
+
+	deck, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Codes: codes, Jokers: 2})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if deck.Cards[1].Value != data.Joker || deck.Cards[1].Suit != data.Black {
+		t.Errorf("Second card should be the black joker")
+	}
+}
+
+// Tests that invalid options are rejected
+func TestCreateDeckInvalidOptions(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	_, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Composition: "tarot"})
+	if !errors.Is(err, controllers.ErrInvalidComposition) {
+		t.Errorf("Should have returned %v", controllers.ErrInvalidComposition)
+	}
+
+	_, err = controller.CreateDeckWithOptions(controllers.DeckOptions{Jokers: controllers.MaxJokers + 1})
+	if !errors.Is(err, controllers.ErrInvalidJokers) {
+		t.Errorf("Should have returned %v", controllers.ErrInvalidJokers)
+	}
+}
@@ -0,0 +1,75 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"sync"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Hammers DrawCards from many goroutines at the same time and
+// checks that every card of the shoe is dealt exactly once.
+// Run it with "go test -race" to detect unsafe accesses
+func TestDrawCardsConcurrently(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	deck, _ := controller.CreateDeckWithOptions(controllers.DeckOptions{Shuffle: true, Decks: controllers.MaxDecks})
+	total := deck.Remaining
+
+	players := 32
+	dealt := make([][]data.Card, players)
+
+	var wg sync.WaitGroup
+	for p := 0; p < players; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+
+			// Other decks are created meanwhile so that
+			// the repository map is also modified
+			controller.CreateDeck(false, nil)
+
+			for {
+				cards, err := controller.DrawCards(deck.Id, 1+p%3)
+				if errors.Is(err, controllers.ErrNotEnoughCards) {
+					// Draw the last cards one by one
+					if cards, err = controller.DrawCards(deck.Id, 1); err != nil {
+						return
+					}
+				} else if err != nil {
+					t.Errorf("There should be no error: %v", err)
+					return
+				}
+				dealt[p] = append(dealt[p], cards...)
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	instances := map[int]int{}
+	for _, cards := range dealt {
+		for _, v := range cards {
+			instances[v.Instance]++
+		}
+	}
+
+	if len(instances) != total {
+		t.Errorf("%d cards should have been dealt, got %d", total, len(instances))
+	}
+
+	for instance, n := range instances {
+		if n != 1 {
+			t.Errorf("Card instance %d was dealt %d times", instance, n)
+		}
+	}
+
+	opened, _ := controller.OpenDeck(deck.Id)
+	if opened.Remaining != 0 || len(opened.Cards) != 0 {
+		t.Errorf("The deck should be empty")
+	}
+}
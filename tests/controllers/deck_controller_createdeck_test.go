@@ -18,10 +18,11 @@ func TestDefaultCardSet(t *testing.T) {
 
 	defaultCards := controller.GetDefaultCardSet()
 
-	if defaultCards[0].Code != "SA" ||
-		defaultCards[13].Code != "DA" ||
-		defaultCards[26].Code != "CA" ||
-		defaultCards[39].Code != "HA" {
+	if defaultCards[0].Code != "AS" ||
+		defaultCards[9].Code != "TS" ||
+		defaultCards[13].Code != "AD" ||
+		defaultCards[26].Code != "AC" ||
+		defaultCards[39].Code != "AH" {
 		t.Errorf("Bad Default Card Set order")
 	}
 
@@ -130,7 +131,7 @@ func TestCreateDeckPartilaWrongCode(t *testing.T) {
 	controller := controllers.NewDeckController(repository)
 
 	// XX is an invalid code for a card
-	codes := []string{"SA", "XX"}
+	codes := []string{"AS", "XX"}
 	_, err := controller.CreateDeck(false, codes)
 
 	if err == nil {
@@ -146,7 +147,7 @@ func TestGetCardSetByCodesErr(t *testing.T) {
 	controller := controllers.NewDeckController(repository)
 
 	// The second one is wrong
-	_, err := controller.GetCardSetByCodes([]string{"SA", "YY"})
+	_, err := controller.GetCardSetByCodes([]string{"AS", "YY"})
 
 	if err == nil || !errors.Is(err, controllers.ErrInvalidCardCode) {
 		t.Errorf("Should have returned %v", controllers.ErrInvalidCardCode)
@@ -160,8 +161,7 @@ func TestGetCardSetByCodesOK(t *testing.T) {
 	repository := &data.MemoryDeckRepository{}
 	controller := controllers.NewDeckController(repository)
 
-	// The second one is wrong
-	cards, err := controller.GetCardSetByCodes([]string{"SK", "H8"})
+	cards, err := controller.GetCardSetByCodes([]string{"KS", "8H"})
 
 	if err != nil {
 		t.Errorf("There should be no error: %v", err)
@@ -171,12 +171,40 @@ func TestGetCardSetByCodesOK(t *testing.T) {
 		t.Errorf("Cards set length should be 2")
 	}
 
-	if cards[0].Code != "SK" {
-		t.Errorf("First card should be SK")
+	if cards[0].Code != "KS" {
+		t.Errorf("First card should be KS")
 	}
 
-	if cards[1].Code != "H8" {
-		t.Errorf("Second card should be H8")
+	if cards[1].Code != "8H" {
+		t.Errorf("Second card should be 8H")
+	}
+}
+
+// Tests that every accepted alias for a card is
+// resolved to the card with its canonical code
+func TestGetCardSetByCodesAliases(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	// Ten aliases and legacy suit first codes
+	codes := []string{"TS", "0S", "10s", "S10", "S1", "SK", "h8"}
+	expected := []string{"TS", "TS", "TS", "TS", "TS", "KS", "8H"}
+
+	cards, err := controller.GetCardSetByCodes(codes)
+
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	for i, v := range cards {
+		if v.Code != expected[i] {
+			t.Errorf("Code %v should be resolved as %v, got %v", codes[i], expected[i], v.Code)
+		}
+	}
+
+	if cards[0].Value != data.Ten {
+		t.Errorf("TS should be a Ten")
 	}
 }
 
@@ -187,8 +215,7 @@ func TestCrateDeckPartialOk(t *testing.T) {
 	repository := &data.MemoryDeckRepository{}
 	controller := controllers.NewDeckController(repository)
 
-	// XX is an invalid code for a card
-	codes := []string{"SQ", "D5"}
+	codes := []string{"QS", "5D"}
 	deck, err := controller.CreateDeck(true, codes)
 
 	if err != nil {
@@ -215,3 +242,60 @@ func TestCrateDeckPartialOk(t *testing.T) {
 		t.Errorf("Second card should be %v", codes[1])
 	}
 }
+
+// Tests that every invalid code is reported, not only the first
+func TestGetCardSetByCodesAllInvalid(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	_, err := controller.GetCardSetByCodes([]string{"YY", "AS", "1X", "KH", "YY"})
+
+	var codeErr *controllers.CardCodeError
+	if !errors.As(err, &codeErr) || !errors.Is(err, controllers.ErrInvalidCardCode) {
+		t.Fatalf("Should have returned %v with the codes, got %v", controllers.ErrInvalidCardCode, err)
+	}
+
+	expected := []string{"YY", "1X", "YY"}
+	if len(codeErr.Codes) != len(expected) {
+		t.Fatalf("The error should have the codes %v, got %v", expected, codeErr.Codes)
+	}
+
+	for i, v := range expected {
+		if codeErr.Codes[i] != v {
+			t.Errorf("Invalid code %d should be %s, got %s", i, v, codeErr.Codes[i])
+		}
+	}
+}
+
+// Tests that duplicated codes are allowed by default and
+// reported when they are rejected
+func TestCreateDeckDuplicates(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	codes := []string{"TS", "KH", "0S", "KH", "QD"}
+
+	if deck, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Codes: codes}); err != nil || deck.Remaining != 5 {
+		t.Errorf("Duplicated codes should be allowed by default: %v", err)
+	}
+
+	_, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Codes: codes, RejectDuplicates: true})
+
+	var codeErr *controllers.CardCodeError
+	if !errors.As(err, &codeErr) || !errors.Is(err, controllers.ErrDuplicateCardCode) {
+		t.Fatalf("Should have returned %v, got %v", controllers.ErrDuplicateCardCode, err)
+	}
+
+	// 0S is an alias of TS
+	if len(codeErr.Duplicates) != 2 || codeErr.Duplicates[0] != "0S" || codeErr.Duplicates[1] != "KH" {
+		t.Errorf("The error should have the duplicated codes, got %v", codeErr.Duplicates)
+	}
+
+	// Invalid codes are reported together with the duplicates
+	_, err = controller.CreateDeckWithOptions(controllers.DeckOptions{Codes: append(codes, "ZZ"), RejectDuplicates: true})
+	if !errors.As(err, &codeErr) || !errors.Is(err, controllers.ErrInvalidCardCode) || len(codeErr.Codes) != 1 || len(codeErr.Duplicates) != 2 {
+		t.Errorf("The error should have the invalid and duplicated codes, got %v", err)
+	}
+}
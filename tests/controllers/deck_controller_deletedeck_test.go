@@ -0,0 +1,52 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Deletes a deck and checks that it can't be opened again
+func TestDeleteDeck(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+	deck, _ := controller.CreateDeck(true, nil)
+
+	if err := controller.DeleteDeck(deck.Id, ""); err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if _, err := controller.OpenDeck(deck.Id); !errors.Is(err, controllers.ErrDeckNotFound) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckNotFound, err)
+	}
+
+	if err := controller.DeleteDeck(deck.Id, ""); !errors.Is(err, controllers.ErrDeckNotFound) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckNotFound, err)
+	}
+
+	if err := controller.DeleteDeck(uuid.New(), ""); !errors.Is(err, controllers.ErrDeckNotFound) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckNotFound, err)
+	}
+}
+
+// Only the owner of a deck can delete it
+func TestDeleteDeckOwner(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+	deck := newTableDeck(t, controller)
+
+	for _, viewer := range []string{"alice", ""} {
+		if err := controller.DeleteDeck(deck.Id, viewer); !errors.Is(err, controllers.ErrNotDeckOwner) {
+			t.Errorf("Should have returned %v, got %v", controllers.ErrNotDeckOwner, err)
+		}
+	}
+
+	if err := controller.DeleteDeck(deck.Id, "dealer"); err != nil {
+		t.Errorf("There should be no error: %v", err)
+	}
+}
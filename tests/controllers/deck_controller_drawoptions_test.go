@@ -0,0 +1,116 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Tests drawing cards from the bottom of the deck
+func TestDrawCardsFromBottom(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(false, nil)
+
+	cards, err := controller.DrawCardsWithOptions(deck.Id, controllers.DrawOptions{Amount: 2, From: "bottom"})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if cards[0].Code != "KH" || cards[1].Code != "QH" {
+		t.Errorf("The bottom cards should have been drawn, got %s and %s", cards[0].Code, cards[1].Code)
+	}
+
+	opened, _ := controller.OpenDeck(deck.Id)
+	if opened.Remaining != data.MaxCards-2 || opened.Cards[opened.Remaining-1].Code != "JH" {
+		t.Errorf("The deck should have the cards left")
+	}
+}
+
+// Tests drawing cards at random positions
+func TestDrawCardsFromRandom(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(false, nil)
+
+	cards, err := controller.DrawCardsWithOptions(deck.Id, controllers.DrawOptions{Amount: 10, From: "random"})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	opened, _ := controller.OpenDeck(deck.Id)
+	left := map[int]bool{}
+	for _, v := range opened.Cards {
+		left[v.Instance] = true
+	}
+
+	for _, v := range cards {
+		if left[v.Instance] {
+			t.Errorf("Card %s should have been drawn", v.Code)
+		}
+	}
+
+	if len(left) != data.MaxCards-10 {
+		t.Errorf("The deck should have %d cards left, got %d", data.MaxCards-10, len(left))
+	}
+}
+
+// Tests drawing cards by code, in any notation
+func TestDrawCardsByCode(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(true, nil)
+
+	cards, err := controller.DrawCardsWithOptions(deck.Id, controllers.DrawOptions{Codes: []string{"KH", "s10"}})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if len(cards) != 2 || cards[0].Code != "KH" || cards[1].Code != "TS" {
+		t.Errorf("KH and TS should have been drawn")
+	}
+
+	opened, _ := controller.OpenDeck(deck.Id)
+	if opened.Remaining != data.MaxCards-2 {
+		t.Errorf("Remaining cards must be = %d", data.MaxCards-2)
+	}
+}
+
+// Tests that drawing by code is atomic and that invalid
+// options return errors
+func TestDrawCardsOptionsErrors(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(false, nil)
+
+	errorTests := []struct {
+		options controllers.DrawOptions
+		err     error
+	}{
+		{controllers.DrawOptions{Codes: []string{"AS", "KH", "AS"}}, controllers.ErrCardNotFound},
+		{controllers.DrawOptions{Codes: []string{"AS", "ZZ"}}, controllers.ErrInvalidCardCode},
+		{controllers.DrawOptions{Codes: []string{"AS"}, From: "bottom"}, controllers.ErrInvalidPosition},
+		{controllers.DrawOptions{Codes: []string{"AS"}, Amount: 2}, controllers.ErrInvalidAmount},
+		{controllers.DrawOptions{Amount: 1, From: "middle"}, controllers.ErrInvalidPosition},
+		{controllers.DrawOptions{Amount: 0, From: "random"}, controllers.ErrInvalidAmount},
+		{controllers.DrawOptions{Amount: data.MaxCards + 1, From: "bottom"}, controllers.ErrNotEnoughCards},
+	}
+
+	for _, test := range errorTests {
+		if _, err := controller.DrawCardsWithOptions(deck.Id, test.options); !errors.Is(err, test.err) {
+			t.Errorf("Options %+v should return %v, got %v", test.options, test.err, err)
+		}
+	}
+
+	opened, _ := controller.OpenDeck(deck.Id)
+	if opened.Remaining != data.MaxCards {
+		t.Errorf("Failed draws should not modify the deck")
+	}
+}
@@ -0,0 +1,92 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"fmt"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Memory repository whose draws fail with err, and whose
+// reads fail with openErr if it is not nil
+type failingRepository struct {
+	*data.MemoryDeckRepository
+	err     error
+	openErr error
+}
+
+func (r *failingRepository) GetDeckById(uuid uuid.UUID) (*data.Deck, error) {
+
+	if r.openErr != nil {
+		return nil, r.openErr
+	}
+
+	return r.MemoryDeckRepository.GetDeckById(uuid)
+}
+
+func (r *failingRepository) DrawCardsAt(uuid.UUID, int, data.Position, func(int) int) ([]data.Card, error) {
+	return nil, r.err
+}
+
+func (r *failingRepository) CloseDeck(uuid.UUID) (*data.Deck, error) {
+	return nil, r.err
+}
+
+// Error of a storage backend
+var errDisk = errors.New("disk failure")
+
+// Tests the controller error of every repository error
+func TestRepositoryErrors(t *testing.T) {
+
+	expected := map[error]error{
+		data.ErrNotFound:          controllers.ErrDeckNotFound,
+		data.ErrClosed:            controllers.ErrDeckClosed,
+		data.ErrTruncate:          controllers.ErrNotEnoughCards,
+		data.ErrInvalidParameters: controllers.ErrInvalidAmount,
+		data.ErrCardNotFound:      controllers.ErrCardNotFound,
+		data.ErrNotDrawn:          controllers.ErrCardNotDrawn,
+		data.ErrPileNotFound:      controllers.ErrPileNotFound,
+		data.ErrPileExists:        controllers.ErrPileExists,
+		errDisk:                   controllers.ErrGeneral,
+	}
+
+	for dataErr, controllerErr := range expected {
+		for _, err := range []error{dataErr, fmt.Errorf("loading deck: %w", dataErr)} {
+			repository := &failingRepository{MemoryDeckRepository: &data.MemoryDeckRepository{}, err: err}
+			controller := controllers.NewDeckController(repository)
+			deck, _ := controller.CreateDeck(false, nil)
+
+			if _, got := controller.DrawCards(deck.Id, 1); !errors.Is(got, controllerErr) {
+				t.Errorf("Drawing with %v should return %v, got %v", err, controllerErr, got)
+			}
+		}
+	}
+}
+
+// Tests that unknown repository errors are internal errors that
+// keep their cause, instead of being reported as something else
+func TestInternalErrors(t *testing.T) {
+
+	repository := &failingRepository{MemoryDeckRepository: &data.MemoryDeckRepository{}, err: errDisk}
+	controller := controllers.NewDeckController(repository)
+	deck, _ := controller.CreateDeck(false, nil)
+
+	if _, err := controller.CloseDeck(deck.Id, ""); !errors.Is(err, controllers.ErrGeneral) || !errors.Is(err, errDisk) {
+		t.Errorf("Closing should return %v wrapping %v, got %v", controllers.ErrGeneral, errDisk, err)
+	}
+
+	repository.openErr = fmt.Errorf("loading deck: %w", errDisk)
+	if _, err := controller.OpenDeck(deck.Id); !errors.Is(err, controllers.ErrGeneral) || errors.Is(err, controllers.ErrDeckNotFound) {
+		t.Errorf("Opening should return %v instead of %v, got %v", controllers.ErrGeneral, controllers.ErrDeckNotFound, err)
+	}
+
+	repository.openErr = data.ErrNotFound
+	if _, err := controller.OpenDeck(deck.Id); !errors.Is(err, controllers.ErrDeckNotFound) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckNotFound, err)
+	}
+}
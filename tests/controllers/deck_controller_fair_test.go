@@ -0,0 +1,221 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"test/cardsgame/fairness"
+	"testing"
+)
+
+// Creates a fair deck with the options and seeds it with
+// the client seed "player"
+func newFairDeck(t *testing.T, controller *controllers.DeckController, options controllers.DeckOptions) *data.Deck {
+
+	options.Fair = true
+
+	deck, err := controller.CreateDeckWithOptions(options)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	deck, err = controller.SeedDeck(deck.Id, "player", options.Owner)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	return deck
+}
+
+// Returns the codes of the cards
+func cardCodes(cards []data.Card) []string {
+
+	codes := make([]string, len(cards))
+	for i, v := range cards {
+		codes[i] = v.Code
+	}
+
+	return codes
+}
+
+// Returns true if both lists have the same codes in the same order
+func sameCodes(a []string, b []string) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Tests that a fair deck publishes the hash of its server seed,
+// and the commitment of its order once it gets the client seed
+func TestCreateFairDeck(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Fair: true})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	initial, _ := fairness.InitialCodes(controllers.CompositionStandard, 0, 1)
+	if !deck.Fair || deck.Shuffled || deck.ServerSeed == "" || deck.Commitment != "" || !sameCodes(cardCodes(deck.Cards), initial) {
+		t.Errorf("Deck should be fair and wait for the client seed in the initial order")
+	}
+
+	if _, err := controller.DrawCards(deck.Id, 1); !errors.Is(err, controllers.ErrFairDeckNotSeeded) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrFairDeckNotSeeded, err)
+	}
+
+	seeded, err := controller.SeedDeck(deck.Id, "player", "")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if !seeded.Shuffled || seeded.ClientSeed != "player" || seeded.ServerSeed != deck.ServerSeed {
+		t.Errorf("Deck should be shuffled with the client seed")
+	}
+
+	if !sameCodes(cardCodes(seeded.Cards), fairness.ShuffleCodes(initial, deck.ServerSeed, "player")) {
+		t.Errorf("Deck should be in the order of the seeds")
+	}
+
+	if seeded.Commitment != fairness.Commitment(deck.ServerSeed, cardCodes(seeded.Cards)) {
+		t.Errorf("The commitment should match the server seed and the deck order")
+	}
+
+	if _, err := controller.SeedDeck(deck.Id, "other", ""); !errors.Is(err, controllers.ErrFairDeckSeeded) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrFairDeckSeeded, err)
+	}
+}
+
+// Tests that the controller builds the initial order of fair
+// decks like the verifier
+func TestFairInitialOrder(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	names := []string{
+		controllers.CompositionStandard, controllers.CompositionPiquet,
+		controllers.CompositionEuchre, controllers.CompositionPinochle,
+	}
+
+	for _, name := range names {
+		composition, _ := controllers.GetComposition(name)
+		initial, _ := fairness.InitialCodes(name, 2, 2)
+		if !sameCodes(cardCodes(controller.GetShoeCardSet(composition, 2, 2)), initial) {
+			t.Errorf("The %s shoe should be in the initial order of the verifier", name)
+		}
+	}
+}
+
+// Tests that the server seed is only revealed when the deck
+// is closed, and that the reveal can be verified
+func TestRevealFairDeck(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck := newFairDeck(t, controller, controllers.DeckOptions{Decks: 2, Jokers: 1})
+	drawn, _ := controller.DrawCards(deck.Id, 10)
+
+	if _, err := controller.RevealDeck(deck.Id); !errors.Is(err, controllers.ErrDeckNotFinished) {
+		t.Fatalf("Should have returned %v", controllers.ErrDeckNotFinished)
+	}
+
+	controller.CloseDeck(deck.Id, "")
+
+	if _, err := controller.DrawCards(deck.Id, 1); !errors.Is(err, controllers.ErrDeckClosed) {
+		t.Errorf("Should have returned %v", controllers.ErrDeckClosed)
+	}
+
+	reveal, err := controller.RevealDeck(deck.Id)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if err := fairness.Verify(*reveal); err != nil {
+		t.Errorf("The reveal should be verified: %v", err)
+	}
+
+	for i, v := range drawn {
+		if reveal.Cards[i] != v.Code {
+			t.Errorf("Card %d should be %v, got %v", i, v.Code, reveal.Cards[i])
+		}
+	}
+}
+
+// Tests that an exhausted fair deck can be revealed
+// without closing it
+func TestRevealExhaustedFairDeck(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck := newFairDeck(t, controller, controllers.DeckOptions{Composition: controllers.CompositionEuchre})
+	controller.DrawCards(deck.Id, deck.Remaining)
+
+	if _, err := controller.RevealDeck(deck.Id); err != nil {
+		t.Errorf("There should be no error: %v", err)
+	}
+}
+
+// Tests the errors of fair decks
+func TestFairDeckErrors(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	_, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Fair: true, Codes: []string{"AS"}})
+	if !errors.Is(err, controllers.ErrFairPartialDeck) {
+		t.Errorf("Should have returned %v", controllers.ErrFairPartialDeck)
+	}
+
+	deck, _ := controller.CreateDeck(true, nil)
+	controller.CloseDeck(deck.Id, "")
+
+	if _, err := controller.RevealDeck(deck.Id); !errors.Is(err, controllers.ErrDeckNotFair) {
+		t.Errorf("Should have returned %v", controllers.ErrDeckNotFair)
+	}
+
+	if _, err := controller.SeedDeck(deck.Id, "player", ""); !errors.Is(err, controllers.ErrDeckNotFair) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckNotFair, err)
+	}
+
+	fair, _ := controller.CreateDeckWithOptions(controllers.DeckOptions{Fair: true, Owner: "dealer"})
+
+	seeds := []struct {
+		seed     string
+		viewer   string
+		expected error
+	}{
+		{"", "dealer", controllers.ErrInvalidClientSeed},
+		{"player", "alice", controllers.ErrNotDeckOwner},
+	}
+
+	for _, v := range seeds {
+		if _, err := controller.SeedDeck(fair.Id, v.seed, v.viewer); !errors.Is(err, v.expected) {
+			t.Errorf("Should have returned %v, got %v", v.expected, err)
+		}
+	}
+
+	if _, err := controller.DrawCardsToPile(fair.Id, "north", 1, "", "dealer"); !errors.Is(err, controllers.ErrFairDeckNotSeeded) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrFairDeckNotSeeded, err)
+	}
+
+	controller.CloseDeck(fair.Id, "dealer")
+
+	if _, err := controller.RevealDeck(fair.Id); !errors.Is(err, controllers.ErrFairDeckNotSeeded) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrFairDeckNotSeeded, err)
+	}
+
+	if _, err := controller.SeedDeck(fair.Id, "player", "dealer"); !errors.Is(err, controllers.ErrDeckClosed) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckClosed, err)
+	}
+}
@@ -0,0 +1,155 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+	"time"
+)
+
+// Tests that stored responses are returned for the same request
+// and rejected for a different one
+func TestIdempotentRequests(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+	deck, _ := controller.CreateDeck(true, nil)
+
+	response, err := controller.BeginRequest("key-1", "draw 1")
+	if response != nil || err != nil {
+		t.Fatalf("The first request should be run, got %v %v", response, err)
+	}
+
+	// A retry while the request is in progress
+	if _, err := controller.BeginRequest("key-1", "draw 1"); !errors.Is(err, controllers.ErrIdempotencyInUse) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrIdempotencyInUse, err)
+	}
+
+	err = controller.SaveResponse(deck.Id, data.StoredResponse{Key: "key-1", Fingerprint: "draw 1", Status: 200, Body: []byte("[]")})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+	controller.EndRequest("key-1")
+
+	response, err = controller.BeginRequest("key-1", "draw 1")
+	if err != nil || response == nil || response.Status != 200 || string(response.Body) != "[]" {
+		t.Errorf("The stored response should be returned, got %+v %v", response, err)
+	}
+
+	// Replayed requests don't reserve the key
+	if _, err := controller.BeginRequest("key-1", "draw 2"); !errors.Is(err, controllers.ErrIdempotencyReused) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrIdempotencyReused, err)
+	}
+
+	for _, key := range []string{"", "two words", strings.Repeat("k", 256)} {
+		if _, err := controller.BeginRequest(key, "draw 1"); !errors.Is(err, controllers.ErrInvalidIdempotency) {
+			t.Errorf("Should have returned %v, got %v", controllers.ErrInvalidIdempotency, err)
+		}
+	}
+}
+
+// Tests that stored responses expire after the window
+func TestIdempotentRequestsExpire(t *testing.T) {
+
+	now := time.Now()
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+	controller.SetClock(func() time.Time { return now })
+	deck, _ := controller.CreateDeck(true, nil)
+
+	controller.BeginRequest("key-1", "draw 1")
+	controller.SaveResponse(deck.Id, data.StoredResponse{Key: "key-1", Fingerprint: "draw 1", Status: 200})
+	controller.EndRequest("key-1")
+
+	now = now.Add(controllers.IdempotencyWindow - time.Second)
+	if _, err := controller.BeginRequest("key-1", "draw 2"); !errors.Is(err, controllers.ErrIdempotencyReused) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrIdempotencyReused, err)
+	}
+
+	now = now.Add(time.Second)
+	response, err := controller.BeginRequest("key-1", "draw 2")
+	if response != nil || err != nil {
+		t.Errorf("The expired response should be ignored, got %+v %v", response, err)
+	}
+	controller.EndRequest("key-1")
+
+	// Storing a response for a missing deck
+	controller.DeleteDeck(deck.Id, "")
+	err = controller.SaveResponse(deck.Id, data.StoredResponse{Key: "key-2"})
+	if !errors.Is(err, controllers.ErrDeckNotFound) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckNotFound, err)
+	}
+}
+
+// Tests that responses saved by a failed change are discarded
+// with it, and that full decks reject new keys
+func TestIdempotentResponsesStoredWithChanges(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+	deck, _ := controller.CreateDeck(true, nil)
+
+	err := controller.IfVersion(deck.Id, nil, func(c *controllers.DeckController) error {
+		c.DrawCards(deck.Id, 1)
+		c.SaveResponse(deck.Id, data.StoredResponse{Key: "key-1", Status: 200})
+		_, err := c.DrawCards(deck.Id, data.MaxCards)
+		return err
+	})
+	if !errors.Is(err, controllers.ErrNotEnoughCards) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrNotEnoughCards, err)
+	}
+
+	if response, _ := controller.BeginRequest("key-1", ""); response != nil {
+		t.Errorf("The response of a failed change should not be stored")
+	}
+	controller.EndRequest("key-1")
+
+	if opened, _ := controller.OpenDeck(deck.Id); opened.Remaining != data.MaxCards {
+		t.Errorf("The failed change should not draw, %d remaining", opened.Remaining)
+	}
+
+	for i := 0; i < data.MaxResponses; i++ {
+		controller.SaveResponse(deck.Id, data.StoredResponse{Key: fmt.Sprintf("key%d", i), Status: 200})
+	}
+
+	err = controller.SaveResponse(deck.Id, data.StoredResponse{Key: "full", Status: 200})
+	if !errors.Is(err, controllers.ErrIdempotencyFull) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrIdempotencyFull, err)
+	}
+}
+
+// Tests that the decks created with WithNewDecks are only added
+// if it succeeds
+func TestWithNewDecks(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	var created *data.Deck
+	err := controller.WithNewDecks(func(c *controllers.DeckController) error {
+		created, _ = c.CreateDeck(true, nil)
+		return c.SaveResponse(created.Id, data.StoredResponse{Key: "create-1", Status: 201})
+	})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	opened, err := controller.OpenDeck(created.Id)
+	if err != nil || opened.Version != 1 || len(opened.Responses) != 1 {
+		t.Errorf("The deck should be added at version 1 with its response, got %+v %v", opened, err)
+	}
+
+	var discarded *data.Deck
+	err = controller.WithNewDecks(func(c *controllers.DeckController) error {
+		discarded, _ = c.CreateDeck(true, nil)
+		return controllers.ErrInvalidAmount
+	})
+	if !errors.Is(err, controllers.ErrInvalidAmount) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrInvalidAmount, err)
+	}
+
+	if _, err := controller.OpenDeck(discarded.Id); !errors.Is(err, controllers.ErrDeckNotFound) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckNotFound, err)
+	}
+}
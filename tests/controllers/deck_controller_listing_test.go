@@ -0,0 +1,144 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+	"time"
+)
+
+// Returns a controller whose clock advances a second with
+// every deck created
+func newDatedController() *controllers.DeckController {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	controller.SetClock(func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	})
+
+	return controller
+}
+
+// Tests the creation time and tags of the new decks
+func TestCreateDeckMetadata(t *testing.T) {
+
+	controller := newDatedController()
+
+	deck, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Tags: []string{"poker", "table-1", "poker"}})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if len(deck.Tags) != 2 || deck.Tags[0] != "poker" || deck.Tags[1] != "table-1" {
+		t.Errorf("The repeated tags should be removed, got %v", deck.Tags)
+	}
+
+	if !deck.CreatedAt.Equal(time.Date(2024, 5, 1, 12, 0, 1, 0, time.UTC)) {
+		t.Errorf("The deck should be created at the controller time, got %v", deck.CreatedAt)
+	}
+
+	tooMany := make([]string, controllers.MaxTags+1)
+	for i := range tooMany {
+		tooMany[i] = string(rune('a' + i))
+	}
+
+	for _, tags := range [][]string{{""}, {"two words"}, tooMany} {
+		_, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Tags: tags})
+		if !errors.Is(err, controllers.ErrInvalidTag) {
+			t.Errorf("Should have returned %v for %v, got %v", controllers.ErrInvalidTag, tags, err)
+		}
+	}
+}
+
+// Tests listing every deck in pages with the cursors
+func TestListDecksPages(t *testing.T) {
+
+	controller := newDatedController()
+
+	var created []*data.Deck
+	for i := 0; i < 5; i++ {
+		var tags []string
+		if i%2 == 0 {
+			tags = []string{"even"}
+		}
+		deck, _ := controller.CreateDeckWithOptions(controllers.DeckOptions{Tags: tags})
+		created = append(created, deck)
+	}
+
+	for _, sort := range []string{"", "-created_at"} {
+		var listed []*data.Deck
+		options := controllers.ListOptions{Sort: sort, Limit: 2}
+
+		for pages := 1; ; pages++ {
+			page, err := controller.ListDecks(options)
+			if err != nil {
+				t.Fatalf("There should be no error: %v", err)
+			}
+			for i := range page.Decks {
+				listed = append(listed, &page.Decks[i])
+			}
+			if page.Next == "" {
+				if pages != 3 {
+					t.Errorf("There should be 3 pages, got %d", pages)
+				}
+				break
+			}
+			options.Cursor = page.Next
+		}
+
+		if len(listed) != len(created) {
+			t.Fatalf("There should be %d decks, got %d", len(created), len(listed))
+		}
+
+		for i, v := range listed {
+			expected := created[i]
+			if sort != "" {
+				expected = created[len(created)-1-i]
+			}
+			if v.Id != expected.Id {
+				t.Errorf("Deck %d should be %v, got %v", i, expected.Id, v.Id)
+			}
+		}
+	}
+
+	page, _ := controller.ListDecks(controllers.ListOptions{Tag: "even"})
+	if len(page.Decks) != 3 || page.Next != "" {
+		t.Errorf("There should be 3 decks tagged even, got %d", len(page.Decks))
+	}
+}
+
+// Tests the invalid list options
+func TestListDecksErrors(t *testing.T) {
+
+	controller := newDatedController()
+	controller.CreateDeck(false, nil)
+	controller.CreateDeck(false, nil)
+
+	page, _ := controller.ListDecks(controllers.ListOptions{Limit: 1})
+
+	tests := []struct {
+		options  controllers.ListOptions
+		expected error
+	}{
+		{controllers.ListOptions{Limit: -1}, controllers.ErrInvalidPageSize},
+		{controllers.ListOptions{Limit: controllers.MaxPageSize + 1}, controllers.ErrInvalidPageSize},
+		{controllers.ListOptions{Sort: "composition"}, controllers.ErrInvalidSort},
+		{controllers.ListOptions{Tag: "two words"}, controllers.ErrInvalidTag},
+		{controllers.ListOptions{Owner: "bad player!"}, controllers.ErrInvalidPlayer},
+		{controllers.ListOptions{Cursor: "not a cursor"}, controllers.ErrInvalidCursor},
+		// Cursors only work with the order of their page
+		{controllers.ListOptions{Cursor: page.Next, Sort: "remaining"}, controllers.ErrInvalidCursor},
+	}
+
+	for _, v := range tests {
+		if _, err := controller.ListDecks(v.options); !errors.Is(err, v.expected) {
+			t.Errorf("Should have returned %v for %+v, got %v", v.expected, v.options, err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Tests peeking at the top cards without drawing them
+func TestPeekCards(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(true, nil)
+
+	cards, err := controller.PeekCards(deck.Id, 3, "")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if !sameOrder(cards, deck.Cards[:3]) {
+		t.Errorf("The top cards should have been returned")
+	}
+
+	drawn, _ := controller.DrawCards(deck.Id, 3)
+	if !sameOrder(cards, drawn) {
+		t.Errorf("Peeking should not remove the cards")
+	}
+
+	if _, err := controller.PeekCards(deck.Id, 0, ""); !errors.Is(err, controllers.ErrInvalidAmount) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrInvalidAmount, err)
+	}
+
+	if _, err := controller.PeekCards(deck.Id, data.MaxCards, ""); !errors.Is(err, controllers.ErrNotEnoughCards) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrNotEnoughCards, err)
+	}
+}
+
+// Tests burning the top cards
+func TestBurnCards(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(true, nil)
+
+	burned, err := controller.BurnCards(deck.Id, 1, "")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if burned.Remaining != data.MaxCards-1 || len(burned.Burned) != 1 || burned.Burned[0] != deck.Cards[0] {
+		t.Errorf("The top card should have been burned")
+	}
+
+	drawn, _ := controller.DrawCards(deck.Id, 1)
+	if drawn[0] != deck.Cards[1] {
+		t.Errorf("The card after the burned one should be drawn")
+	}
+
+	if _, err := controller.BurnCards(deck.Id, data.MaxCards, ""); !errors.Is(err, controllers.ErrNotEnoughCards) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrNotEnoughCards, err)
+	}
+
+	controller.CloseDeck(deck.Id, "")
+	if _, err := controller.BurnCards(deck.Id, 1, ""); !errors.Is(err, controllers.ErrDeckClosed) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckClosed, err)
+	}
+}
+
+// Returns true if the cards are sorted by instance
+func sortedByInstance(cards []data.Card) bool {
+
+	for i := 1; i < len(cards); i++ {
+		if cards[i-1].Instance > cards[i].Instance {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Tests that opening a deck that hides its order returns the
+// cards left in composition order to every viewer
+func TestOpenDeckHideOrder(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeckWithOptions(controllers.DeckOptions{Shuffle: true, HideOrder: true})
+	controller.DrawCards(deck.Id, 2)
+
+	for _, viewer := range []string{"", "alice"} {
+		opened, err := controller.OpenDeckWithOptions(deck.Id, controllers.OpenOptions{Viewer: viewer})
+		if err != nil {
+			t.Fatalf("There should be no error: %v", err)
+		}
+
+		if opened.Remaining != data.MaxCards-2 || len(opened.Cards) != data.MaxCards-2 {
+			t.Errorf("Remaining cards must be = %d", data.MaxCards-2)
+		}
+
+		if !sortedByInstance(opened.Cards) {
+			t.Errorf("The cards should be sorted by instance for %q", viewer)
+		}
+	}
+
+	// Decks with no owner hide the order from everybody
+	if _, err := controller.PeekCards(deck.Id, 1, ""); !errors.Is(err, controllers.ErrNotDeckOwner) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrNotDeckOwner, err)
+	}
+
+	shown, _ := controller.CreateDeck(true, nil)
+	opened, _ := controller.OpenDeckWithOptions(shown.Id, controllers.OpenOptions{})
+	if !sameOrder(opened.Cards, shown.Cards) {
+		t.Errorf("The order should be shown by default")
+	}
+}
+
+// Tests that the owner of a deck that hides its order is the
+// only one that sees it
+func TestOpenDeckHideOrderOwner(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeckWithOptions(controllers.DeckOptions{Shuffle: true, HideOrder: true, Owner: "dealer"})
+
+	opened, _ := controller.OpenDeckWithOptions(deck.Id, controllers.OpenOptions{Viewer: "dealer"})
+	if !sameOrder(opened.Cards, deck.Cards) {
+		t.Errorf("The owner should see the order")
+	}
+
+	if _, err := controller.PeekCards(deck.Id, 1, "dealer"); err != nil {
+		t.Errorf("There should be no error: %v", err)
+	}
+
+	opened, _ = controller.OpenDeckWithOptions(deck.Id, controllers.OpenOptions{Viewer: "mallory"})
+	if !sortedByInstance(opened.Cards) {
+		t.Errorf("The cards should be sorted by instance for other players")
+	}
+}
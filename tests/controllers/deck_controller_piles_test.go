@@ -0,0 +1,160 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Tests creating piles and their name validation
+func TestCreatePile(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(true, nil)
+
+	created, err := controller.CreatePile(deck.Id, "north")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if len(created.Piles) != 1 || created.Piles[0].Name != "north" || len(created.Piles[0].Cards) != 0 {
+		t.Errorf("The deck should have an empty pile, got %+v", created.Piles)
+	}
+
+	if _, err := controller.CreatePile(deck.Id, "north"); !errors.Is(err, controllers.ErrPileExists) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrPileExists, err)
+	}
+
+	for _, name := range []string{"", "two words", "a/b", strings.Repeat("a", 33)} {
+		if _, err := controller.CreatePile(deck.Id, name); !errors.Is(err, controllers.ErrInvalidPileName) {
+			t.Errorf("Pile %q should return %v, got %v", name, controllers.ErrInvalidPileName, err)
+		}
+	}
+
+	for i := 1; i < controllers.MaxPiles; i++ {
+		controller.CreatePile(deck.Id, fmt.Sprintf("pile%d", i))
+	}
+
+	if _, err := controller.CreatePile(deck.Id, "extra"); !errors.Is(err, controllers.ErrTooManyPiles) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrTooManyPiles, err)
+	}
+}
+
+// Tests dealing a hand, discarding from it and returning the
+// discard pile to the deck
+func TestPiles(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(true, nil)
+	controller.CreatePile(deck.Id, "north")
+	controller.CreatePile(deck.Id, "discard")
+
+	hand, err := controller.DrawCardsToPile(deck.Id, "north", 5, "", "")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if !sameOrder(hand, deck.Cards[:5]) {
+		t.Errorf("The top cards should have been dealt to the pile")
+	}
+
+	pile, _ := controller.GetPile(deck.Id, "north", "")
+	if !sameOrder(pile.Cards, hand) {
+		t.Errorf("The pile should have the dealt cards")
+	}
+
+	moved, err := controller.MovePileCards(deck.Id, "north", "discard", []string{strings.ToLower(hand[1].Code)}, "")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if discard := moved.GetPile("discard"); len(discard.Cards) != 1 || discard.Cards[0] != hand[1] {
+		t.Errorf("The card should have been discarded")
+	}
+
+	if _, err := controller.ShufflePile(deck.Id, "north", "riffle*3", ""); err != nil {
+		t.Errorf("There should be no error: %v", err)
+	}
+
+	returned, err := controller.ReturnPile(deck.Id, "discard", controllers.PositionBottom, "")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if returned.Remaining != data.MaxCards-4 || returned.Cards[returned.Remaining-1] != hand[1] {
+		t.Errorf("The discarded card should be at the bottom of the deck")
+	}
+
+	if _, err := controller.GetPile(deck.Id, "south", ""); !errors.Is(err, controllers.ErrPileNotFound) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrPileNotFound, err)
+	}
+
+	if _, err := controller.DrawCardsToPile(deck.Id, "south", 1, "", ""); !errors.Is(err, controllers.ErrPileNotFound) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrPileNotFound, err)
+	}
+
+	if _, err := controller.MovePileCards(deck.Id, "north", "discard", []string{"XX"}, ""); !errors.Is(err, controllers.ErrInvalidCardCode) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrInvalidCardCode, err)
+	}
+
+	if _, err := controller.MovePileCards(deck.Id, "north", "discard", []string{hand[1].Code}, ""); !errors.Is(err, controllers.ErrCardNotFound) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrCardNotFound, err)
+	}
+
+	if _, err := controller.ShufflePile(deck.Id, "north", "shake", ""); !errors.Is(err, controllers.ErrInvalidShuffleMethod) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrInvalidShuffleMethod, err)
+	}
+
+	if _, err := controller.DrawCardsToPile(deck.Id, "north", data.MaxCards, "", ""); !errors.Is(err, controllers.ErrNotEnoughCards) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrNotEnoughCards, err)
+	}
+}
+
+// Tests that the piles of a closed deck can't change
+func TestClosedDeckPiles(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(true, nil)
+	controller.CreatePile(deck.Id, "north")
+	controller.DrawCardsToPile(deck.Id, "north", 3, "", "")
+	controller.CloseDeck(deck.Id, "")
+
+	if _, err := controller.CreatePile(deck.Id, "south"); !errors.Is(err, controllers.ErrDeckClosed) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckClosed, err)
+	}
+
+	if _, err := controller.ShufflePile(deck.Id, "north", "", ""); !errors.Is(err, controllers.ErrDeckClosed) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckClosed, err)
+	}
+
+	stored, _ := controller.OpenDeck(deck.Id)
+	if len(stored.Piles) != 1 || stored.Version != 4 {
+		t.Errorf("The closed deck should not change, got %d piles at version %d", len(stored.Piles), stored.Version)
+	}
+}
+
+// Tests that fair decks deal to piles but their piles can't
+// be returned, as that would change the committed order
+func TestFairDeckPiles(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck := newFairDeck(t, controller, controllers.DeckOptions{})
+	controller.CreatePile(deck.Id, "north")
+
+	if _, err := controller.DrawCardsToPile(deck.Id, "north", 2, "", ""); err != nil {
+		t.Errorf("There should be no error: %v", err)
+	}
+
+	if _, err := controller.ReturnPile(deck.Id, "north", "", ""); !errors.Is(err, controllers.ErrFairDeckShuffle) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrFairDeckShuffle, err)
+	}
+}
@@ -0,0 +1,112 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Tests putting drawn cards back in a deck
+func TestReturnCards(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(false, nil)
+	controller.DrawCards(deck.Id, 12)
+
+	// Codes are accepted in any notation
+	returned, err := controller.ReturnCards(deck.Id, []string{"S10", "as"}, "", "")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if returned.Cards[0].Code != "TS" || returned.Cards[1].Code != "AS" || returned.Remaining != data.MaxCards-10 {
+		t.Errorf("The cards should be returned on top")
+	}
+
+	returned, _ = controller.ReturnCards(deck.Id, nil, "BOTTOM", "")
+	if returned.Cards[returned.Remaining-1].Code != "QS" || returned.Remaining != data.MaxCards || len(returned.Drawn) != 0 {
+		t.Errorf("Every drawn card should be returned at the bottom")
+	}
+
+	controller.DrawCards(deck.Id, 5)
+	if returned, _ = controller.ReturnCards(deck.Id, nil, "random", ""); returned.Remaining != data.MaxCards || !returned.Shuffled {
+		t.Errorf("Returning cards at random should shuffle the deck")
+	}
+
+	errorTests := []struct {
+		codes    []string
+		position string
+		err      error
+	}{
+		{[]string{"AS"}, "middle", controllers.ErrInvalidPosition},
+		{[]string{"ZZ"}, "top", controllers.ErrInvalidCardCode},
+		{[]string{"AS"}, "top", controllers.ErrCardNotDrawn},
+	}
+
+	for _, test := range errorTests {
+		if _, err := controller.ReturnCards(deck.Id, test.codes, test.position, ""); !errors.Is(err, test.err) {
+			t.Errorf("Returning %v at %s should return %v, got %v", test.codes, test.position, test.err, err)
+		}
+	}
+}
+
+// Tests shuffling the drawn cards back in the deck
+func TestShuffleDeckIncludeDrawn(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(false, nil)
+	controller.DrawCards(deck.Id, 20)
+
+	shuffled, err := controller.ShuffleDeck(deck.Id, "", true, "")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if shuffled.Remaining != data.MaxCards || len(shuffled.Cards) != data.MaxCards || len(shuffled.Drawn) != 0 {
+		t.Errorf("Every card should be back in the deck")
+	}
+}
+
+// Tests cutting a deck at a position and close to the middle
+func TestCutDeck(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(false, nil)
+
+	position := 13
+	cut, err := controller.CutDeck(deck.Id, &position, "")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if cut.Cards[0].Code != "AD" || cut.Cards[data.MaxCards-1].Code != "KS" || cut.Shuffled {
+		t.Errorf("The spades should be at the bottom and the deck not shuffled")
+	}
+
+	cut, _ = controller.CutDeck(deck.Id, nil, "")
+	if cut.Cards[0].Code == "AD" || cut.Remaining != data.MaxCards {
+		t.Errorf("The deck should be cut again")
+	}
+
+	for _, position := range []int{0, -1, data.MaxCards} {
+		if _, err := controller.CutDeck(deck.Id, &position, ""); !errors.Is(err, controllers.ErrInvalidPosition) {
+			t.Errorf("Cutting at %d should return %v, got %v", position, controllers.ErrInvalidPosition, err)
+		}
+	}
+
+	controller.DrawCards(deck.Id, data.MaxCards-1)
+	if _, err := controller.CutDeck(deck.Id, nil, ""); !errors.Is(err, controllers.ErrNotEnoughCards) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrNotEnoughCards, err)
+	}
+
+	fair, _ := controller.CreateDeckWithOptions(controllers.DeckOptions{Fair: true})
+	if _, err := controller.CutDeck(fair.Id, nil, ""); !errors.Is(err, controllers.ErrFairDeckShuffle) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrFairDeckShuffle, err)
+	}
+}
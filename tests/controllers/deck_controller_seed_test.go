@@ -0,0 +1,119 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"math/rand"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Returns true if both card sets have the same order
+func sameOrder(a []data.Card, b []data.Card) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i, v := range a {
+		if b[i] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Tests that the same seed always produces the same order,
+// even from different controllers
+func TestCreateDeckWithSeed(t *testing.T) {
+
+	seed := int64(42)
+	options := controllers.DeckOptions{Shuffle: true, Seed: &seed}
+
+	deck1, _ := controllers.NewDeckController(&data.MemoryDeckRepository{}).CreateDeckWithOptions(options)
+	deck2, _ := controllers.NewDeckController(&data.MemoryDeckRepository{}).CreateDeckWithOptions(options)
+
+	if !sameOrder(deck1.Cards, deck2.Cards) {
+		t.Errorf("Decks with the same seed must have the same order")
+	}
+
+	if deck1.Seed != seed || !deck1.Shuffled {
+		t.Errorf("Deck seed should be %d, got %d", seed, deck1.Seed)
+	}
+
+	other := int64(43)
+	options.Seed = &other
+	deck3, _ := controllers.NewDeckController(&data.MemoryDeckRepository{}).CreateDeckWithOptions(options)
+
+	if sameOrder(deck1.Cards, deck3.Cards) {
+		t.Errorf("Decks with different seeds must have different orders")
+	}
+}
+
+// Tests that only the decks shuffled with a seed are
+// flagged as reproducible
+func TestCreateDeckSeeded(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	seed := int64(42)
+	seeded, _ := controller.CreateDeckWithOptions(controllers.DeckOptions{Shuffle: true, Seed: &seed})
+	if !seeded.Seeded {
+		t.Errorf("A deck shuffled with a seed should be seeded")
+	}
+
+	deck, _ := controller.CreateDeck(true, nil)
+	if deck.Seeded || deck.Seed != 0 {
+		t.Errorf("A deck shuffled without seed should not be seeded")
+	}
+
+	fair, _ := controller.CreateDeckWithOptions(controllers.DeckOptions{Fair: true})
+	if fair.Seeded {
+		t.Errorf("A fair deck should not be seeded")
+	}
+}
+
+// Tests that a seed is rejected when the deck would not be
+// shuffled with it
+func TestSeedWithoutShuffle(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	seed := int64(42)
+	invalid := map[string]controllers.DeckOptions{
+		"no shuffle": {Seed: &seed},
+		"codes":      {Shuffle: true, Codes: []string{"AS", "KH"}, Seed: &seed},
+		"fair":       {Fair: true, Seed: &seed},
+	}
+
+	for name, options := range invalid {
+		if _, err := controller.CreateDeckWithOptions(options); !errors.Is(err, controllers.ErrSeedWithoutShuffle) {
+			t.Errorf("A seed with %s should return %v, got %v", name, controllers.ErrSeedWithoutShuffle, err)
+		}
+	}
+
+	deck, err := controller.CreateDeckWithOptions(controllers.DeckOptions{ShuffleMethod: "riffle*7", Seed: &seed})
+	if err != nil || !deck.Seeded {
+		t.Errorf("A seed with a shuffle method should shuffle the deck: %v", err)
+	}
+}
+
+// Tests that controllers with the same random source
+// create the same sequence of decks
+func TestCreateDeckInjectedSource(t *testing.T) {
+
+	controller1 := controllers.NewDeckControllerWithSource(&data.MemoryDeckRepository{}, rand.NewSource(7))
+	controller2 := controllers.NewDeckControllerWithSource(&data.MemoryDeckRepository{}, rand.NewSource(7))
+
+	for i := 0; i < 3; i++ {
+		deck1, _ := controller1.CreateDeck(true, nil)
+		deck2, _ := controller2.CreateDeck(true, nil)
+
+		if !sameOrder(deck1.Cards, deck2.Cards) {
+			t.Errorf("Deck %d should be the same for both controllers", i)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Tests that a shoe contains every card once per deck and
+// each physical card has a different instance
+func TestCreateDeckShoe(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	decks := 6
+	deck, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Shuffle: true, Decks: decks})
+
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if deck.Remaining != data.MaxCards*decks || deck.Decks != decks {
+		t.Errorf("Remaining cards must be = %d", data.MaxCards*decks)
+	}
+
+	codes := map[string]int{}
+	instances := map[int]bool{}
+	for _, v := range deck.Cards {
+		codes[v.Code]++
+		if instances[v.Instance] {
+			t.Errorf("Instance %d is repeated", v.Instance)
+		}
+		instances[v.Instance] = true
+	}
+
+	for code, n := range codes {
+		if n != decks {
+			t.Errorf("Card %v should appear %d times, got %d", code, decks, n)
+		}
+	}
+}
+
+// Tests that repeated codes in a partial deck get
+// different instances
+func TestCreateDeckPartialInstances(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	deck, _ := controller.CreateDeck(false, []string{"AS", "AS", "KH"})
+
+	for i, v := range deck.Cards {
+		if v.Instance != i+1 {
+			t.Errorf("Card %d should be instance %d, got %d", i, i+1, v.Instance)
+		}
+	}
+}
+
+// Tests that the reshuffle flag is raised once the
+// cut card is reached
+func TestCreateDeckPenetration(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	deck, _ := controller.CreateDeckWithOptions(controllers.DeckOptions{Decks: 2, Penetration: 0.75})
+
+	// 104 cards, the cut card is placed after 78 of them
+	if deck.CutCard != 26 {
+		t.Fatalf("Cut card should be at 26 remaining cards, got %d", deck.CutCard)
+	}
+
+	controller.DrawCards(deck.Id, 77)
+	opened, _ := controller.OpenDeck(deck.Id)
+	if opened.NeedsReshuffle() {
+		t.Errorf("Deck should not need reshuffle before reaching the cut card")
+	}
+
+	controller.DrawCards(deck.Id, 1)
+	opened, _ = controller.OpenDeck(deck.Id)
+	if !opened.NeedsReshuffle() {
+		t.Errorf("Deck should need reshuffle after reaching the cut card")
+	}
+}
+
+// Tests that the cut card is rounded and always leaves cards
+// before and after it
+func TestCreateDeckPenetrationBounds(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	tests := []struct {
+		codes       []string
+		penetration float64
+		cutCard     int
+	}{
+		// 0.052 cards round to none, so one is dealt
+		{nil, 0.001, 51},
+		{nil, 0.999, 1},
+		// 39.52 cards are rounded to 40
+		{nil, 0.76, 12},
+		{[]string{"AS", "KH", "QD"}, 0.5, 1},
+		{[]string{"AS", "KH"}, 0.1, 1},
+	}
+
+	for _, v := range tests {
+		deck, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Codes: v.codes, Penetration: v.penetration})
+		if err != nil {
+			t.Fatalf("There should be no error: %v", err)
+		}
+
+		if deck.CutCard != v.cutCard || deck.NeedsReshuffle() {
+			t.Errorf("Penetration %v of %d cards should place the cut card at %d, got %d", v.penetration, deck.Remaining, v.cutCard, deck.CutCard)
+		}
+	}
+
+	_, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Codes: []string{"AS"}, Penetration: 0.5})
+	if !errors.Is(err, controllers.ErrInvalidPenetration) {
+		t.Errorf("A single card deck should return %v, got %v", controllers.ErrInvalidPenetration, err)
+	}
+}
+
+// Tests that invalid shoe options are rejected
+func TestCreateDeckInvalidShoe(t *testing.T) {
+
+	repository := &data.MemoryDeckRepository{}
+	controller := controllers.NewDeckController(repository)
+
+	_, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Decks: controllers.MaxDecks + 1})
+	if !errors.Is(err, controllers.ErrInvalidDecks) {
+		t.Errorf("Should have returned %v", controllers.ErrInvalidDecks)
+	}
+
+	_, err = controller.CreateDeckWithOptions(controllers.DeckOptions{Penetration: 1})
+	if !errors.Is(err, controllers.ErrInvalidPenetration) {
+		t.Errorf("Should have returned %v", controllers.ErrInvalidPenetration)
+	}
+}
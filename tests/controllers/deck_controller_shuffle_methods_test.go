@@ -0,0 +1,220 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"math/rand"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Returns true if a contains every number from 0 to n-1 once
+func isPermutation(a []int, n int) bool {
+
+	if len(a) != n {
+		return false
+	}
+
+	seen := make([]bool, n)
+	for _, v := range a {
+		if v < 0 || v >= n || seen[v] {
+			return false
+		}
+		seen[v] = true
+	}
+
+	return true
+}
+
+// Returns the amount of rising sequences of a permutation: the
+// maximal runs of consecutive cards that keep their relative order
+func risingSequences(a []int) int {
+
+	position := make([]int, len(a))
+	for i, v := range a {
+		position[v] = i
+	}
+
+	sequences := 1
+	for v := 1; v < len(a); v++ {
+		if position[v] < position[v-1] {
+			sequences++
+		}
+	}
+
+	return sequences
+}
+
+// Tests the parsing of valid and invalid shuffle plans
+func TestParseShufflePlan(t *testing.T) {
+
+	plan, err := controllers.ParseShufflePlan("Riffle*7, cut,overhand×2,strip")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	expected := controllers.ShufflePlan{
+		{Method: controllers.MethodRiffle, Times: 7},
+		{Method: controllers.MethodCut, Times: 1},
+		{Method: controllers.MethodOverhand, Times: 2},
+		{Method: controllers.MethodStrip, Times: 1},
+	}
+
+	if len(plan) != len(expected) {
+		t.Fatalf("The plan should have %d steps, got %d", len(expected), len(plan))
+	}
+
+	for i, v := range expected {
+		if plan[i] != v {
+			t.Errorf("Step %d should be %v, got %v", i, v, plan[i])
+		}
+	}
+
+	for _, text := range []string{"", "riffle,", "shake", "riffle*0", "riffle*101", "riffle*x", "cut*2*3"} {
+		if _, err := controllers.ParseShufflePlan(text); !errors.Is(err, controllers.ErrInvalidShuffleMethod) {
+			t.Errorf("Plan %q should return %v, got %v", text, controllers.ErrInvalidShuffleMethod, err)
+		}
+	}
+}
+
+// Tests that every method returns a permutation of the cards
+func TestShuffleMethodsPermutation(t *testing.T) {
+
+	source := rand.New(rand.NewSource(1))
+
+	for _, method := range []string{"riffle", "overhand", "strip", "cut", "riffle*7,cut"} {
+		plan, _ := controllers.ParseShufflePlan(method)
+		shuffler := controllers.PlanShuffler{Plan: plan, Source: source}
+
+		for _, n := range []int{0, 1, 2, 52, 416} {
+			if a := shuffler.Permutation(n); !isPermutation(a, n) {
+				t.Errorf("%s of %d cards should return a permutation, got %v", method, n, a)
+			}
+		}
+	}
+}
+
+// Tests that a riffle interleaves two packets, so it produces at
+// most two rising sequences, and that a cut rotates the deck
+func TestRiffleAndCut(t *testing.T) {
+
+	source := rand.New(rand.NewSource(1))
+	riffle := controllers.PlanShuffler{Plan: controllers.ShufflePlan{{Method: controllers.MethodRiffle, Times: 1}}, Source: source}
+	cut := controllers.PlanShuffler{Plan: controllers.ShufflePlan{{Method: controllers.MethodCut, Times: 1}}, Source: source}
+
+	for i := 0; i < 100; i++ {
+		if sequences := risingSequences(riffle.Permutation(52)); sequences > 2 {
+			t.Fatalf("A riffle should have at most 2 rising sequences, got %d", sequences)
+		}
+
+		a := cut.Permutation(52)
+		if a[0] == 0 {
+			t.Fatalf("A cut should move the top card")
+		}
+		for j, v := range a {
+			if v != (a[0]+j)%52 {
+				t.Fatalf("A cut should keep the order of the cards, got %v", a)
+			}
+		}
+	}
+}
+
+// Tests that seven riffles mix the deck much better than one,
+// counting the rising sequences
+func TestRifflesMix(t *testing.T) {
+
+	source := rand.New(rand.NewSource(1))
+	plan, _ := controllers.ParseShufflePlan("riffle*7")
+	shuffler := controllers.PlanShuffler{Plan: plan, Source: source}
+
+	total := 0
+	for i := 0; i < 100; i++ {
+		total += risingSequences(shuffler.Permutation(52))
+	}
+
+	// A uniformly random permutation of 52 cards has 26.5
+	// rising sequences on average
+	if average := float64(total) / 100; average < 20 {
+		t.Errorf("Seven riffles should have about 26 rising sequences, got %f", average)
+	}
+}
+
+// Tests creating decks with a shuffle plan, which is
+// reproducible with a seed
+func TestCreateDeckWithShuffleMethod(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	seed := int64(42)
+	options := controllers.DeckOptions{ShuffleMethod: "riffle*3,cut", Seed: &seed}
+
+	deck1, err := controller.CreateDeckWithOptions(options)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	deck2, _ := controller.CreateDeckWithOptions(options)
+
+	if !deck1.Shuffled || !deck1.Seeded || !sameOrder(deck1.Cards, deck2.Cards) {
+		t.Errorf("Decks with the same plan and seed must be shuffled in the same order")
+	}
+
+	if sameOrder(deck1.Cards, controller.GetDefaultCardSet()) {
+		t.Errorf("The deck should have been shuffled")
+	}
+
+	if _, err := controller.CreateDeckWithOptions(controllers.DeckOptions{ShuffleMethod: "shake"}); !errors.Is(err, controllers.ErrInvalidShuffleMethod) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrInvalidShuffleMethod, err)
+	}
+
+	if _, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Fair: true, ShuffleMethod: "cut"}); !errors.Is(err, controllers.ErrInvalidShuffleMethod) {
+		t.Errorf("Fair decks should return %v, got %v", controllers.ErrInvalidShuffleMethod, err)
+	}
+}
+
+// Tests shuffling the cards left in an existing deck
+func TestShuffleDeck(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	deck, _ := controller.CreateDeck(false, nil)
+	controller.DrawCards(deck.Id, 2)
+
+	shuffled, err := controller.ShuffleDeck(deck.Id, "overhand*4", false, "")
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	if !shuffled.Shuffled || shuffled.Remaining != data.MaxCards-2 {
+		t.Errorf("The deck should be shuffled and keep its cards")
+	}
+
+	instances := map[int]bool{}
+	for _, v := range shuffled.Cards {
+		instances[v.Instance] = true
+	}
+
+	if len(instances) != data.MaxCards-2 || instances[1] || instances[2] {
+		t.Errorf("The shuffled deck should have the cards left")
+	}
+
+	if _, err := controller.ShuffleDeck(deck.Id, "", false, ""); err != nil {
+		t.Errorf("A deck should be shuffled without method: %v", err)
+	}
+
+	if _, err := controller.ShuffleDeck(deck.Id, "shake", false, ""); !errors.Is(err, controllers.ErrInvalidShuffleMethod) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrInvalidShuffleMethod, err)
+	}
+
+	fair, _ := controller.CreateDeckWithOptions(controllers.DeckOptions{Fair: true})
+	if _, err := controller.ShuffleDeck(fair.Id, "cut", false, ""); !errors.Is(err, controllers.ErrFairDeckShuffle) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrFairDeckShuffle, err)
+	}
+
+	controller.CloseDeck(deck.Id, "")
+	if _, err := controller.ShuffleDeck(deck.Id, "cut", false, ""); !errors.Is(err, controllers.ErrDeckClosed) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckClosed, err)
+	}
+}
@@ -0,0 +1,183 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"math"
+	"math/rand"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Standard normal quantiles of the significance levels used
+const (
+	// alpha = 0.001, for deterministic sources
+	z001 = 3.090
+	// alpha = 0.000001, so that random sources don't fail
+	z000001 = 4.753
+)
+
+// Returns the critical value of the chi-square distribution with
+// dof degrees of freedom for the normal quantile z, using the
+// Wilson–Hilferty approximation
+func chiSquareCritical(dof int, z float64) float64 {
+
+	k := float64(dof)
+	a := 2 / (9 * k)
+
+	return k * math.Pow(1-a+z*math.Sqrt(a), 3)
+}
+
+// Shuffles n cards trials times and returns the chi-square
+// statistic of how many times every card ends at every position,
+// which has (n-1)^2 degrees of freedom
+func positionChiSquare(shuffler controllers.Shuffler, n int, trials int) float64 {
+
+	counts := make([][]int, n)
+	for i := range counts {
+		counts[i] = make([]int, n)
+	}
+
+	for t := 0; t < trials; t++ {
+		for position, card := range shuffler.Permutation(n) {
+			counts[card][position]++
+		}
+	}
+
+	expected := float64(trials) / float64(n)
+	statistic := 0.0
+	for _, row := range counts {
+		for _, v := range row {
+			d := float64(v) - expected
+			statistic += d * d / expected
+		}
+	}
+
+	return statistic
+}
+
+// Shuffler swapping every position with any other one, which
+// does not produce every permutation with the same probability
+type naiveShuffler struct {
+	random *rand.Rand
+}
+
+func (s naiveShuffler) Permutation(n int) []int {
+
+	a := make([]int, n)
+	for i := range a {
+		a[i] = i
+	}
+
+	for i := range a {
+		j := s.random.Intn(n)
+		a[i], a[j] = a[j], a[i]
+	}
+
+	return a
+}
+
+// Shuffler reversing the cards
+type reverseShuffler struct{}
+
+func (reverseShuffler) Permutation(n int) []int {
+
+	a := make([]int, n)
+	for i := range a {
+		a[i] = n - 1 - i
+	}
+
+	return a
+}
+
+// Tests that the Fisher–Yates shuffle places every card at
+// every position with the same probability
+func TestFisherYatesUniformPositions(t *testing.T) {
+
+	n, trials := 10, 20000
+	critical := chiSquareCritical((n-1)*(n-1), z001)
+
+	shuffler := controllers.FisherYatesShuffler{Source: rand.New(rand.NewSource(1))}
+	if statistic := positionChiSquare(shuffler, n, trials); statistic > critical {
+		t.Errorf("Chi-square should be below %f, got %f", critical, statistic)
+	}
+}
+
+// Tests that the chi-square test detects a biased shuffle
+func TestNaiveShuffleIsBiased(t *testing.T) {
+
+	n, trials := 10, 20000
+	critical := chiSquareCritical((n-1)*(n-1), z001)
+
+	shuffler := naiveShuffler{random: rand.New(rand.NewSource(1))}
+	if statistic := positionChiSquare(shuffler, n, trials); statistic <= critical {
+		t.Errorf("Chi-square should be above %f, got %f", critical, statistic)
+	}
+}
+
+// Tests that the cryptographically secure shuffle places every
+// card at every position with the same probability
+func TestCryptoShuffleUniformPositions(t *testing.T) {
+
+	n, trials := 10, 20000
+	critical := chiSquareCritical((n-1)*(n-1), z000001)
+
+	shuffler := controllers.FisherYatesShuffler{Source: controllers.CryptoSource{}}
+	if statistic := positionChiSquare(shuffler, n, trials); statistic > critical {
+		t.Errorf("Chi-square should be below %f, got %f", critical, statistic)
+	}
+}
+
+// Tests that CryptoSource returns unbiased values in range for
+// bounds that are not a power of two
+func TestCryptoSourceUniform(t *testing.T) {
+
+	source := controllers.CryptoSource{}
+
+	for _, n := range []int{1, 3, 7, 52} {
+
+		trials := n * 2000
+		counts := make([]int, n)
+
+		for i := 0; i < trials; i++ {
+			v := source.Intn(n)
+			if v < 0 || v >= n {
+				t.Fatalf("Intn(%d) returned %d", n, v)
+			}
+			counts[v]++
+		}
+
+		if n == 1 {
+			continue
+		}
+
+		expected := float64(trials) / float64(n)
+		statistic := 0.0
+		for _, v := range counts {
+			d := float64(v) - expected
+			statistic += d * d / expected
+		}
+
+		if critical := chiSquareCritical(n-1, z000001); statistic > critical {
+			t.Errorf("Chi-square of Intn(%d) should be below %f, got %f", n, critical, statistic)
+		}
+	}
+}
+
+// Tests that the controller shuffles the decks with
+// the injected Shuffler
+func TestCreateDeckInjectedShuffler(t *testing.T) {
+
+	controller := controllers.NewDeckControllerWithShuffler(&data.MemoryDeckRepository{}, reverseShuffler{})
+
+	deck, _ := controller.CreateDeck(true, nil)
+	cards := controller.GetDefaultCardSet()
+
+	for i, v := range deck.Cards {
+		if v != cards[len(cards)-1-i] {
+			t.Errorf("Card %d should be %s, got %s", i, cards[len(cards)-1-i].Code, v.Code)
+			break
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Tests the changes made only at the expected version
+func TestIfVersion(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+	deck, _ := controller.CreateDeck(true, nil)
+
+	err := controller.IfVersion(deck.Id, []int64{deck.Version}, func(c *controllers.DeckController) error {
+		if _, err := c.DrawCards(deck.Id, 2); err != nil {
+			return err
+		}
+		_, err := c.CloseDeck(deck.Id, "")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	opened, _ := controller.OpenDeck(deck.Id)
+	if opened.Version != deck.Version+1 || opened.Remaining != data.MaxCards-2 || !opened.Closed {
+		t.Errorf("The changes should be stored as the next version, got %+v", opened)
+	}
+
+	err = controller.IfVersion(deck.Id, []int64{deck.Version}, func(c *controllers.DeckController) error {
+		return nil
+	})
+	if !errors.Is(err, controllers.ErrVersionMismatch) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrVersionMismatch, err)
+	}
+
+	// Errors of the changes are returned as they are
+	err = controller.IfVersion(deck.Id, []int64{opened.Version}, func(c *controllers.DeckController) error {
+		_, err := c.DrawCards(deck.Id, 1)
+		return err
+	})
+	if !errors.Is(err, controllers.ErrDeckClosed) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckClosed, err)
+	}
+
+	err = controller.IfVersion(deck.Id, []int64{opened.Version}, func(c *controllers.DeckController) error {
+		return c.DeleteDeck(deck.Id, "")
+	})
+	if err != nil {
+		t.Errorf("There should be no error: %v", err)
+	}
+
+	if _, err := controller.OpenDeck(deck.Id); !errors.Is(err, controllers.ErrDeckNotFound) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckNotFound, err)
+	}
+}
@@ -0,0 +1,296 @@
+// Author: Ferran Balaguer
+
+package controllers_test
+
+import (
+	"errors"
+	"test/cardsgame/controllers"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Returns the amount of cards with a code, which are the
+// ones the viewer can see
+func visibleCards(cards []data.Card) int {
+
+	visible := 0
+	for _, v := range cards {
+		if v.Code != "" {
+			visible++
+		}
+	}
+
+	return visible
+}
+
+// Creates a deck owned by the dealer, with a hand for alice
+// and bob and a face up table, and deals 2 cards to each one
+func newTableDeck(t *testing.T, controller *controllers.DeckController) *data.Deck {
+
+	deck, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Shuffle: true, Owner: "dealer"})
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	controller.CreatePileWithOptions(deck.Id, controllers.PileOptions{Name: "alice", Owner: "alice", Viewer: "dealer"})
+	controller.CreatePileWithOptions(deck.Id, controllers.PileOptions{Name: "bob", Owner: "bob", Viewer: "dealer"})
+	controller.CreatePileWithOptions(deck.Id, controllers.PileOptions{Name: "table", FaceUp: true, Viewer: "dealer"})
+
+	for _, name := range []string{"alice", "bob", "table"} {
+		controller.DrawCardsToPile(deck.Id, name, 2, "", "dealer")
+	}
+
+	return deck
+}
+
+// Tests the roles of the viewers of a deck
+func TestViewerRole(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+	deck := newTableDeck(t, controller)
+	deck, _ = controller.OpenDeck(deck.Id)
+
+	roles := map[string]controllers.Role{
+		"dealer":  controllers.RoleOwner,
+		"alice":   controllers.RolePlayer,
+		"carol":   controllers.RoleSpectator,
+		"":        controllers.RoleSpectator,
+		"Dealer ": controllers.RoleSpectator,
+	}
+
+	for viewer, role := range roles {
+		if got := controllers.ViewerRole(deck, viewer); got != role {
+			t.Errorf("Viewer %q should have role %d, got %d", viewer, role, got)
+		}
+	}
+
+	public, _ := controller.CreateDeck(true, nil)
+	if controllers.ViewerRole(public, "carol") != controllers.RoleOwner {
+		t.Errorf("Every viewer of a deck with no owner should see all its cards")
+	}
+}
+
+// Tests that every viewer sees the cards allowed by their role
+func TestOpenDeckViews(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+	deck := newTableDeck(t, controller)
+
+	owner, _ := controller.OpenDeckWithOptions(deck.Id, controllers.OpenOptions{Viewer: "dealer"})
+	if visibleCards(owner.Cards) != data.MaxCards-6 || visibleCards(owner.GetPile("bob").Cards) != 2 {
+		t.Errorf("The owner should see every card")
+	}
+
+	player, _ := controller.OpenDeckWithOptions(deck.Id, controllers.OpenOptions{Viewer: "alice"})
+	if len(player.Cards) != data.MaxCards-6 || visibleCards(player.Cards) != 0 {
+		t.Errorf("A player should only see how many cards are left in the deck")
+	}
+
+	if visibleCards(player.GetPile("alice").Cards) != 2 || visibleCards(player.GetPile("table").Cards) != 2 {
+		t.Errorf("A player should see their own pile and the face up ones")
+	}
+
+	if len(player.GetPile("bob").Cards) != 2 || visibleCards(player.GetPile("bob").Cards) != 0 {
+		t.Errorf("A player should not see the piles of other players")
+	}
+
+	spectator, _ := controller.OpenDeckWithOptions(deck.Id, controllers.OpenOptions{Viewer: "carol"})
+	for _, pile := range spectator.Piles {
+		if len(pile.Cards) != 2 || visibleCards(pile.Cards) != 0 {
+			t.Errorf("A spectator should only see how many cards pile %s has", pile.Name)
+		}
+	}
+
+	if spectator.Remaining != data.MaxCards-6 || visibleCards(spectator.Cards) != 0 {
+		t.Errorf("A spectator should only see how many cards are left in the deck")
+	}
+
+	stored, _ := controller.OpenDeck(deck.Id)
+	if visibleCards(stored.Cards) != data.MaxCards-6 {
+		t.Errorf("Projecting a deck should not change the stored one")
+	}
+}
+
+// Tests the views of piles, dealt cards and peeked cards
+func TestPileViews(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+	deck := newTableDeck(t, controller)
+
+	if pile, _ := controller.GetPile(deck.Id, "alice", "alice"); visibleCards(pile.Cards) != 2 {
+		t.Errorf("A player should see their own pile")
+	}
+
+	if pile, _ := controller.GetPile(deck.Id, "alice", "bob"); len(pile.Cards) != 2 || visibleCards(pile.Cards) != 0 {
+		t.Errorf("A player should not see the pile of another player")
+	}
+
+	if _, err := controller.DrawCardsToPile(deck.Id, "bob", 1, "", "alice"); !errors.Is(err, controllers.ErrNotDeckOwner) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrNotDeckOwner, err)
+	}
+
+	if cards, _ := controller.DrawCardsToPile(deck.Id, "bob", 1, "", "dealer"); visibleCards(cards) != 1 {
+		t.Errorf("The owner should see the dealt cards")
+	}
+
+	if _, err := controller.PeekCards(deck.Id, 1, "alice"); !errors.Is(err, controllers.ErrNotDeckOwner) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrNotDeckOwner, err)
+	}
+
+	if cards, err := controller.PeekCards(deck.Id, 1, "dealer"); err != nil || visibleCards(cards) != 1 {
+		t.Errorf("The owner should peek at the deck: %v", err)
+	}
+}
+
+// Tests that only the owner changes a deck, and that players
+// only move and shuffle the cards of their own piles
+func TestOwnerActions(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+	deck := newTableDeck(t, controller)
+	controller.CreatePileWithOptions(deck.Id, controllers.PileOptions{Name: "bob-2", Owner: "bob", Viewer: "dealer"})
+
+	// Closing goes last, since closed decks can't change
+	actions := []struct {
+		name string
+		run  func(viewer string) error
+	}{
+		{"draw", func(viewer string) error {
+			_, err := controller.DrawCardsWithOptions(deck.Id, controllers.DrawOptions{Amount: 1, Viewer: viewer})
+			return err
+		}},
+		{"burn", func(viewer string) error {
+			_, err := controller.BurnCards(deck.Id, 1, viewer)
+			return err
+		}},
+		{"shuffle", func(viewer string) error {
+			_, err := controller.ShuffleDeck(deck.Id, "", false, viewer)
+			return err
+		}},
+		{"return cards", func(viewer string) error {
+			_, err := controller.ReturnCards(deck.Id, nil, "", viewer)
+			return err
+		}},
+		{"cut", func(viewer string) error {
+			_, err := controller.CutDeck(deck.Id, nil, viewer)
+			return err
+		}},
+		// Holding a pile would make a player of a spectator
+		{"create pile", func(viewer string) error {
+			_, err := controller.CreatePileWithOptions(deck.Id, controllers.PileOptions{Name: "mine", Owner: viewer, Viewer: viewer})
+			return err
+		}},
+		// Dealing to their own pile would show the cards
+		{"draw to pile", func(viewer string) error {
+			_, err := controller.DrawCardsToPile(deck.Id, "alice", 1, "", viewer)
+			return err
+		}},
+		{"return pile", func(viewer string) error {
+			_, err := controller.ReturnPile(deck.Id, "bob", "", viewer)
+			return err
+		}},
+		{"close", func(viewer string) error {
+			_, err := controller.CloseDeck(deck.Id, viewer)
+			return err
+		}},
+	}
+
+	for _, action := range actions {
+		for _, viewer := range []string{"alice", "carol", ""} {
+			if err := action.run(viewer); !errors.Is(err, controllers.ErrNotDeckOwner) {
+				t.Errorf("Should have returned %v for %s as %q, got %v", controllers.ErrNotDeckOwner, action.name, viewer, err)
+			}
+		}
+	}
+
+	stored, _ := controller.OpenDeck(deck.Id)
+	if stored.Remaining != data.MaxCards-6 || len(stored.Piles) != 4 || len(stored.Burned) != 0 {
+		t.Errorf("The rejected actions should not change the deck, got %d cards and %d piles", stored.Remaining, len(stored.Piles))
+	}
+
+	shuffles := []struct {
+		pile     string
+		viewer   string
+		expected error
+	}{
+		{"bob", "alice", controllers.ErrNotPileOwner},
+		{"table", "carol", controllers.ErrNotPileOwner},
+		{"missing", "alice", controllers.ErrPileNotFound},
+		{"alice", "alice", nil},
+		{"bob", "dealer", nil},
+	}
+
+	for _, v := range shuffles {
+		if _, err := controller.ShufflePile(deck.Id, v.pile, "", v.viewer); !errors.Is(err, v.expected) {
+			t.Errorf("Should have returned %v shuffling %s as %q, got %v", v.expected, v.pile, v.viewer, err)
+		}
+	}
+
+	moves := []struct {
+		from     string
+		to       string
+		viewer   string
+		expected error
+	}{
+		{"alice", "bob", "bob", controllers.ErrNotPileOwner},
+		{"alice", "table", "alice", controllers.ErrNotPileOwner},
+		{"table", "bob", "carol", controllers.ErrNotPileOwner},
+		{"bob", "missing", "bob", controllers.ErrPileNotFound},
+		{"bob", "bob-2", "bob", nil},
+		{"alice", "bob", "dealer", nil},
+	}
+
+	for _, v := range moves {
+		if _, err := controller.MovePileCards(deck.Id, v.from, v.to, nil, v.viewer); !errors.Is(err, v.expected) {
+			t.Errorf("Should have returned %v moving %s to %s as %q, got %v", v.expected, v.from, v.to, v.viewer, err)
+		}
+	}
+
+	for _, action := range actions {
+		if err := action.run("dealer"); err != nil {
+			t.Errorf("The owner should %s: %v", action.name, err)
+		}
+	}
+}
+
+// Tests that the players of a fair deck reveal it once the
+// owner closes it
+func TestRevealOwnedDeck(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	fair := newFairDeck(t, controller, controllers.DeckOptions{Owner: "dealer"})
+	controller.CreatePileWithOptions(fair.Id, controllers.PileOptions{Name: "alice", Owner: "alice", Viewer: "dealer"})
+	controller.DrawCardsToPile(fair.Id, "alice", 2, "", "dealer")
+
+	if _, err := controller.RevealDeck(fair.Id); !errors.Is(err, controllers.ErrDeckNotFinished) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrDeckNotFinished, err)
+	}
+
+	if _, err := controller.CloseDeck(fair.Id, "alice"); !errors.Is(err, controllers.ErrNotDeckOwner) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrNotDeckOwner, err)
+	}
+
+	controller.CloseDeck(fair.Id, "dealer")
+
+	reveal, err := controller.RevealDeck(fair.Id)
+	if err != nil || reveal.Commitment != fair.Commitment {
+		t.Errorf("A player should reveal the deck: %v", err)
+	}
+}
+
+// Tests the validation of player identifiers
+func TestInvalidPlayer(t *testing.T) {
+
+	controller := controllers.NewDeckController(&data.MemoryDeckRepository{})
+
+	if _, err := controller.CreateDeckWithOptions(controllers.DeckOptions{Owner: "two words"}); !errors.Is(err, controllers.ErrInvalidPlayer) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrInvalidPlayer, err)
+	}
+
+	deck, _ := controller.CreateDeck(false, nil)
+	options := controllers.PileOptions{Name: "north", Owner: "a/b"}
+	if _, err := controller.CreatePileWithOptions(deck.Id, options); !errors.Is(err, controllers.ErrInvalidPlayer) {
+		t.Errorf("Should have returned %v, got %v", controllers.ErrInvalidPlayer, err)
+	}
+}
@@ -0,0 +1,86 @@
+// Author: Ferran Balaguer
+
+package data_test
+
+import (
+	"errors"
+	"test/cardsgame/data"
+	"testing"
+)
+
+// Tests that every card of a full deck has a different code
+func TestCardCodesAreUnique(t *testing.T) {
+
+	codes := map[string]bool{}
+
+	for s := data.Spades; s <= data.Hearts; s++ {
+		for v := data.Ace; v <= data.King; v++ {
+			code := data.CardCode(v, s)
+			if codes[code] {
+				t.Errorf("Code %v is used by more than one card", code)
+			}
+			codes[code] = true
+		}
+	}
+
+	if len(codes) != data.MaxCards {
+		t.Errorf("There should be %d different codes", data.MaxCards)
+	}
+}
+
+// Tests that every canonical code is parsed back to its card
+func TestParseCardCodeRoundTrip(t *testing.T) {
+
+	for s := data.Spades; s <= data.Hearts; s++ {
+		for v := data.Ace; v <= data.King; v++ {
+			card, err := data.ParseCardCode(data.CardCode(v, s))
+			if err != nil {
+				t.Fatalf("There should be no error: %v", err)
+			}
+			if card.Value != v || card.Suit != s {
+				t.Errorf("Code %v was parsed as %v of %v", card.Code, card.Value, card.Suit)
+			}
+		}
+	}
+}
+
+// Tests that invalid codes are rejected
+func TestParseCardCodeInvalid(t *testing.T) {
+
+	for _, code := range []string{"", "A", "XX", "1S", "11S", "SS", "AST", "XS", "AR"} {
+		if _, err := data.ParseCardCode(code); !errors.Is(err, data.ErrInvalidCode) {
+			t.Errorf("Code %q should return %v", code, data.ErrInvalidCode)
+		}
+	}
+}
+
+// Tests that a deck stored with the legacy codes and enum
+// numbering is migrated to the canonical codes
+func TestMigrateDeck(t *testing.T) {
+
+	// Legacy decks had One = 1 and Two = 2, so the stored
+	// Value field can not be trusted
+	deck := data.Deck{
+		Cards: []data.Card{
+			{Value: 0, Suit: data.Spades, Code: "SA"},
+			{Value: 1, Suit: data.Hearts, Code: "H1"},
+			{Value: 2, Suit: data.Clubs, Code: "C2"},
+		},
+	}
+
+	if err := data.MigrateDeck(&deck); err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	expected := []data.Card{
+		{Value: data.Ace, Suit: data.Spades, Code: "AS"},
+		{Value: data.Ten, Suit: data.Hearts, Code: "TH"},
+		{Value: data.Two, Suit: data.Clubs, Code: "2C"},
+	}
+
+	for i, v := range expected {
+		if deck.Cards[i] != v {
+			t.Errorf("Card %d should be %v, got %v", i, v, deck.Cards[i])
+		}
+	}
+}
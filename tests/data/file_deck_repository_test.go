@@ -0,0 +1,259 @@
+// Author: Ferran Balaguer
+
+package data_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"test/cardsgame/data"
+	"test/cardsgame/data/repotest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Opens a file repository or fails the test
+func openFileRepository(t *testing.T, dir string) *data.FileDeckRepository {
+
+	repository, err := data.NewFileDeckRepository(dir)
+
+	if err != nil {
+		t.Fatalf("Impossible to open the repository: %v", err)
+	}
+
+	return repository
+}
+
+// Tests that decks and draws are recovered after reopening
+func TestFileRepositoryReplay(t *testing.T) {
+
+	dir := t.TempDir()
+	deck := repotest.NewStandardDeck()
+
+	repository := openFileRepository(t, dir)
+	repository.Add(deck)
+	repository.DrawCardsFromDeck(deck.Id, 5)
+	repository.DrawCardsFromDeck(deck.Id, 2)
+	repository.Close()
+
+	reopened := openFileRepository(t, dir)
+	defer reopened.Close()
+
+	stored, err := reopened.GetDeckById(deck.Id)
+	if err != nil {
+		t.Fatalf("Deck should have been recovered: %v", err)
+	}
+
+	if stored.Remaining != data.MaxCards-7 || len(stored.Cards) != data.MaxCards-7 {
+		t.Errorf("Remaining cards must be = %d", data.MaxCards-7)
+	}
+
+	if stored.Cards[0] != deck.Cards[7] {
+		t.Errorf("First card should be %v, got %v", deck.Cards[7].Code, stored.Cards[0].Code)
+	}
+}
+
+// Tests that a record partially written when the process
+// died is discarded, and the repository keeps working
+func TestFileRepositoryTornRecord(t *testing.T) {
+
+	dir := t.TempDir()
+	deck := repotest.NewStandardDeck()
+
+	repository := openFileRepository(t, dir)
+	repository.Add(deck)
+	repository.DrawCardsFromDeck(deck.Id, 3)
+	repository.Close()
+
+	journal, _ := os.OpenFile(filepath.Join(dir, "journal.log"), os.O_APPEND|os.O_WRONLY, 0644)
+	journal.WriteString(`{"deck":{"Id":"` + deck.Id.String() + `","Remaining":`)
+	journal.Close()
+
+	reopened := openFileRepository(t, dir)
+	stored, _ := reopened.GetDeckById(deck.Id)
+
+	if stored.Remaining != data.MaxCards-3 {
+		t.Errorf("Remaining cards must be = %d, got %d", data.MaxCards-3, stored.Remaining)
+	}
+
+	reopened.DrawCardsFromDeck(deck.Id, 1)
+	reopened.Close()
+
+	reopened = openFileRepository(t, dir)
+	defer reopened.Close()
+	stored, _ = reopened.GetDeckById(deck.Id)
+
+	if stored.Remaining != data.MaxCards-4 {
+		t.Errorf("Remaining cards must be = %d, got %d", data.MaxCards-4, stored.Remaining)
+	}
+}
+
+// Tests that deleted decks are not recovered, both from
+// the journal and from the snapshots
+func TestFileRepositoryReplayDeletions(t *testing.T) {
+
+	dir := t.TempDir()
+	deck := repotest.NewStandardDeck()
+	other := repotest.NewStandardDeck()
+
+	repository := openFileRepository(t, dir)
+	repository.Add(deck)
+	repository.Add(other)
+	repository.DrawCardsFromDeck(deck.Id, 5)
+	repository.DeleteDeck(deck.Id)
+	repository.Close()
+
+	reopened := openFileRepository(t, dir)
+
+	if _, err := reopened.GetDeckById(deck.Id); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+
+	if _, err := reopened.GetDeckById(other.Id); err != nil {
+		t.Errorf("Deck should have been recovered: %v", err)
+	}
+
+	// Opening compacted the journal into a snapshot
+	reopened.DeleteDeck(other.Id)
+	reopened.Close()
+
+	reopened = openFileRepository(t, dir)
+	defer reopened.Close()
+
+	if _, err := reopened.GetDeckById(other.Id); !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("Should have returned %v, got %v", data.ErrNotFound, err)
+	}
+}
+
+// Tests that the decks are recovered from the snapshots
+// and the journal is truncated after writing them
+func TestFileRepositorySnapshot(t *testing.T) {
+
+	dir := t.TempDir()
+	deck := repotest.NewStandardDeck()
+
+	repository := openFileRepository(t, dir)
+	repository.SnapshotInterval = 3
+	repository.Add(deck)
+	for i := 0; i < 10; i++ {
+		repository.DrawCardsFromDeck(deck.Id, 1)
+	}
+	repository.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "snapshot.json")); err != nil {
+		t.Errorf("Snapshot should have been written: %v", err)
+	}
+
+	reopened := openFileRepository(t, dir)
+	defer reopened.Close()
+	stored, _ := reopened.GetDeckById(deck.Id)
+
+	if stored.Remaining != data.MaxCards-10 || stored.Cards[0] != deck.Cards[10] {
+		t.Errorf("Remaining cards must be = %d, got %d", data.MaxCards-10, stored.Remaining)
+	}
+}
+
+// Tests that decks stored with the legacy codes are
+// migrated when the repository is opened
+func TestFileRepositoryMigratesLegacyCodes(t *testing.T) {
+
+	dir := t.TempDir()
+	id := uuid.New()
+
+	snapshot := fmt.Sprintf(`[{"Id":"%v","Remaining":2,"Cards":[{"Value":0,"Suit":0,"Code":"SA"},{"Value":1,"Suit":3,"Code":"H1"}]}]`, id)
+	os.WriteFile(filepath.Join(dir, "snapshot.json"), []byte(snapshot), 0644)
+
+	repository := openFileRepository(t, dir)
+	defer repository.Close()
+	stored, _ := repository.GetDeckById(id)
+
+	if stored.Cards[0].Code != "AS" || stored.Cards[1].Code != "TH" || stored.Cards[1].Value != data.Ten {
+		t.Errorf("Legacy codes should have been migrated, got %v and %v", stored.Cards[0].Code, stored.Cards[1].Code)
+	}
+}
+
+// Kills the process with SIGKILL while it is drawing cards and
+// checks that no card is lost nor duplicated after recovering
+func TestFileRepositoryKilledMidDraw(t *testing.T) {
+
+	if dir := os.Getenv("CARDSGAME_DRAW_DIR"); dir != "" {
+		drawForever(dir, uuid.MustParse(os.Getenv("CARDSGAME_DRAW_DECK")))
+		return
+	}
+
+	dir := t.TempDir()
+
+	// A big deck so that the process is still drawing when killed
+	deck := repotest.NewStandardDeck()
+	for len(deck.Cards) < 20*data.MaxCards {
+		card := deck.Cards[len(deck.Cards)%data.MaxCards]
+		card.Instance = len(deck.Cards) + 1
+		deck.Cards = append(deck.Cards, card)
+	}
+	deck.Remaining = len(deck.Cards)
+
+	repository := openFileRepository(t, dir)
+	repository.Add(deck)
+	repository.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFileRepositoryKilledMidDraw$")
+	cmd.Env = append(os.Environ(), "CARDSGAME_DRAW_DIR="+dir, "CARDSGAME_DRAW_DECK="+deck.Id.String())
+	stdout, _ := cmd.StdoutPipe()
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Impossible to start the drawing process: %v", err)
+	}
+
+	// Every line is the instance of a card the process got
+	// from the repository. The process is killed after some
+	// cards and the rest of its output is read afterwards
+	drawn := map[int]bool{}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		var instance int
+		fmt.Sscan(scanner.Text(), &instance)
+		drawn[instance] = true
+		if len(drawn) == 20 {
+			cmd.Process.Kill()
+		}
+	}
+	cmd.Wait()
+
+	reopened := openFileRepository(t, dir)
+	defer reopened.Close()
+	stored, _ := reopened.GetDeckById(deck.Id)
+
+	for _, v := range stored.Cards {
+		if drawn[v.Instance] {
+			t.Errorf("Card %v was drawn but is still in the deck", v.Instance)
+		}
+	}
+
+	// The draw being done when killed may be on disk but
+	// not reported yet
+	lost := len(deck.Cards) - stored.Remaining - len(drawn)
+	if lost < 0 || lost > 1 || len(stored.Cards) != stored.Remaining {
+		t.Errorf("%d cards drawn and %d remaining, there should be %d", len(drawn), stored.Remaining, len(deck.Cards))
+	}
+}
+
+// Draws a deck one card at a time printing their
+// instances, until the process is killed
+func drawForever(dir string, id uuid.UUID) {
+
+	repository, err := data.NewFileDeckRepository(dir)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	for {
+		cards, err := repository.DrawCardsFromDeck(id, 1)
+		if err != nil {
+			os.Exit(0)
+		}
+		fmt.Println(cards[0].Instance)
+	}
+}
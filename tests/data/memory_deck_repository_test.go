@@ -0,0 +1,90 @@
+// Author: Ferran Balaguer
+
+package data_test
+
+import (
+	"test/cardsgame/data"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Adds a deck with the given codes to a new repository
+func newRepositoryWithDeck(codes ...string) (*data.MemoryDeckRepository, data.Deck) {
+
+	deck := data.Deck{Id: uuid.New(), Remaining: len(codes)}
+	for i, v := range codes {
+		card, _ := data.ParseCardCode(v)
+		card.Instance = i + 1
+		deck.Cards = append(deck.Cards, card)
+	}
+
+	repository := &data.MemoryDeckRepository{}
+	repository.Add(deck)
+
+	return repository, deck
+}
+
+// Tests that an opened deck is not modified by later draws
+func TestOpenedDeckIsSnapshot(t *testing.T) {
+
+	repository, deck := newRepositoryWithDeck("AS", "2S", "3S", "4S")
+
+	opened, _ := repository.GetDeckById(deck.Id)
+
+	repository.DrawCardsFromDeck(deck.Id, 2)
+
+	if opened.Remaining != 4 || len(opened.Cards) != 4 {
+		t.Errorf("Opened deck should still have 4 cards")
+	}
+
+	if opened.Cards[0].Code != "AS" {
+		t.Errorf("Opened deck first card should still be AS")
+	}
+}
+
+// Tests that a drawn cards slice doesn't alias the stored
+// deck, so appending to it doesn't overwrite the next cards
+func TestDrawnCardsAreSnapshot(t *testing.T) {
+
+	repository, deck := newRepositoryWithDeck("AS", "2S", "3S", "4S")
+
+	drawn, _ := repository.DrawCardsFromDeck(deck.Id, 2)
+	drawn = append(drawn, data.Card{Code: "ZZ"})
+	drawn[0].Code = "ZZ"
+
+	next, _ := repository.DrawCardsFromDeck(deck.Id, 2)
+
+	if next[0].Code != "3S" || next[1].Code != "4S" {
+		t.Errorf("The next cards should be 3S and 4S, got %v and %v", next[0].Code, next[1].Code)
+	}
+
+	if drawn[1].Code != "2S" {
+		t.Errorf("Drawn cards should not change after later draws")
+	}
+}
+
+// Tests that modifying a deck outside of the repository
+// doesn't modify the stored one
+func TestStoredDeckIsNotShared(t *testing.T) {
+
+	repository, deck := newRepositoryWithDeck("AS", "2S")
+
+	// The deck passed to Add
+	deck.Cards[0].Code = "ZZ"
+
+	// A deck returned by GetDeckById
+	opened, _ := repository.GetDeckById(deck.Id)
+	opened.Cards[1].Code = "ZZ"
+	opened.Remaining = 0
+
+	// A card returned by GetDeckCardByCode
+	card, _ := repository.GetDeckCardByCode(deck.Id, "AS")
+	card.Code = "ZZ"
+
+	stored, _ := repository.GetDeckById(deck.Id)
+
+	if stored.Cards[0].Code != "AS" || stored.Cards[1].Code != "2S" || stored.Remaining != 2 {
+		t.Errorf("Stored deck was modified from outside the repository")
+	}
+}
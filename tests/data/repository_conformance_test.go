@@ -0,0 +1,35 @@
+// Author: Ferran Balaguer
+
+package data_test
+
+import (
+	"path/filepath"
+	"test/cardsgame/data"
+	"test/cardsgame/data/repotest"
+	"testing"
+)
+
+func TestMemoryRepositoryConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) data.DeckRepository {
+		return &data.MemoryDeckRepository{}
+	})
+}
+
+func TestFileRepositoryConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) data.DeckRepository {
+		repository := openFileRepository(t, t.TempDir())
+		t.Cleanup(func() { repository.Close() })
+		return repository
+	})
+}
+
+func TestSqlRepositoryConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) data.DeckRepository {
+		repository, err := data.NewSqlDeckRepository(filepath.Join(t.TempDir(), "cards.db"))
+		if err != nil {
+			t.Fatalf("Impossible to open the repository: %v", err)
+		}
+		t.Cleanup(func() { repository.Close() })
+		return repository
+	})
+}
@@ -0,0 +1,123 @@
+// Author: Ferran Balaguer
+
+package data_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"test/cardsgame/data"
+	"test/cardsgame/data/repotest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Tests that the decks are kept after closing the database,
+// and that reopening it doesn't apply the migrations again
+func TestSqlRepositoryReopen(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "cards.db")
+	deck := repotest.NewStandardDeck()
+
+	repository, err := data.NewSqlDeckRepository(path)
+	if err != nil {
+		t.Fatalf("Impossible to open the repository: %v", err)
+	}
+	repository.Add(deck)
+	repository.DrawCardsFromDeck(deck.Id, 4)
+	repository.Close()
+
+	reopened, err := data.NewSqlDeckRepository(path)
+	if err != nil {
+		t.Fatalf("Impossible to reopen the repository: %v", err)
+	}
+	defer reopened.Close()
+
+	stored, err := reopened.GetDeckById(deck.Id)
+	if err != nil {
+		t.Fatalf("Deck should have been kept: %v", err)
+	}
+
+	if stored.Remaining != data.MaxCards-4 || stored.Cards[0] != deck.Cards[4] {
+		t.Errorf("Remaining cards must be = %d", data.MaxCards-4)
+	}
+}
+
+// Reads the row ids of the cards left in a deck, from the top
+func cardRows(t *testing.T, db *sql.DB, deckId uuid.UUID) []int64 {
+
+	rows, err := db.Query(`SELECT rowid FROM cards WHERE deck_id = ? ORDER BY position DESC`, deckId.String())
+	if err != nil {
+		t.Fatalf("Impossible to read the cards: %v", err)
+	}
+	defer rows.Close()
+
+	var result []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("Impossible to read the cards: %v", err)
+		}
+		result = append(result, id)
+	}
+
+	return result
+}
+
+// Tests that drawing and returning cards only writes the rows
+// of the cards that changed
+func TestSqlRepositoryWritesChangedRows(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "cards.db")
+	deck := repotest.NewStandardDeck()
+
+	repository, err := data.NewSqlDeckRepository(path)
+	if err != nil {
+		t.Fatalf("Impossible to open the repository: %v", err)
+	}
+	defer repository.Close()
+	repository.Add(deck)
+
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(1000)")
+	if err != nil {
+		t.Fatalf("Impossible to open the database: %v", err)
+	}
+	defer db.Close()
+
+	before := cardRows(t, db, deck.Id)
+
+	drawn, err := repository.DrawCardsFromDeck(deck.Id, 2)
+	if err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	after := cardRows(t, db, deck.Id)
+	if len(after) != data.MaxCards-2 {
+		t.Fatalf("Remaining cards must be = %d, got %d", data.MaxCards-2, len(after))
+	}
+	for i, v := range after {
+		if v != before[i+2] {
+			t.Fatalf("The cards left should keep their rows, got %v instead of %v", after, before[2:])
+		}
+	}
+
+	if _, err := repository.ReturnCards(deck.Id, []string{drawn[0].Code}, data.PositionTop, nil); err != nil {
+		t.Fatalf("There should be no error: %v", err)
+	}
+
+	returned := cardRows(t, db, deck.Id)
+	for i, v := range after {
+		if returned[i+1] != v {
+			t.Fatalf("The cards left should keep their rows, got %v instead of %v", returned[1:], after)
+		}
+	}
+
+	stored, _ := repository.GetDeckById(deck.Id)
+	if stored.Cards[0] != drawn[0] || stored.Cards[1] != deck.Cards[2] {
+		t.Errorf("The returned card should be on top, got %v", stored.Cards[:2])
+	}
+
+	if card, _ := repository.GetDeckCardByCode(deck.Id, drawn[0].Code); card == nil || *card != drawn[0] {
+		t.Errorf("Should have found %v, got %v", drawn[0], card)
+	}
+}
@@ -0,0 +1,161 @@
+// Author: Ferran Balaguer
+
+package fairness_test
+
+import (
+	"errors"
+	"test/cardsgame/fairness"
+	"testing"
+)
+
+var initialCards = []string{"AS", "2S", "3S", "4S", "5S", "6S", "7S", "8S", "9S", "TS"}
+
+// Returns a valid reveal of a euchre deck with a joker
+func newReveal() fairness.Reveal {
+
+	initial, _ := fairness.InitialCodes("euchre", 1, 1)
+	cards := fairness.ShuffleCodes(initial, "server", "client")
+
+	return fairness.Reveal{
+		ServerSeed:     "server",
+		ServerSeedHash: fairness.SeedHash("server"),
+		ClientSeed:     "client",
+		Commitment:     fairness.Commitment("server", cards),
+		Composition:    "euchre",
+		Jokers:         1,
+		Decks:          1,
+		InitialCards:   initial,
+		Cards:          cards,
+	}
+}
+
+// Tests that the shuffle only depends on the seeds
+func TestShuffleIsDeterministic(t *testing.T) {
+
+	a := fairness.ShuffleCodes(initialCards, "server", "client")
+	b := fairness.ShuffleCodes(initialCards, "server", "client")
+	c := fairness.ShuffleCodes(initialCards, "server", "other")
+
+	same, other := true, true
+	for i := range a {
+		same = same && a[i] == b[i]
+		other = other && a[i] == c[i]
+	}
+
+	if !same {
+		t.Errorf("The same seeds must produce the same order")
+	}
+
+	if other {
+		t.Errorf("A different client seed must produce a different order")
+	}
+}
+
+// Tests that bounded numbers are always inside the bounds
+func TestSourceIntn(t *testing.T) {
+
+	source := fairness.NewSource("server", "client")
+
+	for n := 1; n < 1000; n++ {
+		if v := source.Intn(n); v < 0 || v >= n {
+			t.Fatalf("Intn(%d) returned %d", n, v)
+		}
+	}
+}
+
+// Tests that a valid reveal is verified
+func TestVerifyOk(t *testing.T) {
+
+	if err := fairness.Verify(newReveal()); err != nil {
+		t.Errorf("There should be no error: %v", err)
+	}
+}
+
+// Tests that any tampering of the reveal is detected
+func TestVerifyTampered(t *testing.T) {
+
+	reveal := newReveal()
+	reveal.ServerSeed = "other"
+	if err := fairness.Verify(reveal); !errors.Is(err, fairness.ErrSeedHashMismatch) {
+		t.Errorf("A different server seed should return %v", fairness.ErrSeedHashMismatch)
+	}
+
+	// The server seed matches the hash, but not the commitment
+	reveal = newReveal()
+	reveal.Commitment = fairness.Commitment("other", reveal.Cards)
+	if err := fairness.Verify(reveal); !errors.Is(err, fairness.ErrCommitmentMismatch) {
+		t.Errorf("A different commitment should return %v", fairness.ErrCommitmentMismatch)
+	}
+
+	reveal = newReveal()
+	reveal.Cards[0], reveal.Cards[1] = reveal.Cards[1], reveal.Cards[0]
+	if err := fairness.Verify(reveal); !errors.Is(err, fairness.ErrCommitmentMismatch) {
+		t.Errorf("A different order should return %v", fairness.ErrCommitmentMismatch)
+	}
+
+	// The commitment matches, but the order is not the
+	// one produced by the seeds
+	reveal = newReveal()
+	reveal.Cards[0], reveal.Cards[1] = reveal.Cards[1], reveal.Cards[0]
+	reveal.Commitment = fairness.Commitment(reveal.ServerSeed, reveal.Cards)
+	if err := fairness.Verify(reveal); !errors.Is(err, fairness.ErrOrderMismatch) {
+		t.Errorf("An order not produced by the seeds should return %v", fairness.ErrOrderMismatch)
+	}
+}
+
+// Tests that the initial order is built from the deck instead
+// of trusting the revealed one
+func TestVerifyInitialCards(t *testing.T) {
+
+	// The cards are the shuffle of the revealed initial cards,
+	// but these are not the initial order of the deck
+	reveal := newReveal()
+	reveal.InitialCards[0], reveal.InitialCards[1] = reveal.InitialCards[1], reveal.InitialCards[0]
+	reveal.Cards = fairness.ShuffleCodes(reveal.InitialCards, reveal.ServerSeed, reveal.ClientSeed)
+	reveal.Commitment = fairness.Commitment(reveal.ServerSeed, reveal.Cards)
+	if err := fairness.Verify(reveal); !errors.Is(err, fairness.ErrInitialMismatch) {
+		t.Errorf("A different initial order should return %v", fairness.ErrInitialMismatch)
+	}
+
+	reveal = newReveal()
+	reveal.Jokers = 0
+	if err := fairness.Verify(reveal); !errors.Is(err, fairness.ErrInitialMismatch) {
+		t.Errorf("A different composition should return %v", fairness.ErrInitialMismatch)
+	}
+
+	reveal = newReveal()
+	reveal.Composition = "tarot"
+	if err := fairness.Verify(reveal); !errors.Is(err, fairness.ErrInvalidDeck) {
+		t.Errorf("An unknown composition should return %v", fairness.ErrInvalidDeck)
+	}
+}
+
+// Tests the initial order of the decks
+func TestInitialCodes(t *testing.T) {
+
+	sizes := map[string]int{"standard": 52, "piquet": 32, "euchre": 24, "pinochle": 48}
+
+	for composition, size := range sizes {
+		codes, err := fairness.InitialCodes(composition, 2, 3)
+		if err != nil || len(codes) != (size+2)*3 {
+			t.Errorf("A shoe of 3 %s decks should have %d cards, got %d: %v", composition, (size+2)*3, len(codes), err)
+		}
+	}
+
+	codes, _ := fairness.InitialCodes("standard", 2, 1)
+	for i, v := range initialCards {
+		if codes[i] != v {
+			t.Errorf("Card %d should be %s, got %s", i, v, codes[i])
+		}
+	}
+
+	if codes[13] != "AD" || codes[52] != "XR" || codes[53] != "XB" {
+		t.Errorf("Suits should follow spades and jokers go last, red first")
+	}
+
+	for _, v := range []struct{ jokers, decks int }{{3, 1}, {-1, 1}, {0, 0}} {
+		if _, err := fairness.InitialCodes("standard", v.jokers, v.decks); !errors.Is(err, fairness.ErrInvalidDeck) {
+			t.Errorf("%d jokers and %d decks should return %v", v.jokers, v.decks, fairness.ErrInvalidDeck)
+		}
+	}
+}